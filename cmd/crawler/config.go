@@ -1,17 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/akamensky/argparse"
-	"github.com/m1dugh/crawler/internal/plugin"
 	"github.com/m1dugh/crawler/pkg/config"
+	"github.com/m1dugh/crawler/pkg/plugin"
 )
 
 func AddConfigCommand(parser *argparse.Parser) *argparse.Command {
@@ -34,6 +36,14 @@ func AddConfigCommand(parser *argparse.Parser) *argparse.Command {
 		Help: "if specified, the specified file will be copied to ROOT_FOLDER/plugins",
 	})
 
+	addCommand.Flag("", "exec", &argparse.Options{
+		Help: "if specified, the file is an executable speaking the exec plugin protocol",
+	})
+
+	addCommand.StringList("", "arg", &argparse.Options{
+		Help: "the arguments given to an exec plugin",
+	})
+
 	activateCommand := configCommand.NewCommand("activate", "activates/deactivates a loaded plugin")
 
 	activateCommand.Flag("d", "disable", &argparse.Options{
@@ -45,6 +55,17 @@ func AddConfigCommand(parser *argparse.Parser) *argparse.Command {
 		Help:     "the name of the plugin",
 	})
 
+	setCommand := configCommand.NewCommand("set", "sets the settings given to a plugin when loaded, as key=value arguments")
+
+	setCommand.String("t", "tag", &argparse.Options{
+		Required: true,
+		Help:     "the name of the plugin",
+	})
+
+	setCommand.StringList("u", "unset", &argparse.Options{
+		Help: "the key of a setting to remove, can be specified multiple times",
+	})
+
 	deleteCommand := configCommand.NewCommand("remove", "removes a plugin configuration")
 
 	deleteCommand.String("t", "tag", &argparse.Options{
@@ -62,6 +83,14 @@ func AddConfigCommand(parser *argparse.Parser) *argparse.Command {
 		Help: "print path of the plugins",
 	})
 
+	listCommand.Flag("f", "filters", &argparse.Options{
+		Help: "print the policies available for the --policy flag of crawl, opening the plugins without initializing them",
+	})
+
+	listCommand.Flag("v", "verbose", &argparse.Options{
+		Help: "print the metadata, hooks and filters of the plugins, opening them without initializing them",
+	})
+
 	checkCommand := configCommand.NewCommand("check", "checks if all plugins are ready to be used bu the crawler")
 
 	checkCommand.Flag("a", "all", &argparse.Options{
@@ -76,7 +105,39 @@ func AddConfigCommand(parser *argparse.Parser) *argparse.Command {
 
 }
 
-func HandleConfigCommand(configCommand *argparse.Command) {
+// the flags of config set followed by a value
+var SET_VALUE_FLAGS = []string{"-t", "--tag", "-u", "--unset"}
+
+// returns args without the key=value settings given to config set, argparse
+// not supporting positional arguments, and these settings
+func ExtractSetSettings(args []string) ([]string, []string) {
+	if len(args) < 3 || args[1] != "config" || args[2] != "set" {
+		return args, nil
+	}
+
+	result := append(make([]string, 0, len(args)), args[:3]...)
+	settings := make([]string, 0)
+	for i := 3; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			settings = append(settings, args[i])
+			continue
+		}
+
+		result = append(result, args[i])
+		for _, flag := range SET_VALUE_FLAGS {
+			if args[i] == flag && i+1 < len(args) {
+				i++
+				result = append(result, args[i])
+				break
+			}
+		}
+	}
+
+	return result, settings
+}
+
+// settings are the key=value arguments of config set, see ExtractSetSettings
+func HandleConfigCommand(configCommand *argparse.Command, settings []string) {
 
 	cfg, err := config.GetConfig()
 
@@ -90,6 +151,8 @@ func HandleConfigCommand(configCommand *argparse.Command) {
 				var file *os.File
 				var tag string
 				var mvFlag bool
+				var execFlag bool
+				var args []string
 				for _, arg := range command.GetArgs() {
 					switch arg.GetLname() {
 					case "file":
@@ -98,9 +161,13 @@ func HandleConfigCommand(configCommand *argparse.Command) {
 						tag = *arg.GetResult().(*string)
 					case "mv":
 						mvFlag = *arg.GetResult().(*bool)
+					case "exec":
+						execFlag = *arg.GetResult().(*bool)
+					case "arg":
+						args = *arg.GetResult().(*[]string)
 					}
 				}
-				handleAddCommand(&cfg, file, tag, mvFlag)
+				handleAddCommand(&cfg, file, tag, mvFlag, execFlag, args)
 			} else if command.GetName() == "activate" {
 				var disable bool
 				var tag string
@@ -115,6 +182,20 @@ func HandleConfigCommand(configCommand *argparse.Command) {
 				}
 
 				handleActivateCommand(&cfg, tag, disable)
+			} else if command.GetName() == "set" {
+				var tag string
+				var unset []string
+
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "tag":
+						tag = *arg.GetResult().(*string)
+					case "unset":
+						unset = *arg.GetResult().(*[]string)
+					}
+				}
+
+				handleSetCommand(&cfg, tag, settings, unset)
 			} else if command.GetName() == "remove" {
 				var tag string
 
@@ -129,6 +210,8 @@ func HandleConfigCommand(configCommand *argparse.Command) {
 			} else if command.GetName() == "list" {
 				var all bool
 				var path bool
+				var filters bool
+				var verbose bool
 
 				for _, arg := range command.GetArgs() {
 					switch arg.GetLname() {
@@ -136,10 +219,14 @@ func HandleConfigCommand(configCommand *argparse.Command) {
 						all = *arg.GetResult().(*bool)
 					case "path":
 						path = *arg.GetResult().(*bool)
+					case "filters":
+						filters = *arg.GetResult().(*bool)
+					case "verbose":
+						verbose = *arg.GetResult().(*bool)
 					}
 				}
 
-				handleListCommand(cfg, all, path)
+				handleListCommand(cfg, all, path, filters, verbose)
 			} else if command.GetName() == "check" {
 				var all bool
 				var verbose bool
@@ -164,22 +251,22 @@ func handleCheckCommand(cfg *config.Config, all bool, verbose bool) {
 	for _, pluginConfig := range cfg.Plugins {
 		if pluginConfig.Active || all {
 
-			var pluginPath string
-
-			if strings.HasPrefix(pluginConfig.Path, "/") {
-				pluginPath = pluginConfig.Path
-			} else {
-
-				pluginPath = path.Join(config.ROOT_PATH, pluginConfig.Path)
+			// the plugins are not initialized, their settings being checked against their schema
+			crawlerPlugin, err := config.OpenPlugin(pluginConfig)
+			if err == nil {
+				_, err = plugin.ValidateSettings(crawlerPlugin.Settings, pluginConfig.Settings)
+				if err == nil && verbose {
+					fmt.Printf("%s at %s is working\n", pluginConfig.Name, pluginConfig.Path)
+					printPluginMetadata(crawlerPlugin)
+				}
+				crawlerPlugin.Close()
 			}
 
-			_, err := plugin.GetCrawlerPlugin(pluginPath)
-
 			if err != nil {
 				fmt.Printf("could not load %s at %s\n", pluginConfig.Name, pluginConfig.Path)
 
 				if verbose {
-					fmt.Println(err)
+					printLoadError(err)
 				}
 				valid = false
 			}
@@ -193,7 +280,11 @@ func handleCheckCommand(cfg *config.Config, all bool, verbose bool) {
 	}
 }
 
-func handleListCommand(cfg config.Config, all, path bool) {
+func handleListCommand(cfg config.Config, all, path, filters, verbose bool) {
+	if filters {
+		fmt.Println("built-in policies:", strings.Join(BUILTIN_POLICIES, ", "))
+	}
+
 	count := 0
 	for _, p := range cfg.Plugins {
 		if all || p.Active {
@@ -212,6 +303,10 @@ func handleListCommand(cfg config.Config, all, path bool) {
 
 			fmt.Println(message)
 
+			if filters || verbose {
+				printPluginDetails(p, filters, verbose)
+			}
+
 		}
 	}
 
@@ -222,6 +317,62 @@ func handleListCommand(cfg config.Config, all, path bool) {
 	}
 }
 
+// prints the error and the details of a *plugin.LoadError
+func printLoadError(err error) {
+	fmt.Printf("\t%s\n", err)
+
+	var loadErr *plugin.LoadError
+	if errors.As(err, &loadErr) {
+		for _, detail := range loadErr.Details {
+			fmt.Printf("\t  - %s\n", detail)
+		}
+	}
+}
+
+func printPluginMetadata(crawlerPlugin *plugin.CrawlerPlugin) {
+	metadata := crawlerPlugin.Metadata
+	if len(metadata.Name) > 0 {
+		fmt.Println("\tname:", metadata.Name)
+	}
+	if len(metadata.Version) > 0 {
+		fmt.Println("\tversion:", metadata.Version)
+	}
+	if len(metadata.Author) > 0 {
+		fmt.Println("\tauthor:", metadata.Author)
+	}
+	if len(metadata.Description) > 0 {
+		fmt.Println("\tdescription:", metadata.Description)
+	}
+	fmt.Println("\tapi version:", crawlerPlugin.GetApiVersion())
+	fmt.Println("\thooks:", strings.Join(crawlerPlugin.SupportedHooks(), ", "))
+}
+
+func printPluginDetails(pluginConfig *config.PluginConfig, filters bool, verbose bool) {
+	crawlerPlugin, err := config.OpenPlugin(pluginConfig)
+	if err != nil {
+		fmt.Println("\tcould not load plugin:")
+		printLoadError(err)
+		return
+	}
+	defer crawlerPlugin.Close()
+
+	if verbose {
+		printPluginMetadata(crawlerPlugin)
+	}
+
+	names := make([]string, 0, len(crawlerPlugin.Filters))
+	for name, filter := range crawlerPlugin.Filters {
+		if filter != nil && *filter != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("\tfilter: %s.%s\n", pluginConfig.Name, name)
+	}
+}
+
 func handleRemoveCommand(cfg *config.Config, tag string) {
 	found := false
 	for i, p := range cfg.Plugins {
@@ -242,6 +393,45 @@ func handleRemoveCommand(cfg *config.Config, tag string) {
 	}
 }
 
+func handleSetCommand(cfg *config.Config, tag string, settings []string, unset []string) {
+	var pluginConfig *config.PluginConfig
+	for _, p := range cfg.Plugins {
+		if p.Name == tag {
+			pluginConfig = p
+		}
+	}
+
+	if pluginConfig == nil {
+		log.Fatal("could not find plugin ", tag)
+	}
+
+	if pluginConfig.Settings == nil {
+		pluginConfig.Settings = make(map[string]string)
+	}
+
+	if len(settings) == 0 && len(unset) == 0 {
+		log.Fatal("no setting given, expected key=value or --unset key")
+	}
+
+	for _, setting := range settings {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			log.Fatal("invalid setting ", setting, ", expected key=value")
+		}
+		pluginConfig.Settings[parts[0]] = parts[1]
+	}
+
+	for _, key := range unset {
+		delete(pluginConfig.Settings, key)
+	}
+
+	if config.SaveConfig(*cfg) {
+		fmt.Println("successfully updated settings of", tag)
+	} else {
+		log.Fatal("could not save config")
+	}
+}
+
 func handleActivateCommand(cfg *config.Config, tag string, disable bool) {
 	found := false
 	for i, plg := range cfg.Plugins {
@@ -273,7 +463,7 @@ func handleActivateCommand(cfg *config.Config, tag string, disable bool) {
 	}
 }
 
-func handleAddCommand(cfg *config.Config, file *os.File, tag string, mvFlag bool) {
+func handleAddCommand(cfg *config.Config, file *os.File, tag string, mvFlag bool, execFlag bool, args []string) {
 	if argparse.IsNilFile(file) {
 		log.Fatal("could not find specified file")
 	}
@@ -303,6 +493,12 @@ func handleAddCommand(cfg *config.Config, file *os.File, tag string, mvFlag bool
 			log.Fatal(err)
 		}
 
+		if execFlag {
+			if err = destination.Chmod(0755); err != nil {
+				log.Fatal(err)
+			}
+		}
+
 	}
 	pluginConfig := &config.PluginConfig{
 		Name:   tag,
@@ -310,6 +506,11 @@ func handleAddCommand(cfg *config.Config, file *os.File, tag string, mvFlag bool
 		Active: true,
 	}
 
+	if execFlag {
+		pluginConfig.Kind = config.PLUGIN_KIND_EXEC
+		pluginConfig.Args = args
+	}
+
 	cfg.Plugins = append(cfg.Plugins, pluginConfig)
 
 	if config.SaveConfig(*cfg) {
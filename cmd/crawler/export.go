@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime/debug"
+
+	"github.com/akamensky/argparse"
+	"github.com/m1dugh/crawler/pkg/graph"
+	"github.com/m1dugh/crawler/pkg/har"
+)
+
+func AddExportCommand(parser *argparse.Parser) *argparse.Command {
+
+	exportCommand := parser.NewCommand("export", "exports a saved scan to other formats")
+
+	harCommand := exportCommand.NewCommand("har", "exports the pages of a scan as a HAR 1.2 file, without their bodies and with the credentials of their request headers redacted")
+
+	harCommand.String("f", "file", &argparse.Options{
+		Required: true,
+		Help:     "the db file of the scan (crawl --save) or a crawl --store directory",
+	})
+
+	harCommand.String("o", "output", &argparse.Options{
+		Help: "the HAR file written (default: stdout)",
+	})
+
+	graphCommand := exportCommand.NewCommand("graph", "exports the link graph of the pages of a scan")
+
+	graphCommand.String("f", "file", &argparse.Options{
+		Required: true,
+		Help:     "the db file of the scan (crawl --save) or a crawl --store directory",
+	})
+
+	graphCommand.String("o", "output", &argparse.Options{
+		Help: "the file the graph is written to (default: stdout)",
+	})
+
+	graphCommand.Selector("", "format", graph.FORMATS, &argparse.Options{
+		Default: "dot",
+		Help:    "the format of the graph, graphviz dot, graphml or json",
+	})
+
+	graphCommand.Selector("", "collapse", graph.COLLAPSE_MODES, &argparse.Options{
+		Default: string(graph.COLLAPSE_NONE),
+		Help:    "groups the pages into a node per directory or host",
+	})
+
+	graphCommand.Flag("", "fetched-only", &argparse.Options{
+		Help: "drops the urls found but not fetched",
+	})
+
+	return exportCommand
+}
+
+func HandleExportCommand(exportCommand *argparse.Command) {
+	for _, command := range exportCommand.GetCommands() {
+		if command.Happened() {
+			if command.GetName() == "har" {
+				var file string
+				var outputFile string
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "file":
+						file = *arg.GetResult().(*string)
+					case "output":
+						outputFile = *arg.GetResult().(*string)
+					}
+				}
+
+				data, err := readScanFile(file)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				output := os.Stdout
+				if len(outputFile) > 0 {
+					if output, err = os.Create(outputFile); err != nil {
+						log.Fatal("could not create output file: ", err)
+					}
+					defer output.Close()
+				}
+
+				if err = har.Export(output, getHarCreator(), data); err != nil {
+					log.Fatal("could not export scan: ", err)
+				}
+			} else if command.GetName() == "graph" {
+				var file string
+				var outputFile string
+				var format string
+				options := graph.Options{}
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "file":
+						file = *arg.GetResult().(*string)
+					case "output":
+						outputFile = *arg.GetResult().(*string)
+					case "format":
+						format = *arg.GetResult().(*string)
+					case "collapse":
+						options.Collapse = graph.Collapse(*arg.GetResult().(*string))
+					case "fetched-only":
+						options.FetchedOnly = *arg.GetResult().(*bool)
+					}
+				}
+
+				data, err := readScanFile(file)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				output := os.Stdout
+				if len(outputFile) > 0 {
+					if output, err = os.Create(outputFile); err != nil {
+						log.Fatal("could not create output file: ", err)
+					}
+					defer output.Close()
+				}
+
+				if err = graph.Write(output, graph.Build(data, options), format); err != nil {
+					log.Fatal("could not export graph: ", err)
+				}
+			}
+		}
+	}
+}
+
+// returns the creator of the HAR files
+func getHarCreator() har.Creator {
+	return har.Creator{
+		Name:    har.CREATOR_NAME,
+		Version: getVersion(),
+	}
+}
+
+// returns the module version of the binary, "devel" if not built from a tagged version
+func getVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && len(info.Main.Version) > 0 && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
+}
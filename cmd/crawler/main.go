@@ -1,21 +1,25 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/akamensky/argparse"
+	"github.com/m1dugh/crawler/pkg/analyzers/secrets"
 	"github.com/m1dugh/crawler/pkg/config"
 	"github.com/m1dugh/crawler/pkg/crawler"
+	"github.com/m1dugh/crawler/pkg/har"
 	"github.com/m1dugh/crawler/pkg/plugin"
+	"github.com/m1dugh/crawler/pkg/store"
+	"github.com/m1dugh/crawler/pkg/warc"
 )
 
 const DB_FILE_NAME = ".go-crawler.db"
@@ -26,6 +30,14 @@ func main() {
 
 	configCommand := AddConfigCommand(parser)
 
+	pluginCommand := AddPluginCommand(parser)
+
+	reportCommand := AddReportCommand(parser)
+
+	exportCommand := AddExportCommand(parser)
+
+	warcCommand := AddWarcCommand(parser)
+
 	crawlCommand := parser.NewCommand("crawl", "crawls web pages following given arguments")
 
 	urls := crawlCommand.StringList("u", "url", &argparse.Options{
@@ -43,16 +55,17 @@ func main() {
 	})
 
 	scopeFileOptions := &argparse.Options{
-		Help: "the scope for the crawler",
+		Help: "the scope files for the crawler, merged together (\"@name\" for a scope stored in ROOT_FOLDER/scopes)",
 	}
 
 	if f, err := config.GetDefaultScopeFile(); err == nil {
-		scopeFileOptions.Default = f.Name()
+		scopeFileOptions.Default = []string{f.Name()}
+		f.Close()
 	} else {
 		scopeFileOptions.Required = true
 	}
 
-	scopeFile := crawlCommand.File("s", "scope", 0, 0, scopeFileOptions)
+	scopeFiles := crawlCommand.StringList("s", "scope", scopeFileOptions)
 
 	max_workers := crawlCommand.Int("t", "threads", &argparse.Options{
 		Required: false,
@@ -61,18 +74,37 @@ func main() {
 	})
 
 	dbFileStr := crawlCommand.String("", "resume", &argparse.Options{
-		Help:     "the data file of paused scan",
+		Help:     "the data file of paused scan, the scan is started if the file does not exist",
 		Required: false,
 	})
 
-	policy := crawlCommand.Selector("p", "policy", []string{
-		"AGGRESSIVE",
-		"A",
-		"MODERATE", "M",
-		"LIGHT", "L",
+	checkpoint := crawlCommand.String("", "checkpoint", &argparse.Options{
+		Help: "saves the scan to the --resume file (default: " + DB_FILE_NAME + ") or syncs the --store every duration (\"30s\") or number of fetched pages (\"500\")",
+	})
+
+	storeDir := crawlCommand.String("", "store", &argparse.Options{
+		Help: "the directory the scan is stored in instead of memory, the scan being resumed from it if it exists",
+	})
+
+	bloomErrorRate := crawlCommand.Float("", "bloom", &argparse.Options{
+		Help: "keeps the seen urls in a scalable bloom filter with the given false positive rate (0.001) instead of an exact set, bounding its memory",
+	})
+
+	saveFile := crawlCommand.String("", "save", &argparse.Options{
+		Help: "the file the data of the scan is saved to at the end of the crawl, for the report command",
+	})
+
+	policies := crawlCommand.StringList("p", "policy", &argparse.Options{
+		Default: []string{"MODERATE"},
+		Help:    "the level of scanning, one of " + strings.Join(BUILTIN_POLICIES, ", ") + " or <plugin>.<filter>, can be specified multiple times",
+	})
+
+	policyMode := crawlCommand.Selector("", "policy-mode", []string{
+		"all",
+		"any",
 	}, &argparse.Options{
-		Default: "MODERATE",
-		Help:    "the level of scanning",
+		Default: "all",
+		Help:    "whether an url must be accepted by all the policies or by any of them",
 	})
 
 	shouldFetchRobots := crawlCommand.Flag("", "robots", &argparse.Options{
@@ -80,13 +112,81 @@ func main() {
 		Default: false,
 	})
 
+	scanSecrets := crawlCommand.Flag("", "secrets", &argparse.Options{
+		Help: "scans the fetched pages for leaked api keys, tokens and private keys",
+	})
+
+	secretsRules := crawlCommand.String("", "secrets-rules", &argparse.Options{
+		Help: "the rule file merged with the default rules of --secrets (default: ROOT_FOLDER/secrets.yaml)",
+	})
+
+	secretsUnredacted := crawlCommand.Flag("", "secrets-unredacted", &argparse.Options{
+		Help: "stores the whole secrets found by --secrets in the findings of the scan instead of redacted ones",
+	})
+
+	fingerprint := crawlCommand.Flag("", "fingerprint", &argparse.Options{
+		Help: "detects the technologies (servers, frameworks, cms, libraries) of the fetched pages",
+	})
+
+	fingerprintDb := crawlCommand.String("", "fingerprint-db", &argparse.Options{
+		Help: "the technologies database merged with the default one of --fingerprint (default: ROOT_FOLDER/technologies.json)",
+	})
+
+	fingerprintOutput := crawlCommand.String("", "fingerprint-output", &argparse.Options{
+		Help: "the json file the technologies detected per domain are written to",
+	})
+
+	outputFile := crawlCommand.String("o", "output", &argparse.Options{
+		Help: "the file the output of the crawl is written to (default: stdout)",
+	})
+
+	outputFormat := crawlCommand.Selector("", "format", OUTPUT_FORMATS, &argparse.Options{
+		Default: "text",
+		Help:    "the format of the output, the urls found (text) or a json record per fetched page (jsonl)",
+	})
+
+	harFile := crawlCommand.String("", "har", &argparse.Options{
+		Help: "the HAR file the requests and responses are written to while crawling",
+	})
+
+	harBodySize := crawlCommand.Int("", "har-body-size", &argparse.Options{
+		Default: 0,
+		Help:    "the max size in bytes of the response bodies included in the --har file, 0 for none, -1 for no limit",
+	})
+
+	warcPrefix := crawlCommand.String("", "warc", &argparse.Options{
+		Help: "archives the requests and responses to the gzip compressed WARC files <prefix>-00000.warc.gz...",
+	})
+
+	warcMaxSize := crawlCommand.Int("", "warc-max-size", &argparse.Options{
+		Default: warc.DEFAULT_MAX_FILE_SIZE >> 20,
+		Help:    "the size in megabytes after which a new --warc file is started",
+	})
+
 	// arg parsing
-	if err := parser.Parse(os.Args); err != nil {
+	args, settings := ExtractSetSettings(os.Args)
+	if err := parser.Parse(args); err != nil {
 		log.Fatal("could not parse args: ", err)
 	}
 
 	if configCommand.Happened() {
-		HandleConfigCommand(configCommand)
+		HandleConfigCommand(configCommand, settings)
+
+		return
+	} else if pluginCommand.Happened() {
+		HandlePluginCommand(pluginCommand)
+
+		return
+	} else if reportCommand.Happened() {
+		HandleReportCommand(reportCommand)
+
+		return
+	} else if exportCommand.Happened() {
+		HandleExportCommand(exportCommand)
+
+		return
+	} else if warcCommand.Happened() {
+		HandleWarcCommand(warcCommand)
 
 		return
 	} else if crawlCommand.Happened() {
@@ -108,47 +208,71 @@ func main() {
 			}
 		}
 
-		switch *policy {
-		case "AGGRESSIVE", "A":
-			options.ShouldAddFilter = crawler.AggressiveShouldAddFilter
-		case "LIGHT", "L":
-			options.ShouldAddFilter = crawler.LightShouldAddFilter
-		default:
-			options.ShouldAddFilter = crawler.ModerateShouldAddFilter
+		crawlerPlugins := config.LoadPluginsFromConfig()
 
+		var secretsAnalyzer *secrets.Analyzer
+		if *scanSecrets {
+			rules, err := config.LoadSecretsRules(*secretsRules)
+			if err != nil {
+				log.Fatal("could not load secrets rules: ", err)
+			}
+			secretsAnalyzer = secrets.NewAnalyzer(rules)
+			secretsAnalyzer.Unredacted = *secretsUnredacted
+			crawlerPlugins[secrets.ANALYZER_NAME] = secretsAnalyzer.Plugin()
 		}
 
+		shouldAddFilter, err := GetPolicy(*policies, *policyMode, crawlerPlugins)
+		if err != nil {
+			log.Fatal("could not load policy: ", err)
+		}
+		options.ShouldAddFilter = shouldAddFilter
+
 		options.RequestRate = *requestRate
 
 		options.FetchRobots = *shouldFetchRobots
 
-		if _, err := scopeFile.Stat(); err != nil && errors.Is(err, os.ErrNotExist) {
-			log.Fatal("file does not exists: ", err)
+		if len(*checkpoint) > 0 {
+			options.CheckpointInterval, options.CheckpointPages, err = parseCheckpoint(*checkpoint)
+			if err != nil {
+				log.Fatal("could not parse checkpoint: ", err)
+			}
+
+			if len(*storeDir) == 0 {
+				options.CheckpointFile = DB_FILE_NAME
+				if len(*dbFileStr) > 0 {
+					options.CheckpointFile = *dbFileStr
+				}
+			}
 		}
 
-		body, err := io.ReadAll(scopeFile)
+		if len(*storeDir) > 0 && len(*dbFileStr) > 0 {
+			log.Fatal("--resume and --store cannot be used together")
+		}
 
-		if err != nil {
-			log.Fatal("could not parse scope: ", err)
+		var seen *crawler.SeenSet
+		if *bloomErrorRate != 0 {
+			if *bloomErrorRate < 0 || *bloomErrorRate >= 1 {
+				log.Fatal("the bloom filter false positive rate must be between 0 and 1: ", *bloomErrorRate)
+			}
+			seen = crawler.NewBloomSeenSet(0, *bloomErrorRate)
 		}
 
-		var scope crawler.Scope
+		scope, err := config.LoadScope(*scopeFiles...)
 
-		if err = json.Unmarshal(body, &scope); err != nil {
-			log.Fatal("could not unmarshall json file: ", err)
+		if err != nil {
+			log.Fatal("could not load scope: ", err)
 		}
 
-		cr := crawler.NewCrawler(&scope, options)
-		requests := make(chan []crawler.PageRequest, 10)
-		cr.OnUrlFound = requests
+		cr := crawler.NewCrawler(scope, options)
 
-		go func() {
-			for {
-				for _, u := range <-requests {
-					fmt.Println(u.ToUrl())
-				}
+		output := os.Stdout
+		if len(*outputFile) > 0 {
+			if output, err = os.Create(*outputFile); err != nil {
+				log.Fatal("could not create output file: ", err)
 			}
-		}()
+			defer output.Close()
+		}
+		waitOutput := writeCrawlOutput(cr, output, *outputFormat)
 
 		sigs := make(chan os.Signal, 1)
 		done := make(chan bool, 1)
@@ -162,22 +286,66 @@ func main() {
 
 		cr.OnEndRequested = done
 
-		cr.GetPluginsForDomain = GetOnPageResultAddedHanler(strings.Contains)
+		guards := GetPluginGuards(crawlerPlugins)
+		cr.GetHooksForDomain = GetHooksForDomainHandler(crawlerPlugins, guards)
+		cr.CrawlHooks = GetCrawlHooks(crawlerPlugins, guards)
+
+		if *fingerprint {
+			database, err := config.LoadTechnologies(*fingerprintDb)
+			if err != nil {
+				log.Fatal("could not load technologies database: ", err)
+			}
+			cr.PageAnalyzers = append(cr.PageAnalyzers, database.Analyzer())
+		}
+
+		var harWriter *har.Writer
+		if len(*harFile) > 0 {
+			file, err := os.Create(*harFile)
+			if err != nil {
+				log.Fatal("could not create har file: ", err)
+			}
+			defer file.Close()
 
-		var dbFile *os.File
+			if harWriter, err = har.NewWriter(file, getHarCreator(), *harBodySize); err != nil {
+				log.Fatal("could not write har file: ", err)
+			}
+			cr.PageAnalyzers = append(cr.PageAnalyzers, harWriter.Analyzer())
+		}
+
+		var warcWriter *warc.Writer
+		if len(*warcPrefix) > 0 {
+			if *warcMaxSize <= 0 {
+				log.Fatal("the max size of the warc files must be positive: ", *warcMaxSize)
+			}
+			if warcWriter, err = warc.NewWriter(*warcPrefix, int64(*warcMaxSize)<<20, "go-crawler/"+getVersion()); err != nil {
+				log.Fatal("could not create warc file: ", err)
+			}
+			cr.PageAnalyzers = append(cr.PageAnalyzers, warcWriter.Analyzer())
+		}
+
+		var diskStore *store.DiskStore
+		if len(*storeDir) > 0 {
+			if diskStore, err = store.OpenDiskStore(*storeDir, seen); err != nil {
+				log.Fatal("could not open store: ", err)
+			}
+			cr.SetStore(diskStore)
+		} else if seen != nil {
+			cr.SetStore(crawler.NewMemoryStore(&crawler.CrawlerData{Seen: seen}))
+		}
+
+		// the checkpoints of a previous run being resumed like a --resume file
+		resumeFile := *dbFileStr
+		if len(resumeFile) == 0 {
+			resumeFile = options.CheckpointFile
+		}
 
 		// if stopped scan file specified, start scan with given file and urls otherwise crawls with empty data
-		if dbFileStr != nil && len(*dbFileStr) > 0 {
-			fmt.Println("having resume file")
-			if dbFile, err = os.Open(*dbFileStr); err == nil {
-				var data crawler.CrawlerData
-				body, err = io.ReadAll(dbFile)
+		if len(resumeFile) > 0 {
+			if _, err = os.Stat(resumeFile); err == nil {
+				fmt.Fprintln(os.Stderr, "resuming scan from", resumeFile)
+				data, err := crawler.LoadData(resumeFile)
 				if err != nil {
-					log.Fatal("could not read db file: ", err)
-				}
-
-				if err = json.Unmarshal(body, &data); err != nil {
-					log.Fatal("could not unmarshall json file: ", err)
+					log.Fatal("could not load db file: ", err)
 				}
 
 				var requests []crawler.PageRequest = make([]crawler.PageRequest, len(*urls))
@@ -185,7 +353,14 @@ func main() {
 					requests[i] = crawler.PageRequestFromUrl(u)
 				}
 				data.UrlsToFetch = append(data.UrlsToFetch, requests...)
-				cr.ResumeScan(&data)
+				if seen != nil && !data.Seen.IsBloom() {
+					data.UseSeenSet(seen)
+				} else {
+					for _, request := range requests {
+						data.Seen.Add(request)
+					}
+				}
+				cr.ResumeScan(data)
 			} else {
 				cr.Crawl(*urls)
 			}
@@ -193,62 +368,213 @@ func main() {
 			cr.Crawl(*urls)
 		}
 
-		if !cr.IsDone() {
+		waitOutput()
+
+		// the errors of the outputs being reported once the scan has been saved
+		exitCode := 0
+
+		if harWriter != nil {
+			if err = harWriter.Close(); err != nil {
+				log.Println("could not write har file:", err)
+				exitCode = 1
+			}
+		}
+
+		if warcWriter != nil {
+			if err = warcWriter.Close(); err != nil {
+				log.Println("could not write warc file:", err)
+				exitCode = 1
+			}
+			fmt.Fprintln(os.Stderr, "warc files written:", strings.Join(warcWriter.Files(), ", "))
+		}
+
+		for _, p := range crawlerPlugins {
+			p.Close()
+		}
+
+		printPluginsReport(guards)
+
+		if secretsAnalyzer != nil {
+			secretsAnalyzer.PrintSummary(os.Stderr)
+		}
+
+		if *fingerprint {
+			technologies, err := getCrawlTechnologies(cr)
+			if err != nil {
+				log.Println("could not read technologies:", err)
+				exitCode = 1
+			}
+			fmt.Fprintln(os.Stderr, "technologies report:")
+			printTechnologies(os.Stderr, technologies)
+
+			if len(*fingerprintOutput) > 0 {
+				file, err := os.Create(*fingerprintOutput)
+				if err == nil {
+					err = writeTechnologiesJson(file, technologies)
+					file.Close()
+				}
+				if err != nil {
+					log.Println("could not write technologies:", err)
+					exitCode = 1
+				}
+			}
+		}
+
+		if len(*saveFile) > 0 {
+			if err = cr.Save(*saveFile); err != nil {
+				log.Fatal("could not save scan: ", err)
+			}
+		}
+
+		if diskStore != nil {
+			if err = diskStore.Close(); err != nil {
+				log.Fatal("could not close store: ", err)
+			}
+			if !cr.IsDone() {
+				fmt.Fprintln(os.Stderr, "stop requested, current scan saved in", *storeDir)
+			}
+		} else if !cr.IsDone() {
 			var fileName string
 			if len(*dbFileStr) > 0 {
 				fileName = *dbFileStr
 			} else {
 				fileName = DB_FILE_NAME
 			}
-			fmt.Println("stop requested, saving current scan to", fileName)
-			var body []byte
-			body, err = json.Marshal(cr.GetData())
-			if err != nil || os.WriteFile(fileName, body, 0644) != nil {
-				log.Fatal("could not save scan")
+			fmt.Fprintln(os.Stderr, "stop requested, saving current scan to", fileName)
+			if err = cr.Save(fileName); err != nil {
+				log.Fatal("could not save scan: ", err)
 			}
-			fmt.Println("successfully saved current scan at", fileName)
+			fmt.Fprintln(os.Stderr, "successfully saved current scan at", fileName)
+		} else if len(options.CheckpointFile) > 0 {
+			// removing db file if scan is done
+			os.Remove(options.CheckpointFile)
 		} else if dbFileStr != nil && len(*dbFileStr) > 0 {
 			// removing db file if scan is done
 			os.Remove(*dbFileStr)
 
 		}
+
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
 	}
 
 }
 
-// params:
-//  - validateDomainName:
-//		a function taking string to be checked in forst argument and string to check against in second argument
-func GetOnPageResultAddedHanler(validateDomainName func(string, string) bool) func(string) []plugin.OnPageResultAdded {
-	var res func(string) []plugin.OnPageResultAdded
+// parses the value of --checkpoint, either a duration ("30s", "5m") or a number of pages ("500")
+func parseCheckpoint(value string) (time.Duration, int, error) {
+	if pages, err := strconv.Atoi(value); err == nil {
+		if pages <= 0 {
+			return 0, 0, fmt.Errorf("the number of pages must be positive: %d", pages)
+		}
+		return 0, pages, nil
+	}
 
-	crawlerPlugins := config.LoadPluginsFromConfig()
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s is neither a duration nor a number of pages", value)
+	}
+	if interval <= 0 {
+		return 0, 0, fmt.Errorf("the duration must be positive: %s", value)
+	}
 
-	res = func(domainName string) []plugin.OnPageResultAdded {
-		res := make([]plugin.OnPageResultAdded, 0)
-		for pluginName, p := range crawlerPlugins {
-			for _, entry := range p.Entries {
-				if validateDomainName(domainName, entry.DomainName) {
-					// function wrapping
-					var function plugin.OnPageResultAdded = func(body []byte, pageResult crawler.PageResult, domainResult crawler.DomainResultEntry) plugin.Attachements {
-						attachements := (*entry.OnPageResultAdded)(body, pageResult, domainResult)
+	return interval, 0, nil
+}
+
+// wraps handler to prefix the attachements keys with the name of the plugin
+func prefixAttachements(pluginName string, handler plugin.OnPageResultAdded) plugin.OnPageResultAdded {
+	return func(body []byte, pageResult crawler.PageResult, domainResult crawler.DomainResultEntry) plugin.Attachements {
+		attachements := handler(body, pageResult, domainResult)
+
+		result := make(plugin.Attachements, len(attachements))
+		for name, value := range attachements {
+			newName := pluginName + "." + name
+			result[newName] = value
+		}
+
+		return result
+	}
+}
+
+// wraps handler to set the source of the findings without one to the name of the plugin
+func setFindingsSource(pluginName string, handler plugin.AnalyzePage) plugin.AnalyzePage {
+	return func(body []byte, pageResult crawler.PageResult) []crawler.Finding {
+		findings := handler(body, pageResult)
+		for i := range findings {
+			if len(findings[i].Source) == 0 {
+				findings[i].Source = pluginName
+			}
+		}
+		return findings
+	}
+}
+
+// returns a guard isolating the hooks of each plugin, configured from config.yaml
+func GetPluginGuards(crawlerPlugins map[string]*plugin.CrawlerPlugin) map[string]*plugin.PluginGuard {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	guards := make(map[string]*plugin.PluginGuard, len(crawlerPlugins))
+	for name, p := range crawlerPlugins {
+		var options *plugin.GuardOptions
+		if pluginConfig := cfg.GetPlugin(name); pluginConfig != nil {
+			options = pluginConfig.GetGuardOptions()
+		}
+		guards[name] = plugin.NewPluginGuard(name, options)
+		p.SetErrorHandler(guards[name].Fail)
+	}
+
+	return guards
+}
+
+func printPluginsReport(guards map[string]*plugin.PluginGuard) {
+	if len(guards) == 0 {
+		return
+	}
 
-						result := make(plugin.Attachements, len(attachements))
-						for name, value := range attachements {
-							newName := pluginName + "." + name
-							result[newName] = value
-						}
+	names := make([]string, 0, len(guards))
+	for name := range guards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-						return result
+	fmt.Fprintln(os.Stderr, "plugins report:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%s: %s\n", name, guards[name].GetStats())
+	}
+}
 
+// returns a function returning the hooks of the plugin entries matching a domain name
+func GetHooksForDomainHandler(crawlerPlugins map[string]*plugin.CrawlerPlugin, guards map[string]*plugin.PluginGuard) func(string) []crawler.DomainHooks {
+	return func(domainName string) []crawler.DomainHooks {
+		res := make([]crawler.DomainHooks, 0)
+		for pluginName, p := range crawlerPlugins {
+			for _, entry := range p.Entries {
+				if entry.MatchesDomain(domainName) {
+					hooks := entry.GetHooks()
+					if hooks.OnPageResultAdded != nil {
+						hooks.OnPageResultAdded = prefixAttachements(pluginName, hooks.OnPageResultAdded)
 					}
-					res = append(res, function)
+					if hooks.AnalyzePage != nil {
+						hooks.AnalyzePage = setFindingsSource(pluginName, hooks.AnalyzePage)
+					}
+					res = append(res, guards[pluginName].WrapDomainHooks(hooks))
 				}
 			}
 		}
 
 		return res
 	}
+}
+
+// returns the OnStart and OnFinish hooks of the plugins
+func GetCrawlHooks(crawlerPlugins map[string]*plugin.CrawlerPlugin, guards map[string]*plugin.PluginGuard) []crawler.CrawlHooks {
+	res := make([]crawler.CrawlHooks, 0, len(crawlerPlugins))
+	for name, p := range crawlerPlugins {
+		res = append(res, guards[name].WrapCrawlHooks(p.GetCrawlHooks()))
+	}
 
 	return res
 }
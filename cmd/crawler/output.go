@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/m1dugh/crawler/pkg/crawler"
+)
+
+// the formats of the output of the crawl command
+var OUTPUT_FORMATS = []string{
+	"text",
+	"jsonl",
+}
+
+// a line of the jsonl output, one per fetched page
+type pageRecord struct {
+	Url           string               `json:"url"`
+	Method        string               `json:"method"`
+	StatusCode    int                  `json:"status"`
+	ContentType   string               `json:"content_type"`
+	ContentLength int64                `json:"length"`
+	Depth         int                  `json:"depth"`
+	Parent        string               `json:"parent,omitempty"`
+	Time          time.Time            `json:"time"`
+	DurationMs    float64              `json:"duration_ms"`
+	Attachements  crawler.Attachements `json:"attachements,omitempty"`
+	Technologies  []crawler.Technology `json:"technologies,omitempty"`
+	Findings      []crawler.Finding    `json:"findings,omitempty"`
+}
+
+func newPageRecord(event crawler.Event) pageRecord {
+	pageResult := event.PageResult
+
+	// the event being emitted once the analyzers and plugins have run on the page
+	startTime := event.Time.Add(-event.Duration)
+	if pageResult.Timings != nil {
+		startTime = pageResult.Timings.Start
+	}
+
+	return pageRecord{
+		Url:           pageResult.Url.ToUrl(),
+		Method:        pageResult.Url.GetMethod(),
+		StatusCode:    pageResult.StatusCode,
+		ContentType:   pageResult.ContentType(),
+		ContentLength: pageResult.ContentLength,
+		Depth:         pageResult.Url.Depth,
+		Parent:        pageResult.Url.Parent,
+		Time:          startTime,
+		DurationMs:    float64(event.Duration.Microseconds()) / 1000,
+		Attachements:  event.Attachements,
+		Technologies:  pageResult.Technologies,
+		Findings:      pageResult.Findings,
+	}
+}
+
+// writes the output of the crawl to output in the given format, returns a
+// function to call once the crawl has returned, waiting for the output to be written
+func writeCrawlOutput(cr *crawler.Crawler, output io.Writer, format string) func() {
+	writer := bufio.NewWriter(output)
+	done := make(chan bool)
+
+	switch format {
+	case "jsonl":
+		subscription := cr.Subscribe(&crawler.SubscriptionOptions{
+			BufferSize: crawler.DEFAULT_SUBSCRIPTION_BUFFER_SIZE,
+			Overflow:   crawler.OVERFLOW_BLOCK,
+			Types:      []crawler.EventType{crawler.EVENT_RESPONSE_RECEIVED, crawler.EVENT_CRAWL_FINISHED},
+		})
+
+		go func() {
+			encoder := json.NewEncoder(writer)
+			for event := range subscription.Events {
+				if event.Type == crawler.EVENT_CRAWL_FINISHED {
+					break
+				}
+
+				if err := encoder.Encode(newPageRecord(event)); err != nil {
+					log.Println("could not write page record:", err)
+				}
+
+				// flushed on every record for the output to be read while crawling
+				writer.Flush()
+			}
+			cr.Unsubscribe(subscription)
+			done <- true
+		}()
+	default:
+		urls := make(chan []crawler.PageRequest, 10)
+		cr.OnUrlFound = urls
+
+		go func() {
+			for addedUrls := range urls {
+				for _, u := range addedUrls {
+					fmt.Fprintln(writer, u.ToUrl())
+				}
+				writer.Flush()
+			}
+			done <- true
+		}()
+
+		return func() {
+			// no url being found once the crawl has returned
+			close(urls)
+			<-done
+		}
+	}
+
+	return func() {
+		<-done
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/template"
+
+	"github.com/akamensky/argparse"
+	"github.com/m1dugh/crawler/pkg/config"
+	"github.com/m1dugh/crawler/pkg/plugin"
+)
+
+// the module plugins are built against
+const CRAWLER_MODULE = "github.com/m1dugh/crawler"
+
+const PLUGIN_TEMPLATES_DIR = "templates/plugin"
+
+// the extension stripped from the template files when generating a plugin
+const TEMPLATE_EXTENSION = ".tmpl"
+
+//go:embed templates/plugin
+var pluginTemplates embed.FS
+
+type pluginTemplateData struct {
+	Name   string
+	Module string
+
+	GoVersion string
+	Toolchain string
+
+	CrawlerModule  string
+	CrawlerVersion string
+	CrawlerReplace string
+}
+
+func AddPluginCommand(parser *argparse.Parser) *argparse.Command {
+
+	pluginCommand := parser.NewCommand("plugin", "creates and builds go plugins")
+
+	newCommand := pluginCommand.NewCommand("new", "generates a plugin module ready to be built")
+
+	newCommand.String("n", "name", &argparse.Options{
+		Required: true,
+		Help:     "the name of the plugin",
+	})
+
+	newCommand.String("d", "dir", &argparse.Options{
+		Help: "the directory of the generated module (default: ./<name>)",
+	})
+
+	newCommand.String("m", "module", &argparse.Options{
+		Help: "the module path of the plugin (default: <name>)",
+	})
+
+	newCommand.String("", "replace", &argparse.Options{
+		Help: "the path to a local copy of the crawler sources the plugin is built against",
+	})
+
+	buildCommand := pluginCommand.NewCommand("build", "builds a plugin module and registers it in the config")
+
+	buildCommand.String("d", "dir", &argparse.Options{
+		Default: ".",
+		Help:    "the directory of the plugin module",
+	})
+
+	buildCommand.String("t", "tag", &argparse.Options{
+		Help: "the name of the plugin in the config (default: the name of the directory)",
+	})
+
+	buildCommand.String("o", "output", &argparse.Options{
+		Help: "the path of the built plugin (default: ROOT_FOLDER/plugins/<tag>.so)",
+	})
+
+	buildCommand.Flag("", "no-register", &argparse.Options{
+		Help: "if specified, the plugin is not added to the config",
+	})
+
+	return pluginCommand
+}
+
+func HandlePluginCommand(pluginCommand *argparse.Command) {
+	for _, command := range pluginCommand.GetCommands() {
+		if command.Happened() {
+			if command.GetName() == "new" {
+				var name, dir, module, replace string
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "name":
+						name = *arg.GetResult().(*string)
+					case "dir":
+						dir = *arg.GetResult().(*string)
+					case "module":
+						module = *arg.GetResult().(*string)
+					case "replace":
+						replace = *arg.GetResult().(*string)
+					}
+				}
+
+				handleNewPluginCommand(name, dir, module, replace)
+			} else if command.GetName() == "build" {
+				var dir, tag, output string
+				var noRegister bool
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "dir":
+						dir = *arg.GetResult().(*string)
+					case "tag":
+						tag = *arg.GetResult().(*string)
+					case "output":
+						output = *arg.GetResult().(*string)
+					case "no-register":
+						noRegister = *arg.GetResult().(*bool)
+					}
+				}
+
+				handleBuildPluginCommand(dir, tag, output, noRegister)
+			}
+		}
+	}
+}
+
+// returns the version of the crawler module the running binary was built from,
+// empty if built from a local checkout
+func getCrawlerVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	if info.Main.Path == CRAWLER_MODULE {
+		if info.Main.Version == "(devel)" || strings.HasSuffix(info.Main.Version, "+dirty") {
+			return ""
+		}
+		return info.Main.Version
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == CRAWLER_MODULE {
+			return dep.Version
+		}
+	}
+
+	return ""
+}
+
+// returns the "major.minor" part of a go version such as "go1.21.3"
+func getGoVersion(version string) string {
+	parts := strings.SplitN(strings.TrimPrefix(version, "go"), ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// returns true if goVersion ("major.minor") accepts the toolchain directive
+// in go.mod, which was added in go 1.21
+func supportsToolchainDirective(goVersion string) bool {
+	var major, minor int
+	if _, err := fmt.Sscanf(goVersion, "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+	return major > 1 || (major == 1 && minor >= 21)
+}
+
+// returns true if the running binary was built with -trimpath,
+// plugins must then be built with the same flag
+func isTrimmedBuild() bool {
+	_, file, _, ok := runtime.Caller(0)
+	return ok && !filepath.IsAbs(file)
+}
+
+func handleNewPluginCommand(name string, dir string, module string, replace string) {
+	if len(dir) == 0 {
+		dir = name
+	}
+	if len(module) == 0 {
+		module = name
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		log.Fatalf("could not create plugin: %s is not empty", dir)
+	}
+
+	data := pluginTemplateData{
+		Name:           name,
+		Module:         module,
+		GoVersion:      getGoVersion(runtime.Version()),
+		CrawlerModule:  CRAWLER_MODULE,
+		CrawlerVersion: getCrawlerVersion(),
+	}
+
+	if len(data.GoVersion) == 0 {
+		data.GoVersion = "1.17"
+	} else if supportsToolchainDirective(data.GoVersion) {
+		data.Toolchain = runtime.Version()
+	}
+
+	if len(replace) > 0 {
+		absolutePath, err := filepath.Abs(replace)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data.CrawlerReplace = absolutePath
+		if len(data.CrawlerVersion) == 0 {
+			data.CrawlerVersion = "v0.0.0"
+		}
+	} else if len(data.CrawlerVersion) == 0 {
+		log.Printf("the version of the crawler is unknown, the latest one will be used: use --replace to build against local sources\n")
+	}
+
+	err := fs.WalkDir(pluginTemplates, PLUGIN_TEMPLATES_DIR, func(templatePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath := strings.TrimPrefix(templatePath, PLUGIN_TEMPLATES_DIR)
+		destination := filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(relativePath, TEMPLATE_EXTENSION)))
+
+		if entry.IsDir() {
+			return os.MkdirAll(destination, 0755)
+		}
+
+		tmpl, err := template.ParseFS(pluginTemplates, templatePath)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Create(destination)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		return tmpl.Execute(file, data)
+	})
+
+	if err != nil {
+		log.Fatal("could not create plugin: ", err)
+	}
+
+	fmt.Printf("created plugin %s in %s\n", name, dir)
+	fmt.Printf("run `go test ./...` in %s to feed the fixtures of testdata to the plugin, and `crawler plugin build -d %s` to build it\n", dir, dir)
+}
+
+// runs the go command in dir, forwarding its output
+func runGoCommand(dir string, args ...string) error {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=1")
+	return cmd.Run()
+}
+
+// returns the output of the go command run in dir
+func getGoCommandOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// returns an error if the plugin module in dir would not be built with
+// the toolchain and crawler version of the running binary
+func checkPluginToolchain(dir string) error {
+	if _, err := exec.LookPath("go"); err != nil {
+		return fmt.Errorf("go toolchain not found: %s", err)
+	}
+
+	goVersion, err := getGoCommandOutput(dir, "env", "GOVERSION")
+	if err != nil {
+		return fmt.Errorf("could not get go version: %s", err)
+	}
+
+	if goVersion != runtime.Version() {
+		return fmt.Errorf("the plugin would be built with %s while the crawler was built with %s", goVersion, runtime.Version())
+	}
+
+	crawlerVersion := getCrawlerVersion()
+	if len(crawlerVersion) == 0 {
+		return nil
+	}
+
+	moduleVersion, err := getGoCommandOutput(dir, "list", "-m", "-f", "{{.Version}}|{{with .Replace}}{{.Version}}|{{.Dir}}{{end}}", CRAWLER_MODULE)
+	if err != nil {
+		return fmt.Errorf("could not get the version of %s required by the plugin: %s", CRAWLER_MODULE, err)
+	}
+
+	// version|replace version|replace dir, the replace version being empty for a local directory
+	parts := strings.SplitN(moduleVersion, "|", 3)
+	switch {
+	case len(parts) < 3 || len(parts[1]) == 0 && len(parts[2]) == 0:
+		if parts[0] != crawlerVersion {
+			return fmt.Errorf("the plugin requires %s@%s while the crawler was built from %s", CRAWLER_MODULE, parts[0], crawlerVersion)
+		}
+	case len(parts[1]) > 0:
+		if parts[1] != crawlerVersion {
+			return fmt.Errorf("the plugin replaces %s with version %s while the crawler was built from %s", CRAWLER_MODULE, parts[1], crawlerVersion)
+		}
+	default:
+		if sourcesVersion := getSourcesVersion(parts[2]); sourcesVersion != crawlerVersion {
+			if len(sourcesVersion) == 0 {
+				sourcesVersion = "an untagged or modified checkout"
+			}
+			return fmt.Errorf("the plugin replaces %s with the sources in %s, at %s, while the crawler was built from %s: remove the replace directive or check out %s", CRAWLER_MODULE, parts[2], sourcesVersion, crawlerVersion, crawlerVersion)
+		}
+	}
+
+	return nil
+}
+
+// returns the tag of the git checkout in dir, empty if its HEAD is not tagged,
+// it has uncommitted changes or it is not a git checkout
+func getSourcesVersion(dir string) string {
+	cmd := exec.Command("git", "describe", "--tags", "--exact-match", "--dirty=+dirty")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	version := strings.TrimSpace(string(output))
+	if err != nil || strings.HasSuffix(version, "+dirty") {
+		return ""
+	}
+	return version
+}
+
+func handleBuildPluginCommand(dir string, tag string, output string, noRegister bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(tag) == 0 {
+		tag = filepath.Base(dir)
+	}
+
+	if len(output) == 0 {
+		output = path.Join(config.PLUGIN_PATH, tag+".so")
+	}
+
+	output, err = filepath.Abs(output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "go.sum")); err != nil {
+		if err = runGoCommand(dir, "mod", "tidy"); err != nil {
+			log.Fatal("could not resolve the dependencies of the plugin: ", err)
+		}
+	}
+
+	if err = checkPluginToolchain(dir); err != nil {
+		log.Fatal("could not build plugin: ", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	args := []string{"build", "-buildmode=plugin"}
+	if isTrimmedBuild() {
+		args = append(args, "-trimpath")
+	}
+	args = append(args, "-o", output, ".")
+
+	if err = runGoCommand(dir, args...); err != nil {
+		log.Fatal("could not build plugin: ", err)
+	}
+
+	crawlerPlugin, err := plugin.GetCrawlerPlugin(output)
+	if err != nil {
+		fmt.Printf("built %s but the crawler could not load it:\n", output)
+		printLoadError(err)
+		os.Exit(1)
+	}
+	crawlerPlugin.Close()
+
+	fmt.Println("built", output)
+
+	if noRegister {
+		return
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if pluginConfig := cfg.GetPlugin(tag); pluginConfig != nil {
+		pluginConfig.Path = output
+		pluginConfig.Kind = ""
+		pluginConfig.Args = nil
+	} else {
+		cfg.Plugins = append(cfg.Plugins, &config.PluginConfig{
+			Name:   tag,
+			Path:   output,
+			Active: true,
+		})
+	}
+
+	if config.SaveConfig(cfg) {
+		fmt.Println("successfully registered", tag, "in config")
+	} else {
+		log.Fatal("could not save config")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/m1dugh/crawler/pkg/crawler"
+	"github.com/m1dugh/crawler/pkg/plugin"
+)
+
+// the names of the policies shipped with the crawler
+var BUILTIN_POLICIES = []string{
+	"AGGRESSIVE",
+	"MODERATE",
+	"LIGHT",
+	"SMART",
+	"SIMILARITY",
+}
+
+func getBuiltinPolicy(name string) (crawler.ShouldAddFilter, bool) {
+	switch strings.ToUpper(name) {
+	case "AGGRESSIVE", "A":
+		return crawler.AggressiveShouldAddFilter, true
+	case "MODERATE", "M":
+		return crawler.ModerateShouldAddFilter, true
+	case "LIGHT", "L":
+		return crawler.LightShouldAddFilter, true
+	case "SMART", "S":
+		return crawler.SmartShouldAddFilter, true
+	case "SIMILARITY":
+		return crawler.SimilarityShouldAddFilter, true
+	}
+
+	return nil, false
+}
+
+// returns the ShouldAddFilter named name, either a built-in policy
+// or a filter exported by a loaded plugin as "<plugin>.<filter>"
+func GetShouldAddFilter(name string, crawlerPlugins map[string]*plugin.CrawlerPlugin) (crawler.ShouldAddFilter, error) {
+
+	if filter, ok := getBuiltinPolicy(name); ok {
+		return filter, nil
+	}
+
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("unknown policy %s", name)
+	}
+
+	p, ok := crawlerPlugins[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("plugin %s is not loaded", parts[0])
+	}
+
+	filter, ok := p.Filters[parts[1]]
+	if !ok || filter == nil || *filter == nil {
+		return nil, fmt.Errorf("plugin %s has no filter %s", parts[0], parts[1])
+	}
+
+	return *filter, nil
+}
+
+// returns the ShouldAddFilter composing all the given policies,
+// mode being either "all" or "any"
+func GetPolicy(names []string, mode string, crawlerPlugins map[string]*plugin.CrawlerPlugin) (crawler.ShouldAddFilter, error) {
+
+	filters := make([]crawler.ShouldAddFilter, 0, len(names))
+	for _, name := range names {
+		filter, err := GetShouldAddFilter(name, crawlerPlugins)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	switch len(filters) {
+	case 0:
+		return crawler.DEFAULT_SHOULD_ADD_FILTER, nil
+	case 1:
+		return filters[0], nil
+	}
+
+	if mode == "any" {
+		return crawler.AnyShouldAddFilter(filters...), nil
+	}
+
+	return crawler.AllShouldAddFilter(filters...), nil
+}
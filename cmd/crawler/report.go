@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/akamensky/argparse"
+	"github.com/m1dugh/crawler/pkg/analyzers/headers"
+	"github.com/m1dugh/crawler/pkg/crawler"
+	"github.com/m1dugh/crawler/pkg/store"
+)
+
+func AddReportCommand(parser *argparse.Parser) *argparse.Command {
+
+	reportCommand := parser.NewCommand("report", "prints reports of a saved scan")
+
+	technologiesCommand := reportCommand.NewCommand("technologies", "prints the technologies detected per domain by crawl --fingerprint")
+
+	technologiesCommand.String("f", "file", &argparse.Options{
+		Required: true,
+		Help:     "the db file of the scan (crawl --save) or a crawl --store directory",
+	})
+
+	technologiesCommand.Flag("", "json", &argparse.Options{
+		Help: "prints the report as json",
+	})
+
+	findingsCommand := reportCommand.NewCommand("findings", "prints the findings of the plugins and analyzers per page")
+
+	findingsCommand.String("f", "file", &argparse.Options{
+		Required: true,
+		Help:     "the db file of the scan (crawl --save) or a crawl --store directory",
+	})
+
+	findingsCommand.Flag("", "json", &argparse.Options{
+		Help: "prints the report as json",
+	})
+
+	findingsCommand.Selector("", "min-severity", []string{
+		string(crawler.SEVERITY_INFO),
+		string(crawler.SEVERITY_LOW),
+		string(crawler.SEVERITY_MEDIUM),
+		string(crawler.SEVERITY_HIGH),
+	}, &argparse.Options{
+		Default: string(crawler.SEVERITY_INFO),
+		Help:    "the min severity of the printed findings",
+	})
+
+	headersCommand := reportCommand.NewCommand("headers", "audits the security headers and cookie flags of the responses per domain")
+
+	headersCommand.String("f", "file", &argparse.Options{
+		Required: true,
+		Help:     "the db file of the scan (crawl --save) or a crawl --store directory",
+	})
+
+	headersCommand.Flag("", "json", &argparse.Options{
+		Help: "prints the report as json",
+	})
+
+	headersCommand.Selector("", "min-severity", []string{
+		string(headers.SEVERITY_INFO),
+		string(headers.SEVERITY_LOW),
+		string(headers.SEVERITY_MEDIUM),
+		string(headers.SEVERITY_HIGH),
+	}, &argparse.Options{
+		Default: string(headers.SEVERITY_INFO),
+		Help:    "the min severity of the printed findings",
+	})
+
+	return reportCommand
+}
+
+func HandleReportCommand(reportCommand *argparse.Command) {
+	for _, command := range reportCommand.GetCommands() {
+		if command.Happened() {
+			if command.GetName() == "findings" {
+				var file string
+				var jsonFlag bool
+				var minSeverity string
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "file":
+						file = *arg.GetResult().(*string)
+					case "json":
+						jsonFlag = *arg.GetResult().(*bool)
+					case "min-severity":
+						minSeverity = *arg.GetResult().(*string)
+					}
+				}
+
+				data, err := readScanFile(file)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				severity, _ := crawler.ParseSeverity(minSeverity)
+				findings := getPageFindings(data, severity)
+
+				if jsonFlag {
+					encoder := json.NewEncoder(os.Stdout)
+					encoder.SetIndent("", "  ")
+					err = encoder.Encode(findings)
+				} else {
+					printPageFindings(os.Stdout, findings)
+				}
+
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else if command.GetName() == "headers" {
+				var file string
+				var jsonFlag bool
+				var minSeverity string
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "file":
+						file = *arg.GetResult().(*string)
+					case "json":
+						jsonFlag = *arg.GetResult().(*bool)
+					case "min-severity":
+						minSeverity = *arg.GetResult().(*string)
+					}
+				}
+
+				data, err := readScanFile(file)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				severity, _ := headers.ParseSeverity(minSeverity)
+				findings := getHeadersFindings(data, severity)
+
+				if jsonFlag {
+					encoder := json.NewEncoder(os.Stdout)
+					encoder.SetIndent("", "  ")
+					err = encoder.Encode(findings)
+				} else {
+					printHeadersFindings(os.Stdout, findings)
+				}
+
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else if command.GetName() == "technologies" {
+				var file string
+				var jsonFlag bool
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "file":
+						file = *arg.GetResult().(*string)
+					case "json":
+						jsonFlag = *arg.GetResult().(*bool)
+					}
+				}
+
+				data, err := readScanFile(file)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				if jsonFlag {
+					err = writeTechnologiesJson(os.Stdout, getTechnologiesPerDomain(data))
+				} else {
+					printTechnologies(os.Stdout, getTechnologiesPerDomain(data))
+				}
+
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+// reads the data of a scan saved by crawl
+func readScanFile(path string) (*crawler.CrawlerData, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return readStore(path)
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read scan file: %s", err)
+	}
+
+	var data crawler.CrawlerData
+	if err = json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("could not unmarshall scan file: %s", err)
+	}
+
+	return &data, nil
+}
+
+// reads the data of a crawl --store directory, which may still be crawling
+func readStore(dir string) (*crawler.CrawlerData, error) {
+	diskStore, err := store.OpenDiskStoreReadOnly(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not open store: %s", err)
+	}
+	defer diskStore.Close()
+
+	data, err := diskStore.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("could not read store: %s", err)
+	}
+
+	return &data, nil
+}
+
+// returns the technologies detected per domain
+func getTechnologiesPerDomain(data *crawler.CrawlerData) map[string][]crawler.Technology {
+	result := make(map[string][]crawler.Technology, len(data.FetchedUrls))
+	for domainName := range data.FetchedUrls {
+		if technologies := data.FetchedUrls.GetTechnologies(domainName); len(technologies) > 0 {
+			result[domainName] = technologies
+		}
+	}
+	return result
+}
+
+// returns the technologies detected per domain by the crawl, its results being read one endpoint at a time
+func getCrawlTechnologies(cr *crawler.Crawler) (map[string][]crawler.Technology, error) {
+	technologies := make(map[string][][]crawler.Technology)
+	err := cr.ForEachEntry(func(domainName string, baseUrl string, entry crawler.DomainResultEntry) error {
+		for _, pageResult := range entry.PageResults {
+			technologies[domainName] = append(technologies[domainName], pageResult.Technologies)
+		}
+		return nil
+	})
+
+	result := make(map[string][]crawler.Technology, len(technologies))
+	for domainName, domainTechnologies := range technologies {
+		if merged := crawler.MergeTechnologies(domainTechnologies...); len(merged) > 0 {
+			result[domainName] = merged
+		}
+	}
+	return result, err
+}
+
+// returns the findings per page url whose severity is at least minSeverity
+func getPageFindings(data *crawler.CrawlerData, minSeverity crawler.Severity) map[string][]crawler.Finding {
+	result := make(map[string][]crawler.Finding)
+	for _, domainResults := range data.FetchedUrls {
+		for _, entry := range domainResults {
+			for _, pageResult := range entry.PageResults {
+				for _, finding := range pageResult.Findings {
+					if finding.Severity.Rank() >= minSeverity.Rank() {
+						url := pageResult.Url.ToUrl()
+						result[url] = append(result[url], finding)
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+func printPageFindings(w io.Writer, findingsPerPage map[string][]crawler.Finding) {
+	urls := make([]string, 0, len(findingsPerPage))
+	for url := range findingsPerPage {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	if len(urls) == 0 {
+		fmt.Fprintln(w, "no finding")
+		return
+	}
+
+	for _, url := range urls {
+		fmt.Fprintln(w, url)
+		for _, finding := range findingsPerPage[url] {
+			line := fmt.Sprintf("\t[%s] %s", finding.Severity, finding.Type)
+			if len(finding.Source) > 0 {
+				line += " (" + finding.Source + ")"
+			}
+			line += ": " + finding.Message
+			if len(finding.Location) > 0 {
+				line += " at " + finding.Location
+			}
+			fmt.Fprintln(w, line)
+			if len(finding.Evidence) > 0 {
+				fmt.Fprintf(w, "\t\t%s\n", finding.Evidence)
+			}
+		}
+	}
+}
+
+// returns the security header findings per domain whose severity is at least minSeverity
+func getHeadersFindings(data *crawler.CrawlerData, minSeverity headers.Severity) map[string][]headers.Finding {
+	result := make(map[string][]headers.Finding)
+	for domainName, findings := range headers.AuditFetchedUrls(data.FetchedUrls) {
+		filtered := make([]headers.Finding, 0, len(findings))
+		for _, finding := range findings {
+			if finding.Severity.Rank() >= minSeverity.Rank() {
+				filtered = append(filtered, finding)
+			}
+		}
+
+		if len(filtered) > 0 {
+			result[domainName] = filtered
+		}
+	}
+	return result
+}
+
+func printHeadersFindings(w io.Writer, findingsPerDomain map[string][]headers.Finding) {
+	domains := make([]string, 0, len(findingsPerDomain))
+	for domainName := range findingsPerDomain {
+		domains = append(domains, domainName)
+	}
+	sort.Strings(domains)
+
+	if len(domains) == 0 {
+		fmt.Fprintln(w, "no finding")
+		return
+	}
+
+	for _, domainName := range domains {
+		fmt.Fprintln(w, domainName)
+		for _, finding := range findingsPerDomain[domainName] {
+			fmt.Fprintf(w, "\t[%s] %s: %s (%d page(s))\n", finding.Severity, finding.Check, finding.Message, finding.Count)
+			for _, url := range finding.Urls {
+				fmt.Fprintf(w, "\t\t%s\n", url)
+			}
+		}
+	}
+}
+
+func writeTechnologiesJson(w io.Writer, technologiesPerDomain map[string][]crawler.Technology) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(technologiesPerDomain)
+}
+
+func printTechnologies(w io.Writer, technologiesPerDomain map[string][]crawler.Technology) {
+	domains := make([]string, 0, len(technologiesPerDomain))
+	for domainName := range technologiesPerDomain {
+		domains = append(domains, domainName)
+	}
+	sort.Strings(domains)
+
+	if len(domains) == 0 {
+		fmt.Fprintln(w, "no technology detected")
+		return
+	}
+
+	for _, domainName := range domains {
+		fmt.Fprintln(w, domainName)
+		for _, technology := range technologiesPerDomain[domainName] {
+			line := "\t" + technology.Name
+			if len(technology.Version) > 0 {
+				line += " " + technology.Version
+			}
+			line += fmt.Sprintf(" (%d%%)", technology.Confidence)
+			if len(technology.Categories) > 0 {
+				line += " [" + strings.Join(technology.Categories, ", ") + "]"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/akamensky/argparse"
+	"github.com/m1dugh/crawler/pkg/warc"
+)
+
+// the max length of the url part of the names of the extracted files
+const MAX_EXTRACTED_NAME_LENGTH = 100
+
+func AddWarcCommand(parser *argparse.Parser) *argparse.Command {
+
+	warcCommand := parser.NewCommand("warc", "reads the WARC files written by crawl --warc")
+
+	readCommand := warcCommand.NewCommand("read", "lists the records of WARC files or extracts them")
+
+	readCommand.StringList("f", "file", &argparse.Options{
+		Required: true,
+		Help:     "the WARC files (.warc.gz or .warc), can be specified multiple times",
+	})
+
+	readCommand.StringList("", "type", &argparse.Options{
+		Help: "the types of the records read (warcinfo, request, response), can be specified multiple times (default: all)",
+	})
+
+	readCommand.String("", "url", &argparse.Options{
+		Help: "the regex the WARC-Target-URI of the records read must match",
+	})
+
+	readCommand.String("x", "extract", &argparse.Options{
+		Help: "the directory the records are extracted to, the body of the http response for the response records and the whole block otherwise",
+	})
+
+	readCommand.Flag("", "json", &argparse.Options{
+		Help: "lists the records as json lines",
+	})
+
+	return warcCommand
+}
+
+func HandleWarcCommand(warcCommand *argparse.Command) {
+	for _, command := range warcCommand.GetCommands() {
+		if command.Happened() {
+			if command.GetName() == "read" {
+				var files []string
+				var types []string
+				var urlRegex string
+				var extractDir string
+				var jsonFlag bool
+				for _, arg := range command.GetArgs() {
+					switch arg.GetLname() {
+					case "file":
+						files = *arg.GetResult().(*[]string)
+					case "type":
+						types = *arg.GetResult().(*[]string)
+					case "url":
+						urlRegex = *arg.GetResult().(*string)
+					case "extract":
+						extractDir = *arg.GetResult().(*string)
+					case "json":
+						jsonFlag = *arg.GetResult().(*bool)
+					}
+				}
+
+				var urlPattern *regexp.Regexp
+				if len(urlRegex) > 0 {
+					var err error
+					if urlPattern, err = regexp.Compile(urlRegex); err != nil {
+						log.Fatal("could not compile url regex: ", err)
+					}
+				}
+
+				if len(extractDir) > 0 {
+					if err := os.MkdirAll(extractDir, 0755); err != nil {
+						log.Fatal("could not create extract directory: ", err)
+					}
+				}
+
+				index := 0
+				for _, file := range files {
+					err := readWarcFile(file, func(record *warc.Record) error {
+						if len(types) > 0 && !containsString(types, record.Type()) {
+							return nil
+						}
+						if urlPattern != nil && !urlPattern.MatchString(record.TargetUri()) {
+							return nil
+						}
+
+						if len(extractDir) > 0 {
+							fileName, err := extractWarcRecord(extractDir, index, record)
+							if err != nil {
+								return err
+							}
+							fmt.Println(fileName)
+						} else if jsonFlag {
+							if err := json.NewEncoder(os.Stdout).Encode(newWarcRecordLine(file, record)); err != nil {
+								return err
+							}
+						} else {
+							fmt.Printf("%s\t%s\t%d\t%s\n", record.Header.Get("WARC-Date"), record.Type(), len(record.Block), record.TargetUri())
+						}
+						index++
+						return nil
+					})
+
+					if err != nil {
+						log.Fatal(err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// a line of the json listing of the records
+type warcRecordLine struct {
+	File        string `json:"file"`
+	Type        string `json:"type"`
+	Date        string `json:"date"`
+	RecordId    string `json:"record_id"`
+	TargetUri   string `json:"target_uri,omitempty"`
+	ContentType string `json:"content_type"`
+	Length      int    `json:"length"`
+}
+
+func newWarcRecordLine(file string, record *warc.Record) warcRecordLine {
+	return warcRecordLine{
+		File:        file,
+		Type:        record.Type(),
+		Date:        record.Header.Get("WARC-Date"),
+		RecordId:    record.Header.Get("WARC-Record-ID"),
+		TargetUri:   record.TargetUri(),
+		ContentType: record.Header.Get("Content-Type"),
+		Length:      len(record.Block),
+	}
+}
+
+// calls handleRecord with every record of the WARC file fileName
+func readWarcFile(fileName string, handleRecord func(record *warc.Record) error) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return fmt.Errorf("could not open warc file: %s", err)
+	}
+	defer file.Close()
+
+	reader, err := warc.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", fileName, err)
+	}
+
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not read %s: %s", fileName, err)
+		}
+
+		if err = handleRecord(record); err != nil {
+			return fmt.Errorf("could not read %s: %s", fileName, err)
+		}
+	}
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^\w.-]+`)
+
+// writes the payload of record to dir, returns the name of the file
+func extractWarcRecord(dir string, index int, record *warc.Record) (string, error) {
+	payload, err := record.Payload()
+	if err != nil {
+		return "", err
+	}
+
+	name := record.TargetUri()
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "https://"), "http://")
+	name = strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > MAX_EXTRACTED_NAME_LENGTH {
+		name = name[:MAX_EXTRACTED_NAME_LENGTH]
+	}
+	if len(name) == 0 {
+		name = record.Type()
+	}
+
+	fileName := filepath.Join(dir, fmt.Sprintf("%05d-%s", index, name))
+	if err = os.WriteFile(fileName, payload, 0644); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package headers
+
+import (
+	"sort"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// the max number of urls kept as examples of a finding
+const MAX_FINDING_URLS = 3
+
+type Severity = crawler.Severity
+
+const (
+	SEVERITY_INFO   = crawler.SEVERITY_INFO
+	SEVERITY_LOW    = crawler.SEVERITY_LOW
+	SEVERITY_MEDIUM = crawler.SEVERITY_MEDIUM
+	SEVERITY_HIGH   = crawler.SEVERITY_HIGH
+)
+
+var ParseSeverity = crawler.ParseSeverity
+
+// an issue of the security headers of a host, de-duplicated across its pages
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Header   string   `json:"header"`
+	Subject  string   `json:"subject,omitempty"`
+	Message  string   `json:"message"`
+
+	// the number of pages the issue was found on
+	Count int `json:"count"`
+
+	// examples of urls the issue was found on
+	Urls []string `json:"urls"`
+}
+
+// returns the findings of a single response
+func AuditPage(pageResult crawler.PageResult) []Finding {
+	findings := make([]Finding, 0)
+	if pageResult.Headers == nil {
+		return findings
+	}
+
+	for _, check := range checks {
+		for _, issue := range check(pageResult) {
+			findings = append(findings, Finding{
+				Check:    issue.Check,
+				Severity: issue.Severity,
+				Header:   issue.Header,
+				Subject:  issue.Subject,
+				Message:  issue.Message,
+				Count:    1,
+				Urls:     []string{pageResult.Url.ToUrl()},
+			})
+		}
+	}
+
+	return findings
+}
+
+// returns the findings of the pages of a host, sorted by severity
+func Audit(pageResults []crawler.PageResult) []Finding {
+	findings := make(map[string]*Finding)
+	for _, pageResult := range pageResults {
+		for _, finding := range AuditPage(pageResult) {
+			key := finding.Check + "\x00" + finding.Subject
+			current, ok := findings[key]
+			if !ok {
+				value := finding
+				findings[key] = &value
+				continue
+			}
+
+			current.Count++
+			if len(current.Urls) < MAX_FINDING_URLS {
+				current.Urls = append(current.Urls, finding.Urls...)
+			}
+		}
+	}
+
+	result := make([]Finding, 0, len(findings))
+	for _, finding := range findings {
+		result = append(result, *finding)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Severity.Rank() != result[j].Severity.Rank() {
+			return result[i].Severity.Rank() > result[j].Severity.Rank()
+		}
+		if result[i].Check != result[j].Check {
+			return result[i].Check < result[j].Check
+		}
+		return result[i].Subject < result[j].Subject
+	})
+
+	return result
+}
+
+// returns the findings per domain of the fetched pages
+func AuditFetchedUrls(fetchedUrls crawler.FetchedUrls) map[string][]Finding {
+	result := make(map[string][]Finding, len(fetchedUrls))
+	for domainName, domainResults := range fetchedUrls {
+		pageResults := make([]crawler.PageResult, 0)
+		for _, entry := range domainResults {
+			pageResults = append(pageResults, entry.PageResults...)
+		}
+
+		if findings := Audit(pageResults); len(findings) > 0 {
+			result[domainName] = findings
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,146 @@
+package headers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// the headers of a page without any issue
+func safeHeaders() http.Header {
+	return http.Header{
+		"Content-Type":              {"text/html"},
+		"Strict-Transport-Security": {"max-age=31536000"},
+		"Content-Security-Policy":   {"default-src 'self'; frame-ancestors 'none'"},
+		"X-Content-Type-Options":    {"nosniff"},
+	}
+}
+
+func TestAuditPage(t *testing.T) {
+	if findings := AuditPage(testPageResult("https://example.com/", nil)); len(findings) != 0 {
+		t.Errorf("expected no findings without headers, got %v", findings)
+	}
+	if findings := AuditPage(testPageResult("https://example.com/", safeHeaders())); len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+
+	headers := safeHeaders()
+	headers.Del("X-Content-Type-Options")
+	findings := AuditPage(testPageResult("https://example.com/a", headers))
+	expected := []Finding{{
+		Check:    "content-type-options-missing",
+		Severity: SEVERITY_LOW,
+		Header:   "X-Content-Type-Options",
+		Message:  "X-Content-Type-Options is not set to nosniff",
+		Count:    1,
+		Urls:     []string{"https://example.com/a"},
+	}}
+	if !reflect.DeepEqual(findings, expected) {
+		t.Errorf("expected %v, got %v", expected, findings)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	page := func(path string, edit func(headers http.Header)) crawler.PageResult {
+		headers := safeHeaders()
+		edit(headers)
+		return testPageResult("https://example.com"+path, headers)
+	}
+	withoutNosniff := func(headers http.Header) { headers.Del("X-Content-Type-Options") }
+
+	tests := []struct {
+		name        string
+		pageResults []crawler.PageResult
+		// check:subject => count and urls
+		counts map[string]int
+		urls   map[string][]string
+		order  []string
+	}{
+		{"no findings", []crawler.PageResult{page("/", func(http.Header) {})}, map[string]int{}, map[string][]string{}, []string{}},
+		{"counted once per page", []crawler.PageResult{
+			page("/a", withoutNosniff),
+			page("/b", withoutNosniff),
+		}, map[string]int{"content-type-options-missing": 2}, map[string][]string{
+			"content-type-options-missing": {"https://example.com/a", "https://example.com/b"},
+		}, []string{"content-type-options-missing"}},
+		{"urls capped", []crawler.PageResult{
+			page("/1", withoutNosniff),
+			page("/2", withoutNosniff),
+			page("/3", withoutNosniff),
+			page("/4", withoutNosniff),
+			page("/5", withoutNosniff),
+		}, map[string]int{"content-type-options-missing": 5}, map[string][]string{
+			"content-type-options-missing": {"https://example.com/1", "https://example.com/2", "https://example.com/3"},
+		}, []string{"content-type-options-missing"}},
+		{"subjects kept apart", []crawler.PageResult{
+			page("/a", func(headers http.Header) { headers.Add("Set-Cookie", "a=1; Secure; SameSite=Lax") }),
+			page("/b", func(headers http.Header) {
+				headers.Add("Set-Cookie", "a=1; Secure; SameSite=Lax")
+				headers.Add("Set-Cookie", "b=1; Secure; SameSite=Lax")
+			}),
+		}, map[string]int{"cookie-not-httponly:a": 2, "cookie-not-httponly:b": 1}, map[string][]string{
+			"cookie-not-httponly:a": {"https://example.com/a", "https://example.com/b"},
+			"cookie-not-httponly:b": {"https://example.com/b"},
+		}, []string{"cookie-not-httponly:a", "cookie-not-httponly:b"}},
+		{"sorted by severity", []crawler.PageResult{
+			page("/a", func(headers http.Header) {
+				headers.Del("X-Content-Type-Options")
+				headers.Set("Access-Control-Allow-Origin", "*")
+				headers.Set("Access-Control-Allow-Credentials", "true")
+				headers.Del("Content-Security-Policy")
+			}),
+		}, map[string]int{"cors-wildcard-credentials": 1, "csp-missing": 1, "frame-options-missing": 1, "content-type-options-missing": 1}, nil,
+			[]string{"cors-wildcard-credentials", "csp-missing", "frame-options-missing", "content-type-options-missing"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings := Audit(test.pageResults)
+
+			order := make([]string, 0, len(findings))
+			counts := make(map[string]int, len(findings))
+			urls := make(map[string][]string, len(findings))
+			for _, finding := range findings {
+				key := finding.Check
+				if len(finding.Subject) > 0 {
+					key += ":" + finding.Subject
+				}
+				order = append(order, key)
+				counts[key] = finding.Count
+				urls[key] = finding.Urls
+			}
+
+			if !reflect.DeepEqual(order, test.order) {
+				t.Errorf("expected findings %v, got %v", test.order, order)
+			}
+			if !reflect.DeepEqual(counts, test.counts) {
+				t.Errorf("expected counts %v, got %v", test.counts, counts)
+			}
+			if test.urls != nil && !reflect.DeepEqual(urls, test.urls) {
+				t.Errorf("expected urls %v, got %v", test.urls, urls)
+			}
+		})
+	}
+}
+
+func TestAuditFetchedUrls(t *testing.T) {
+	data := crawler.NewCrawlerData()
+	for i := 0; i < 2; i++ {
+		data.AddFetchedUrl(testPageResult(fmt.Sprintf("https://example.com/%d", i), http.Header{"Content-Type": {"application/json"}}))
+	}
+	data.AddFetchedUrl(testPageResult("https://safe.com/", safeHeaders()))
+
+	result := AuditFetchedUrls(data.FetchedUrls)
+	if _, ok := result["safe.com"]; ok || len(result) != 1 {
+		t.Fatalf("expected only the findings of example.com, got %v", result)
+	}
+
+	for _, finding := range result["example.com"] {
+		if finding.Count != 2 {
+			t.Errorf("expected %s on the 2 pages, got %d", finding.Check, finding.Count)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package headers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// the min max-age of a Strict-Transport-Security header, 180 days
+const HSTS_MIN_MAX_AGE = 15552000
+
+var maxAgePattern = regexp.MustCompile(`(?i)max-age\s*=\s*"?(\d+)"?`)
+
+// an issue found in the headers of a response, Subject distinguishing
+// the issues of a same check (the name of a cookie...)
+type issue struct {
+	Check    string
+	Severity Severity
+	Header   string
+	Subject  string
+	Message  string
+}
+
+func isHttps(pageResult crawler.PageResult) bool {
+	return strings.HasPrefix(strings.ToLower(pageResult.Url.BaseUrl), "https://")
+}
+
+func isHtml(pageResult crawler.PageResult) bool {
+	return strings.HasPrefix(pageResult.ContentType(), "text/html")
+}
+
+func checkHsts(pageResult crawler.PageResult) []issue {
+	value := pageResult.Headers.Get("Strict-Transport-Security")
+
+	if !isHttps(pageResult) {
+		if len(value) > 0 {
+			return []issue{{"hsts-over-http", SEVERITY_INFO, "Strict-Transport-Security", "", "Strict-Transport-Security is ignored by browsers over http"}}
+		}
+		return nil
+	}
+
+	if len(value) == 0 {
+		return []issue{{"hsts-missing", SEVERITY_MEDIUM, "Strict-Transport-Security", "", "Strict-Transport-Security is not set"}}
+	}
+
+	match := maxAgePattern.FindStringSubmatch(value)
+	if match == nil {
+		return []issue{{"hsts-invalid", SEVERITY_LOW, "Strict-Transport-Security", "", "Strict-Transport-Security has no max-age directive"}}
+	}
+
+	if maxAge, err := strconv.Atoi(match[1]); err != nil || maxAge < HSTS_MIN_MAX_AGE {
+		return []issue{{"hsts-short-max-age", SEVERITY_LOW, "Strict-Transport-Security", "", "Strict-Transport-Security max-age is lower than 180 days: " + match[1]}}
+	}
+
+	return nil
+}
+
+// returns the directives of a content security policy, names being lower case
+func parseCsp(value string) map[string][]string {
+	directives := make(map[string][]string)
+	for _, directive := range strings.Split(value, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, ok := directives[name]; !ok {
+			directives[name] = fields[1:]
+		}
+	}
+	return directives
+}
+
+func checkCsp(pageResult crawler.PageResult) []issue {
+	if !isHtml(pageResult) {
+		return nil
+	}
+
+	value := pageResult.Headers.Get("Content-Security-Policy")
+	if len(value) == 0 {
+		if len(pageResult.Headers.Get("Content-Security-Policy-Report-Only")) > 0 {
+			return []issue{{"csp-report-only", SEVERITY_LOW, "Content-Security-Policy-Report-Only", "", "the content security policy is only reported, not enforced"}}
+		}
+		return []issue{{"csp-missing", SEVERITY_MEDIUM, "Content-Security-Policy", "", "Content-Security-Policy is not set"}}
+	}
+
+	directives := parseCsp(value)
+
+	sources, ok := directives["script-src"]
+	directive := "script-src"
+	if !ok {
+		sources, ok = directives["default-src"]
+		directive = "default-src"
+	}
+
+	if !ok {
+		return []issue{{"csp-no-script-src", SEVERITY_MEDIUM, "Content-Security-Policy", "", "the content security policy restricts neither script-src nor default-src"}}
+	}
+
+	issues := make([]issue, 0)
+	for _, source := range sources {
+		switch strings.ToLower(source) {
+		case "'unsafe-inline'":
+			issues = append(issues, issue{"csp-unsafe-inline", SEVERITY_MEDIUM, "Content-Security-Policy", directive, directive + " allows 'unsafe-inline'"})
+		case "'unsafe-eval'":
+			issues = append(issues, issue{"csp-unsafe-eval", SEVERITY_MEDIUM, "Content-Security-Policy", directive, directive + " allows 'unsafe-eval'"})
+		case "*", "http:", "https:", "data:":
+			issues = append(issues, issue{"csp-wildcard-source", SEVERITY_MEDIUM, "Content-Security-Policy", directive, directive + " allows scripts from " + source})
+		}
+	}
+
+	return issues
+}
+
+func checkFrameOptions(pageResult crawler.PageResult) []issue {
+	if !isHtml(pageResult) {
+		return nil
+	}
+
+	value := strings.ToUpper(strings.TrimSpace(pageResult.Headers.Get("X-Frame-Options")))
+	if len(value) == 0 {
+		if _, ok := parseCsp(pageResult.Headers.Get("Content-Security-Policy"))["frame-ancestors"]; ok {
+			return nil
+		}
+		return []issue{{"frame-options-missing", SEVERITY_MEDIUM, "X-Frame-Options", "", "neither X-Frame-Options nor the frame-ancestors directive is set, the page can be framed (clickjacking)"}}
+	}
+
+	if value != "DENY" && value != "SAMEORIGIN" {
+		return []issue{{"frame-options-invalid", SEVERITY_LOW, "X-Frame-Options", "", "X-Frame-Options has an invalid value: " + value}}
+	}
+
+	return nil
+}
+
+func checkContentTypeOptions(pageResult crawler.PageResult) []issue {
+	if strings.ToLower(strings.TrimSpace(pageResult.Headers.Get("X-Content-Type-Options"))) != "nosniff" {
+		return []issue{{"content-type-options-missing", SEVERITY_LOW, "X-Content-Type-Options", "", "X-Content-Type-Options is not set to nosniff"}}
+	}
+	return nil
+}
+
+func checkCors(pageResult crawler.PageResult) []issue {
+	origin := strings.TrimSpace(pageResult.Headers.Get("Access-Control-Allow-Origin"))
+	if len(origin) == 0 {
+		return nil
+	}
+
+	credentials := strings.EqualFold(strings.TrimSpace(pageResult.Headers.Get("Access-Control-Allow-Credentials")), "true")
+
+	switch {
+	case origin == "*" && credentials:
+		return []issue{{"cors-wildcard-credentials", SEVERITY_HIGH, "Access-Control-Allow-Origin", "", "any origin is allowed with credentials"}}
+	case origin == "*":
+		return []issue{{"cors-wildcard", SEVERITY_INFO, "Access-Control-Allow-Origin", "", "any origin is allowed"}}
+	case strings.EqualFold(origin, "null"):
+		return []issue{{"cors-null-origin", SEVERITY_MEDIUM, "Access-Control-Allow-Origin", "", "the null origin is allowed (sandboxed iframes, local files)"}}
+	case credentials:
+		return []issue{{"cors-credentials", SEVERITY_INFO, "Access-Control-Allow-Origin", origin, "credentials are allowed for " + origin + ", check that the origin is not reflected"}}
+	}
+
+	return nil
+}
+
+func checkCookies(pageResult crawler.PageResult) []issue {
+	response := http.Response{Header: pageResult.Headers}
+	https := isHttps(pageResult)
+
+	issues := make([]issue, 0)
+	for _, cookie := range response.Cookies() {
+		if https && !cookie.Secure {
+			issues = append(issues, issue{"cookie-not-secure", SEVERITY_MEDIUM, "Set-Cookie", cookie.Name, "cookie " + cookie.Name + " is not Secure"})
+		}
+
+		if !cookie.HttpOnly {
+			issues = append(issues, issue{"cookie-not-httponly", SEVERITY_LOW, "Set-Cookie", cookie.Name, "cookie " + cookie.Name + " is not HttpOnly"})
+		}
+
+		switch cookie.SameSite {
+		case 0, http.SameSiteDefaultMode:
+			issues = append(issues, issue{"cookie-no-samesite", SEVERITY_LOW, "Set-Cookie", cookie.Name, "cookie " + cookie.Name + " has no SameSite attribute"})
+		case http.SameSiteNoneMode:
+			if !cookie.Secure {
+				issues = append(issues, issue{"cookie-samesite-none-not-secure", SEVERITY_MEDIUM, "Set-Cookie", cookie.Name, "cookie " + cookie.Name + " is SameSite=None without Secure, browsers reject it"})
+			}
+		}
+	}
+
+	return issues
+}
+
+// the checks run on every response
+var checks = []func(crawler.PageResult) []issue{
+	checkHsts,
+	checkCsp,
+	checkFrameOptions,
+	checkContentTypeOptions,
+	checkCors,
+	checkCookies,
+}
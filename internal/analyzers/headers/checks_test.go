@@ -0,0 +1,203 @@
+package headers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func testPageResult(url string, headers http.Header) crawler.PageResult {
+	return crawler.PageResult{
+		Url:     crawler.PageRequestFromUrl(url),
+		Headers: headers,
+	}
+}
+
+// returns the check and subject of the issues
+func issueKeys(issues []issue) []string {
+	keys := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		key := issue.Check
+		if len(issue.Subject) > 0 {
+			key += ":" + issue.Subject
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestParseCsp(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		directives map[string][]string
+	}{
+		{"empty", "", map[string][]string{}},
+		{"directives", "default-src 'self'; script-src 'self' https://cdn.example.com", map[string][]string{
+			"default-src": {"'self'"},
+			"script-src":  {"'self'", "https://cdn.example.com"},
+		}},
+		{"case and spaces", "  Script-Src   'none' ;;frame-ancestors", map[string][]string{
+			"script-src":      {"'none'"},
+			"frame-ancestors": {},
+		}},
+		{"first directive kept", "script-src 'self'; script-src *", map[string][]string{
+			"script-src": {"'self'"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if directives := parseCsp(test.value); !reflect.DeepEqual(directives, test.directives) {
+				t.Errorf("expected %v, got %v", test.directives, directives)
+			}
+		})
+	}
+}
+
+func TestCheckCsp(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers http.Header
+		issues  []string
+	}{
+		{"not html", http.Header{"Content-Type": {"application/json"}}, []string{}},
+		{"missing", http.Header{"Content-Type": {"text/html"}}, []string{"csp-missing"}},
+		{"report only", http.Header{"Content-Type": {"text/html"}, "Content-Security-Policy-Report-Only": {"default-src 'self'"}}, []string{"csp-report-only"}},
+		{"safe", http.Header{"Content-Type": {"text/html"}, "Content-Security-Policy": {"default-src 'self'"}}, []string{}},
+		{"no script source", http.Header{"Content-Type": {"text/html"}, "Content-Security-Policy": {"img-src *"}}, []string{"csp-no-script-src"}},
+		{"unsafe default", http.Header{"Content-Type": {"text/html"}, "Content-Security-Policy": {"default-src 'self' 'unsafe-inline' 'UNSAFE-EVAL'"}}, []string{"csp-unsafe-inline:default-src", "csp-unsafe-eval:default-src"}},
+		{"script src first", http.Header{"Content-Type": {"text/html"}, "Content-Security-Policy": {"default-src *; script-src 'self'"}}, []string{}},
+		{"wildcard sources", http.Header{"Content-Type": {"text/html"}, "Content-Security-Policy": {"script-src https: data: https://cdn.example.com"}}, []string{"csp-wildcard-source:script-src", "csp-wildcard-source:script-src"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues := issueKeys(checkCsp(testPageResult("https://example.com/", test.headers)))
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("expected %v, got %v", test.issues, issues)
+			}
+		})
+	}
+}
+
+func TestCheckHsts(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		value  string
+		issues []string
+	}{
+		{"http without", "http://example.com/", "", []string{}},
+		{"http with", "http://example.com/", "max-age=31536000", []string{"hsts-over-http"}},
+		{"missing", "https://example.com/", "", []string{"hsts-missing"}},
+		{"no max age", "https://example.com/", "includeSubDomains", []string{"hsts-invalid"}},
+		{"zero", "https://example.com/", "max-age=0", []string{"hsts-short-max-age"}},
+		{"below min", "https://example.com/", "max-age=15551999", []string{"hsts-short-max-age"}},
+		{"min", "https://example.com/", "max-age=15552000", []string{}},
+		{"quoted with directives", "https://example.com/", `includeSubDomains; MAX-AGE = "31536000"; preload`, []string{}},
+		{"overflow", "https://example.com/", "max-age=99999999999999999999", []string{"hsts-short-max-age"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers := http.Header{}
+			if len(test.value) > 0 {
+				headers.Set("Strict-Transport-Security", test.value)
+			}
+
+			issues := issueKeys(checkHsts(testPageResult(test.url, headers)))
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("expected %v, got %v", test.issues, issues)
+			}
+		})
+	}
+}
+
+func TestCheckCors(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      string
+		credentials string
+		issues      []issue
+	}{
+		{"none", "", "true", nil},
+		{"wildcard", "*", "", []issue{{"cors-wildcard", SEVERITY_INFO, "Access-Control-Allow-Origin", "", "any origin is allowed"}}},
+		{"wildcard credentials", "*", "TRUE", []issue{{"cors-wildcard-credentials", SEVERITY_HIGH, "Access-Control-Allow-Origin", "", "any origin is allowed with credentials"}}},
+		{"wildcard credentials false", "*", "false", []issue{{"cors-wildcard", SEVERITY_INFO, "Access-Control-Allow-Origin", "", "any origin is allowed"}}},
+		{"null", "null", "", []issue{{"cors-null-origin", SEVERITY_MEDIUM, "Access-Control-Allow-Origin", "", "the null origin is allowed (sandboxed iframes, local files)"}}},
+		{"origin", "https://app.example.com", "", nil},
+		{"origin credentials", "https://app.example.com", " true ", []issue{{"cors-credentials", SEVERITY_INFO, "Access-Control-Allow-Origin", "https://app.example.com", "credentials are allowed for https://app.example.com, check that the origin is not reflected"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers := http.Header{}
+			if len(test.origin) > 0 {
+				headers.Set("Access-Control-Allow-Origin", test.origin)
+			}
+			if len(test.credentials) > 0 {
+				headers.Set("Access-Control-Allow-Credentials", test.credentials)
+			}
+
+			if issues := checkCors(testPageResult("https://example.com/", headers)); !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("expected %v, got %v", test.issues, issues)
+			}
+		})
+	}
+}
+
+func TestCheckCookies(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		cookie string
+		issues []string
+	}{
+		{"safe", "https://example.com/", "id=1; Secure; HttpOnly; SameSite=Lax", []string{}},
+		{"not secure", "https://example.com/", "id=1; HttpOnly; SameSite=Strict", []string{"cookie-not-secure:id"}},
+		{"not secure over http", "http://example.com/", "id=1; HttpOnly; SameSite=Strict", []string{}},
+		{"not httponly", "https://example.com/", "id=1; Secure; SameSite=Strict", []string{"cookie-not-httponly:id"}},
+		{"no samesite", "https://example.com/", "id=1; Secure; HttpOnly", []string{"cookie-no-samesite:id"}},
+		{"empty samesite", "https://example.com/", "id=1; Secure; HttpOnly; SameSite", []string{"cookie-no-samesite:id"}},
+		{"samesite none secure", "https://example.com/", "id=1; Secure; HttpOnly; SameSite=None", []string{}},
+		{"samesite none not secure", "http://example.com/", "id=1; HttpOnly; SameSite=None", []string{"cookie-samesite-none-not-secure:id"}},
+		{"nothing set", "https://example.com/", "id=1", []string{"cookie-not-secure:id", "cookie-not-httponly:id", "cookie-no-samesite:id"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers := http.Header{"Set-Cookie": {test.cookie}}
+			issues := issueKeys(checkCookies(testPageResult(test.url, headers)))
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("expected %v, got %v", test.issues, issues)
+			}
+		})
+	}
+}
+
+func TestCheckFrameOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers http.Header
+		issues  []string
+	}{
+		{"deny", http.Header{"X-Frame-Options": {"deny"}}, []string{}},
+		{"sameorigin", http.Header{"X-Frame-Options": {" SAMEORIGIN "}}, []string{}},
+		{"invalid", http.Header{"X-Frame-Options": {"ALLOW-FROM https://example.com"}}, []string{"frame-options-invalid"}},
+		{"frame ancestors", http.Header{"Content-Security-Policy": {"frame-ancestors 'none'"}}, []string{}},
+		{"missing", http.Header{}, []string{"frame-options-missing"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.headers.Set("Content-Type", "text/html")
+			issues := issueKeys(checkFrameOptions(testPageResult("https://example.com/", test.headers)))
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("expected %v, got %v", test.issues, issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+	"github.com/m1dugh/crawler/internal/plugin"
+)
+
+// the name under which the analyzer is registered, the source of its findings
+const ANALYZER_NAME = "secrets"
+
+// the type of the crawler.Finding of a secret
+const FINDING_TYPE = "secret"
+
+// scans every fetched body for secrets, the secrets of a page being
+// attached to its PageResult as findings
+type Analyzer struct {
+	rules *RuleSet
+
+	// stores the whole secrets in the findings instead of redacted ones,
+	// the findings being persisted in the scan files
+	Unredacted bool
+
+	pages    int
+	findings map[string][]Finding
+	sync.Mutex
+}
+
+func NewAnalyzer(rules *RuleSet) *Analyzer {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+
+	return &Analyzer{
+		rules:    rules,
+		findings: make(map[string][]Finding),
+	}
+}
+
+// returns the crawler.Finding of a secret, its evidence being the redacted
+// secret unless unredacted is set
+func (f Finding) ToFinding(unredacted bool) crawler.Finding {
+	evidence := f.Redacted()
+	if unredacted {
+		evidence = f.Secret
+	}
+
+	return crawler.Finding{
+		Type:     FINDING_TYPE,
+		Severity: f.Severity,
+		Message:  f.RuleId + ": " + f.Description,
+		Evidence: evidence,
+		Location: fmt.Sprintf("body:%d", f.Line),
+		Source:   ANALYZER_NAME,
+	}
+}
+
+func (a *Analyzer) analyzePage(body []byte, pageResult crawler.PageResult) []crawler.Finding {
+	findings := a.rules.Scan(body)
+
+	a.Lock()
+	a.pages++
+	if len(findings) > 0 {
+		a.findings[pageResult.Url.ToUrl()] = findings
+	}
+	a.Unlock()
+
+	result := make([]crawler.Finding, len(findings))
+	for i, finding := range findings {
+		result[i] = finding.ToFinding(a.Unredacted)
+	}
+
+	return result
+}
+
+// returns a built-in plugin running the analyzer on all domains
+func (a *Analyzer) Plugin() *plugin.CrawlerPlugin {
+	var handler crawler.AnalyzePage = a.analyzePage
+
+	return &plugin.CrawlerPlugin{
+		ApiVersion: plugin.API_VERSION,
+		Metadata: plugin.PluginMetadata{
+			Name:        ANALYZER_NAME,
+			Description: "detects leaked api keys, tokens, private keys and jwts",
+		},
+		Entries: []*plugin.CrawlerPluginEntry{
+			{
+				MatchMode:   plugin.MATCH_ALL,
+				AnalyzePage: &handler,
+			},
+		},
+	}
+}
+
+// returns the findings per page url
+func (a *Analyzer) GetFindings() map[string][]Finding {
+	a.Lock()
+	defer a.Unlock()
+
+	result := make(map[string][]Finding, len(a.findings))
+	for url, findings := range a.findings {
+		result[url] = append([]Finding(nil), findings...)
+	}
+	return result
+}
+
+// writes the number of findings per rule and the redacted findings per page
+func (a *Analyzer) PrintSummary(w io.Writer) {
+	findings := a.GetFindings()
+
+	a.Lock()
+	pages := a.pages
+	a.Unlock()
+
+	total := 0
+	perRule := make(map[string]int)
+	urls := make([]string, 0, len(findings))
+	for url, pageFindings := range findings {
+		urls = append(urls, url)
+		total += len(pageFindings)
+		for _, finding := range pageFindings {
+			perRule[finding.RuleId]++
+		}
+	}
+	sort.Strings(urls)
+
+	fmt.Fprintf(w, "secrets report: %d finding(s) on %d page(s), %d page(s) scanned\n", total, len(urls), pages)
+	if total == 0 {
+		return
+	}
+
+	rules := make([]string, 0, len(perRule))
+	for rule := range perRule {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		fmt.Fprintf(w, "\t%s: %d\n", rule, perRule[rule])
+	}
+
+	for _, url := range urls {
+		fmt.Fprintf(w, "\t%s\n", url)
+		for _, finding := range findings[url] {
+			fmt.Fprintf(w, "\t\tline %d: %s %s (entropy %.2f)\n", finding.Line, finding.RuleId, finding.Redacted(), finding.Entropy)
+		}
+	}
+}
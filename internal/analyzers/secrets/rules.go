@@ -0,0 +1,193 @@
+package secrets
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+//go:embed rules.yaml
+var DEFAULT_RULES []byte
+
+// a rule detecting one kind of secret, rules are run in order
+type Rule struct {
+	Id          string `yaml:"id" json:"id"`
+	Description string `yaml:"description" json:"description"`
+	Pattern     string `yaml:"pattern" json:"pattern"`
+
+	// the capture group of Pattern holding the secret, the whole match if 0
+	Group int `yaml:"group" json:"group"`
+
+	// the rule is only run on bodies containing one of the keywords (case insensitive)
+	Keywords []string `yaml:"keywords" json:"keywords"`
+
+	// the min shannon entropy of the secret, in bits per char
+	MinEntropy float64 `yaml:"min_entropy" json:"min_entropy"`
+
+	// secrets matching one of these regexes are ignored
+	Allowlist []string `yaml:"allowlist" json:"allowlist"`
+
+	// the severity of the findings of the rule, high if not set
+	Severity crawler.Severity `yaml:"severity" json:"severity"`
+
+	Disabled bool `yaml:"disabled" json:"disabled"`
+
+	pattern   *regexp.Regexp
+	allowlist []*regexp.Regexp
+}
+
+// the content of a rule file
+type RuleSet struct {
+	// secrets matching one of these regexes are ignored by all rules
+	Allowlist []string `yaml:"allowlist"`
+
+	Rules []*Rule `yaml:"rules"`
+
+	allowlist []*regexp.Regexp
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+func (rule *Rule) compile() error {
+	var err error
+	if rule.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+		return fmt.Errorf("secrets::Rule.compile -> invalid pattern for %s: %s", rule.Id, err)
+	}
+
+	if rule.Group < 0 || rule.Group > rule.pattern.NumSubexp() {
+		return fmt.Errorf("secrets::Rule.compile -> %s has no group %d", rule.Id, rule.Group)
+	}
+
+	if rule.allowlist, err = compilePatterns(rule.Allowlist); err != nil {
+		return fmt.Errorf("secrets::Rule.compile -> invalid allowlist for %s: %s", rule.Id, err)
+	}
+
+	if len(rule.Severity) == 0 {
+		rule.Severity = crawler.SEVERITY_HIGH
+	} else if severity, ok := crawler.ParseSeverity(string(rule.Severity)); ok {
+		rule.Severity = severity
+	} else {
+		return fmt.Errorf("secrets::Rule.compile -> invalid severity for %s: %s", rule.Id, rule.Severity)
+	}
+
+	for i, keyword := range rule.Keywords {
+		rule.Keywords[i] = strings.ToLower(keyword)
+	}
+
+	return nil
+}
+
+// returns true if secret matches an allowlist regex of the rule
+func (rule *Rule) isAllowed(secret string) bool {
+	for _, r := range rule.allowlist {
+		if r.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// parses and compiles a rule file
+func ParseRules(source []byte) (*RuleSet, error) {
+	var ruleSet RuleSet
+	if err := yaml.Unmarshal(source, &ruleSet); err != nil {
+		return nil, fmt.Errorf("secrets::ParseRules -> could not unmarshal rules: %s", err)
+	}
+
+	if err := ruleSet.compile(); err != nil {
+		return nil, err
+	}
+
+	return &ruleSet, nil
+}
+
+func (ruleSet *RuleSet) compile() error {
+	var err error
+	if ruleSet.allowlist, err = compilePatterns(ruleSet.Allowlist); err != nil {
+		return fmt.Errorf("secrets::RuleSet.compile -> invalid allowlist: %s", err)
+	}
+
+	ids := make(map[string]bool, len(ruleSet.Rules))
+	for _, rule := range ruleSet.Rules {
+		if len(rule.Id) == 0 {
+			return fmt.Errorf("secrets::RuleSet.compile -> rule without id")
+		}
+		if ids[rule.Id] {
+			return fmt.Errorf("secrets::RuleSet.compile -> duplicate rule %s", rule.Id)
+		}
+		ids[rule.Id] = true
+
+		if rule.Disabled {
+			continue
+		}
+
+		if err = rule.compile(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns the rules shipped with the crawler
+func DefaultRules() *RuleSet {
+	ruleSet, err := ParseRules(DEFAULT_RULES)
+	if err != nil {
+		panic(err)
+	}
+	return ruleSet
+}
+
+// returns a rule set containing the rules of both sets, the rules of other
+// replacing the rules with the same id, allowlists being concatenated
+func (ruleSet *RuleSet) Merge(other *RuleSet) *RuleSet {
+	if other == nil {
+		return ruleSet
+	}
+
+	result := &RuleSet{
+		Allowlist: append(append([]string{}, ruleSet.Allowlist...), other.Allowlist...),
+		allowlist: append(append([]*regexp.Regexp{}, ruleSet.allowlist...), other.allowlist...),
+		Rules:     make([]*Rule, 0, len(ruleSet.Rules)+len(other.Rules)),
+	}
+
+	overridden := make(map[string]bool, len(other.Rules))
+	for _, rule := range other.Rules {
+		overridden[rule.Id] = true
+	}
+
+	for _, rule := range ruleSet.Rules {
+		if !overridden[rule.Id] {
+			result.Rules = append(result.Rules, rule)
+		}
+	}
+
+	result.Rules = append(result.Rules, other.Rules...)
+
+	return result
+}
+
+// returns true if secret matches a global allowlist regex
+func (ruleSet *RuleSet) isAllowed(secret string) bool {
+	for _, r := range ruleSet.allowlist {
+		if r.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
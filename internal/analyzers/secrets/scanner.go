@@ -0,0 +1,113 @@
+package secrets
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// a secret found in a body
+type Finding struct {
+	RuleId      string `json:"rule"`
+	Description string `json:"description"`
+	Secret      string `json:"secret"`
+
+	// the line of the secret in the body, starting at 1
+	Line    int     `json:"line"`
+	Entropy float64 `json:"entropy"`
+
+	Severity crawler.Severity `json:"severity"`
+}
+
+// returns the secret with its middle part hidden
+func (f Finding) Redacted() string {
+	if len(f.Secret) <= 8 {
+		return strings.Repeat("*", len(f.Secret))
+	}
+	return f.Secret[:4] + "..." + f.Secret[len(f.Secret)-4:]
+}
+
+// returns the shannon entropy of value in bits per char
+func Entropy(value string) float64 {
+	if len(value) == 0 {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	total := 0
+	for _, c := range value {
+		counts[c]++
+		total++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+func containsKeyword(lowerBody []byte, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+
+	for _, keyword := range keywords {
+		if bytes.Contains(lowerBody, []byte(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the secrets found in body sorted by line, each secret being reported
+// once by the first rule matching it
+func (ruleSet *RuleSet) Scan(body []byte) []Finding {
+	findings := make([]Finding, 0)
+	lowerBody := bytes.ToLower(body)
+	seen := make(map[string]bool)
+
+	for _, rule := range ruleSet.Rules {
+		if rule.Disabled || rule.pattern == nil || !containsKeyword(lowerBody, rule.Keywords) {
+			continue
+		}
+
+		for _, match := range rule.pattern.FindAllSubmatchIndex(body, -1) {
+			start, end := match[2*rule.Group], match[2*rule.Group+1]
+			if start < 0 {
+				continue
+			}
+
+			secret := string(body[start:end])
+			if seen[secret] || ruleSet.isAllowed(secret) || rule.isAllowed(secret) {
+				continue
+			}
+
+			entropy := Entropy(secret)
+			if entropy < rule.MinEntropy {
+				continue
+			}
+
+			seen[secret] = true
+			findings = append(findings, Finding{
+				RuleId:      rule.Id,
+				Description: rule.Description,
+				Secret:      secret,
+				Line:        bytes.Count(body[:start], []byte{'\n'}) + 1,
+				Entropy:     math.Round(entropy*100) / 100,
+				Severity:    rule.Severity,
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+
+	return findings
+}
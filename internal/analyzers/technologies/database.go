@@ -0,0 +1,219 @@
+package technologies
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//go:embed technologies.json
+var DEFAULT_DATABASE []byte
+
+// the confidence of a pattern not declaring one
+const DEFAULT_CONFIDENCE = 100
+
+// the separator of the tags of a pattern ("regex\;version:\1\;confidence:50")
+const PATTERN_TAG_SEPARATOR = `\;`
+
+// a list of patterns accepting either a single string or a list
+type PatternList []string
+
+func (list *PatternList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*list = PatternList{single}
+		return nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	*list = values
+	return nil
+}
+
+type Category struct {
+	Name string `json:"name"`
+}
+
+// the signatures of a technology, in the wappalyzer format
+type Signature struct {
+	Categories  []int  `json:"cats"`
+	Description string `json:"description,omitempty"`
+	Website     string `json:"website,omitempty"`
+
+	// header name => pattern matched against its value, "" matching any value
+	Headers map[string]PatternList `json:"headers,omitempty"`
+
+	// cookie name => pattern matched against its value
+	Cookies map[string]PatternList `json:"cookies,omitempty"`
+
+	// meta name or property => pattern matched against its content
+	Meta map[string]PatternList `json:"meta,omitempty"`
+
+	// patterns matched against the src of the scripts of the page
+	ScriptSrc PatternList `json:"scriptSrc,omitempty"`
+
+	// patterns matched against the body of the page
+	Html PatternList `json:"html,omitempty"`
+
+	// patterns matched against the url of the page
+	Url PatternList `json:"url,omitempty"`
+
+	// the technologies implied by the technology ("PHP", "MySQL\;confidence:50")
+	Implies PatternList `json:"implies,omitempty"`
+
+	headers   map[string][]*pattern
+	cookies   map[string][]*pattern
+	meta      map[string][]*pattern
+	scriptSrc []*pattern
+	html      []*pattern
+	url       []*pattern
+}
+
+// a database of technology signatures, in the wappalyzer format
+type Database struct {
+	Categories   map[string]Category   `json:"categories"`
+	Technologies map[string]*Signature `json:"technologies"`
+}
+
+type pattern struct {
+	regex      *regexp.Regexp
+	version    string
+	confidence int
+}
+
+// parses "regex\;version:\1\;confidence:50", regexes being case insensitive
+func parsePattern(value string) (*pattern, error) {
+	parts := strings.Split(value, PATTERN_TAG_SEPARATOR)
+
+	regex, err := regexp.Compile("(?i)" + parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &pattern{
+		regex:      regex,
+		confidence: DEFAULT_CONFIDENCE,
+	}
+
+	for _, tag := range parts[1:] {
+		switch {
+		case strings.HasPrefix(tag, "version:"):
+			result.version = strings.TrimPrefix(tag, "version:")
+		case strings.HasPrefix(tag, "confidence:"):
+			if confidence, err := strconv.Atoi(strings.TrimPrefix(tag, "confidence:")); err == nil {
+				result.confidence = confidence
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// compiles the patterns of list, invalid patterns (unsupported regex syntax) being skipped
+func compilePatterns(technology string, list PatternList) []*pattern {
+	result := make([]*pattern, 0, len(list))
+	for _, value := range list {
+		p, err := parsePattern(value)
+		if err != nil {
+			log.Printf("technologies: skipping invalid pattern of %s: %s\n", technology, err)
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func compilePatternMap(technology string, patterns map[string]PatternList) map[string][]*pattern {
+	result := make(map[string][]*pattern, len(patterns))
+	for key, list := range patterns {
+		result[strings.ToLower(key)] = compilePatterns(technology, list)
+	}
+	return result
+}
+
+func (signature *Signature) compile(name string) {
+	signature.headers = compilePatternMap(name, signature.Headers)
+	signature.cookies = compilePatternMap(name, signature.Cookies)
+	signature.meta = compilePatternMap(name, signature.Meta)
+	signature.scriptSrc = compilePatterns(name, signature.ScriptSrc)
+	signature.html = compilePatterns(name, signature.Html)
+	signature.url = compilePatterns(name, signature.Url)
+}
+
+// parses a database in the wappalyzer format
+func ParseDatabase(source []byte) (*Database, error) {
+	var database Database
+	if err := json.Unmarshal(source, &database); err != nil {
+		return nil, fmt.Errorf("technologies::ParseDatabase -> could not unmarshal database: %s", err)
+	}
+
+	if database.Categories == nil {
+		database.Categories = make(map[string]Category)
+	}
+
+	if database.Technologies == nil {
+		database.Technologies = make(map[string]*Signature)
+	}
+
+	for name, signature := range database.Technologies {
+		if signature == nil {
+			delete(database.Technologies, name)
+			continue
+		}
+		signature.compile(name)
+	}
+
+	return &database, nil
+}
+
+// returns the database shipped with the crawler
+func DefaultDatabase() *Database {
+	database, err := ParseDatabase(DEFAULT_DATABASE)
+	if err != nil {
+		panic(err)
+	}
+	return database
+}
+
+// returns a database containing the technologies and categories of both
+// databases, the ones of other replacing the ones with the same name
+func (database *Database) Merge(other *Database) *Database {
+	if other == nil {
+		return database
+	}
+
+	result := &Database{
+		Categories:   make(map[string]Category, len(database.Categories)+len(other.Categories)),
+		Technologies: make(map[string]*Signature, len(database.Technologies)+len(other.Technologies)),
+	}
+
+	for _, db := range []*Database{database, other} {
+		for id, category := range db.Categories {
+			result.Categories[id] = category
+		}
+		for name, signature := range db.Technologies {
+			result.Technologies[name] = signature
+		}
+	}
+
+	return result
+}
+
+// returns the names of the categories of signature
+func (database *Database) getCategories(signature *Signature) []string {
+	categories := make([]string, 0, len(signature.Categories))
+	for _, id := range signature.Categories {
+		if category, ok := database.Categories[strconv.Itoa(id)]; ok {
+			categories = append(categories, category.Name)
+		}
+	}
+	return categories
+}
@@ -0,0 +1,214 @@
+package technologies
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+var metaTagPattern = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+var scriptTagPattern = regexp.MustCompile(`(?is)<script\s[^>]*\bsrc\s*=\s*["']?([^"'\s>]+)`)
+var attributePattern = regexp.MustCompile(`(?is)\b(name|property|http-equiv|content)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// matches "\1" and the ternary "\1?yes:no" of the version tags
+var versionReferencePattern = regexp.MustCompile(`\\(\d+)(?:\?([^:]*):(.*))?`)
+
+// the parts of a page the signatures are matched against
+type page struct {
+	url       string
+	headers   map[string][]string
+	cookies   map[string][]string
+	meta      map[string][]string
+	scriptSrc []string
+	html      string
+}
+
+func newPage(body []byte, pageResult crawler.PageResult) *page {
+	p := &page{
+		url:     pageResult.Url.ToUrl(),
+		headers: make(map[string][]string, len(pageResult.Headers)),
+		cookies: make(map[string][]string),
+		meta:    make(map[string][]string),
+		html:    string(body),
+	}
+
+	for name, values := range pageResult.Headers {
+		p.headers[strings.ToLower(name)] = values
+	}
+
+	response := http.Response{Header: pageResult.Headers}
+	for _, cookie := range response.Cookies() {
+		name := strings.ToLower(cookie.Name)
+		p.cookies[name] = append(p.cookies[name], cookie.Value)
+	}
+
+	for _, tag := range metaTagPattern.FindAllString(p.html, -1) {
+		var name, content string
+		for _, attribute := range attributePattern.FindAllStringSubmatch(tag, -1) {
+			value := attribute[2] + attribute[3]
+			if strings.ToLower(attribute[1]) == "content" {
+				content = value
+			} else {
+				name = strings.ToLower(value)
+			}
+		}
+		if len(name) > 0 {
+			p.meta[name] = append(p.meta[name], content)
+		}
+	}
+
+	for _, match := range scriptTagPattern.FindAllStringSubmatch(p.html, -1) {
+		p.scriptSrc = append(p.scriptSrc, match[1])
+	}
+
+	return p
+}
+
+// returns the version of the pattern for the submatches of a match
+func resolveVersion(version string, submatches []string) string {
+	return versionReferencePattern.ReplaceAllStringFunc(version, func(reference string) string {
+		parts := versionReferencePattern.FindStringSubmatch(reference)
+		index, _ := strconv.Atoi(parts[1])
+
+		var value string
+		if index < len(submatches) {
+			value = submatches[index]
+		}
+
+		// the branches of a ternary can reference the submatches too ("\1?\1:unknown")
+		if strings.Contains(reference, "?") {
+			if len(value) > 0 {
+				return resolveVersion(parts[2], submatches)
+			}
+			return resolveVersion(parts[3], submatches)
+		}
+		return value
+	})
+}
+
+// the result of the detection of a technology
+type detection struct {
+	version    string
+	confidence int
+}
+
+func (d *detection) add(p *pattern, value string) bool {
+	submatches := p.regex.FindStringSubmatch(value)
+	if submatches == nil {
+		return false
+	}
+
+	d.confidence += p.confidence
+	if version := resolveVersion(p.version, submatches); len(version) > len(d.version) {
+		d.version = version
+	}
+	return true
+}
+
+func (d *detection) matchValues(patterns []*pattern, values []string) {
+	for _, p := range patterns {
+		for _, value := range values {
+			if d.add(p, value) {
+				break
+			}
+		}
+	}
+}
+
+func (d *detection) matchMap(patterns map[string][]*pattern, values map[string][]string) {
+	for key, keyPatterns := range patterns {
+		if keyValues, ok := values[key]; ok {
+			d.matchValues(keyPatterns, keyValues)
+		}
+	}
+}
+
+func (signature *Signature) detect(p *page) detection {
+	var result detection
+
+	result.matchValues(signature.url, []string{p.url})
+	result.matchMap(signature.headers, p.headers)
+	result.matchMap(signature.cookies, p.cookies)
+	result.matchMap(signature.meta, p.meta)
+	result.matchValues(signature.scriptSrc, p.scriptSrc)
+	result.matchValues(signature.html, []string{p.html})
+
+	if result.confidence > 100 {
+		result.confidence = 100
+	}
+
+	return result
+}
+
+// returns the technologies detected on the page, including the technologies they imply
+func (database *Database) Detect(body []byte, pageResult crawler.PageResult) []crawler.Technology {
+	p := newPage(body, pageResult)
+
+	detected := make(map[string]*detection)
+	for name, signature := range database.Technologies {
+		if result := signature.detect(p); result.confidence > 0 {
+			detected[name] = &result
+		}
+	}
+
+	// resolves the implied technologies, the implying technologies being resolved first
+	queue := make([]string, 0, len(detected))
+	for name := range detected {
+		queue = append(queue, name)
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		signature, ok := database.Technologies[name]
+		if !ok {
+			continue
+		}
+
+		for _, implied := range signature.Implies {
+			parts := strings.Split(implied, PATTERN_TAG_SEPARATOR)
+			confidence := detected[name].confidence
+			for _, tag := range parts[1:] {
+				if value, err := strconv.Atoi(strings.TrimPrefix(tag, "confidence:")); err == nil && value < confidence {
+					confidence = value
+				}
+			}
+
+			if current, ok := detected[parts[0]]; ok {
+				if current.confidence >= confidence {
+					continue
+				}
+				current.confidence = confidence
+			} else {
+				detected[parts[0]] = &detection{confidence: confidence}
+			}
+			queue = append(queue, parts[0])
+		}
+	}
+
+	technologies := make([]crawler.Technology, 0, len(detected))
+	for name, result := range detected {
+		technology := crawler.Technology{
+			Name:       name,
+			Version:    result.version,
+			Confidence: result.confidence,
+		}
+		if signature, ok := database.Technologies[name]; ok {
+			technology.Categories = database.getCategories(signature)
+		}
+		technologies = append(technologies, technology)
+	}
+
+	return crawler.MergeTechnologies(technologies)
+}
+
+// returns a PageAnalyzer storing the technologies detected on each page
+func (database *Database) Analyzer() crawler.PageAnalyzer {
+	return func(body []byte, pageResult *crawler.PageResult) {
+		pageResult.Technologies = database.Detect(body, *pageResult)
+	}
+}
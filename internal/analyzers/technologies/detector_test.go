@@ -0,0 +1,204 @@
+package technologies
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+const TEST_DATABASE = `{
+  "categories": {"1": {"name": "CMS"}, "22": {"name": "Web servers"}, "27": {"name": "Programming languages"}, "57": {"name": "Static site generator"}, "59": {"name": "JavaScript libraries"}},
+  "technologies": {
+    "Nginx": {"cats": [22], "headers": {"Server": "nginx(?:/([\\d.]+))?\\;version:\\1"}},
+    "PHP": {"cats": [27], "headers": {"X-Powered-By": "^php/?([\\d.]+)?\\;version:\\1"}, "cookies": {"PHPSESSID": ""}},
+    "WordPress": {
+      "cats": [1],
+      "meta": {"generator": "^WordPress(?: ([\\d.]+))?\\;version:\\1"},
+      "scriptSrc": "/wp-(?:content|includes)/(?:.*ver=([\\d.]+))?\\;version:\\1",
+      "implies": ["PHP", "MySQL\\;confidence:50"]
+    },
+    "Rails": {
+      "cats": [27],
+      "cookies": {"_session_id": "\\;confidence:40"},
+      "meta": {"csrf-param": "^authenticity_token$\\;confidence:50"}
+    },
+    "Gatsby": {"cats": [57], "meta": {"generator": "^Gatsby(?: ([\\d.]+))?\\;version:\\1?\\1:unknown"}},
+    "jQuery": {"cats": [59], "scriptSrc": ["jquery[.-]([\\d.]+)(?:\\.min)?\\.js\\;version:\\1", "/jquery(?:\\.min)?\\.js"]},
+    "Broken": {"cats": [22], "html": "(unclosed", "headers": {"X-Broken": ""}}
+  }
+}`
+
+func testDatabase(t *testing.T) *Database {
+	database, err := ParseDatabase([]byte(TEST_DATABASE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name         string
+		headers      http.Header
+		body         string
+		technologies []crawler.Technology
+	}{
+		{"nothing", http.Header{"Server": {"unknown"}}, "<html></html>", []crawler.Technology{}},
+		{"header version", http.Header{"Server": {"nginx/1.25.3"}}, "", []crawler.Technology{
+			{Name: "Nginx", Version: "1.25.3", Categories: []string{"Web servers"}, Confidence: 100},
+		}},
+		{"header without version", http.Header{"Server": {"nginx"}}, "", []crawler.Technology{
+			{Name: "Nginx", Categories: []string{"Web servers"}, Confidence: 100},
+		}},
+		{"header name case", http.Header{"x-powered-by": {"PHP/8.2.1"}}, "", []crawler.Technology{
+			{Name: "PHP", Version: "8.2.1", Categories: []string{"Programming languages"}, Confidence: 100},
+		}},
+		{"cookie", http.Header{"Set-Cookie": {"PHPSESSID=abc; Path=/"}}, "", []crawler.Technology{
+			{Name: "PHP", Categories: []string{"Programming languages"}, Confidence: 100},
+		}},
+		{"meta version and implies", nil, `<meta name="generator" content="WordPress 6.4.2">`, []crawler.Technology{
+			{Name: "MySQL", Confidence: 50},
+			{Name: "PHP", Categories: []string{"Programming languages"}, Confidence: 100},
+			{Name: "WordPress", Version: "6.4.2", Categories: []string{"CMS"}, Confidence: 100},
+		}},
+		{"meta attributes order", nil, `<META content='WordPress 6.1' NAME='Generator'/>`, []crawler.Technology{
+			{Name: "MySQL", Confidence: 50},
+			{Name: "PHP", Categories: []string{"Programming languages"}, Confidence: 100},
+			{Name: "WordPress", Version: "6.1", Categories: []string{"CMS"}, Confidence: 100},
+		}},
+		{"implied technology detected", http.Header{"X-Powered-By": {"PHP/7.4"}}, `<script src="/wp-includes/js/a.js"></script>`, []crawler.Technology{
+			{Name: "MySQL", Confidence: 50},
+			{Name: "PHP", Version: "7.4", Categories: []string{"Programming languages"}, Confidence: 100},
+			{Name: "WordPress", Categories: []string{"CMS"}, Confidence: 100},
+		}},
+		{"most precise version", nil, `<meta name="generator" content="WordPress 6"><script src="/wp-content/app.js?ver=6.4.2"></script>`, []crawler.Technology{
+			{Name: "MySQL", Confidence: 50},
+			{Name: "PHP", Categories: []string{"Programming languages"}, Confidence: 100},
+			{Name: "WordPress", Version: "6.4.2", Categories: []string{"CMS"}, Confidence: 100},
+		}},
+		{"script src version", nil, `<script type="text/javascript" src="https://cdn.example.com/jquery-3.7.1.min.js"></script>`, []crawler.Technology{
+			{Name: "jQuery", Version: "3.7.1", Categories: []string{"JavaScript libraries"}, Confidence: 100},
+		}},
+		{"script src without version", nil, `<script src=/js/jquery.min.js></script>`, []crawler.Technology{
+			{Name: "jQuery", Categories: []string{"JavaScript libraries"}, Confidence: 100},
+		}},
+		{"ternary version", nil, `<meta name="generator" content="Gatsby">`, []crawler.Technology{
+			{Name: "Gatsby", Version: "unknown", Categories: []string{"Static site generator"}, Confidence: 100},
+		}},
+		{"ternary version set", nil, `<meta name="generator" content="Gatsby 5.12">`, []crawler.Technology{
+			{Name: "Gatsby", Version: "5.12", Categories: []string{"Static site generator"}, Confidence: 100},
+		}},
+		{"partial confidence", http.Header{"Set-Cookie": {"_session_id=abc"}}, "", []crawler.Technology{
+			{Name: "Rails", Categories: []string{"Programming languages"}, Confidence: 40},
+		}},
+		{"summed confidence", http.Header{"Set-Cookie": {"_session_id=abc"}}, `<meta name="csrf-param" content="authenticity_token">`, []crawler.Technology{
+			{Name: "Rails", Categories: []string{"Programming languages"}, Confidence: 90},
+		}},
+		{"invalid pattern skipped", http.Header{"X-Broken": {"1"}}, "(unclosed", []crawler.Technology{
+			{Name: "Broken", Categories: []string{"Web servers"}, Confidence: 100},
+		}},
+	}
+
+	database := testDatabase(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pageResult := crawler.PageResult{
+				Url:     crawler.PageRequestFromUrl("https://example.com/"),
+				Headers: test.headers,
+			}
+
+			technologies := database.Detect([]byte(test.body), pageResult)
+			if !reflect.DeepEqual(technologies, test.technologies) {
+				t.Errorf("expected %+v, got %+v", test.technologies, technologies)
+			}
+		})
+	}
+}
+
+func TestDetectConfidenceCap(t *testing.T) {
+	database, err := ParseDatabase([]byte(`{"technologies": {"Test": {
+		"headers": {"X-Test": "\\;confidence:80"},
+		"html": "test\\;confidence:80"
+	}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageResult := crawler.PageResult{Headers: http.Header{"X-Test": {"1"}}}
+	if technologies := database.Detect([]byte("test"), pageResult); len(technologies) != 1 || technologies[0].Confidence != 100 {
+		t.Errorf("expected a confidence of 100, got %+v", technologies)
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		submatches []string
+		result     string
+	}{
+		{"", []string{"nginx/1.2", "1.2"}, ""},
+		{`\1`, []string{"nginx/1.2", "1.2"}, "1.2"},
+		{`\1`, []string{"nginx", ""}, ""},
+		{`\2`, []string{"nginx/1.2", "1.2"}, ""},
+		{`\1.\2`, []string{"v1_2", "1", "2"}, "1.2"},
+		{`\1?modern:legacy`, []string{"a", "x"}, "modern"},
+		{`\1?modern:legacy`, []string{"a", ""}, "legacy"},
+		{`\1?\1:unknown`, []string{"a", "5.12"}, "5.12"},
+		{`\1?\1:unknown`, []string{"a", ""}, "unknown"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			if result := resolveVersion(test.version, test.submatches); result != test.result {
+				t.Errorf("expected %q, got %q", test.result, result)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	database := testDatabase(t)
+	other, err := ParseDatabase([]byte(`{
+		"categories": {"1": {"name": "Content management"}},
+		"technologies": {"Nginx": {"cats": [1], "headers": {"Server": "^custom$"}}, "Custom": {"headers": {"X-Custom": ""}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := database.Merge(other)
+	if len(merged.Technologies) != len(database.Technologies)+1 || merged.Categories["1"].Name != "Content management" {
+		t.Fatalf("unexpected merged database %+v", merged)
+	}
+
+	pageResult := crawler.PageResult{Headers: http.Header{"Server": {"nginx/1.2"}}}
+	if technologies := merged.Detect(nil, pageResult); len(technologies) != 0 {
+		t.Errorf("expected the signature of other to replace the one of database, got %+v", technologies)
+	}
+	if database.Merge(nil) != database {
+		t.Errorf("expected database to be returned when merged with nil")
+	}
+}
+
+func TestDefaultDatabase(t *testing.T) {
+	database := DefaultDatabase()
+	pageResult := crawler.PageResult{
+		Url:     crawler.PageRequestFromUrl("https://example.com/"),
+		Headers: http.Header{"Server": {"nginx/1.25.3"}},
+	}
+
+	technologies := database.Detect([]byte(`<meta name="generator" content="WordPress 6.4.2">`), pageResult)
+	versions := make(map[string]string)
+	for _, technology := range technologies {
+		versions[technology.Name] = technology.Version
+	}
+
+	if versions["Nginx"] != "1.25.3" || versions["WordPress"] != "6.4.2" {
+		t.Errorf("unexpected technologies %+v", technologies)
+	}
+	if _, ok := versions["PHP"]; !ok {
+		t.Errorf("expected PHP implied by WordPress, got %+v", technologies)
+	}
+}
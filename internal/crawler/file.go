@@ -0,0 +1,41 @@
+package crawler
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+)
+
+// calls write with a temporary file renamed to fileName once written and synced,
+// for fileName to never hold a partial content, the temporary file being removed on error
+func WriteFileAtomic(fileName string, write func(file *bufio.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := file.Name()
+
+	// os.CreateTemp creating the file readable by its owner only
+	err = file.Chmod(0644)
+	writer := bufio.NewWriter(file)
+	if err == nil {
+		err = write(writer)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, fileName)
+	}
+
+	if err != nil {
+		os.Remove(tmpName)
+	}
+	return err
+}
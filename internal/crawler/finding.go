@@ -0,0 +1,52 @@
+package crawler
+
+import "strings"
+
+type Severity string
+
+const (
+	SEVERITY_INFO   Severity = "info"
+	SEVERITY_LOW    Severity = "low"
+	SEVERITY_MEDIUM Severity = "medium"
+	SEVERITY_HIGH   Severity = "high"
+)
+
+// returns the rank of the severity, higher being more severe
+func (s Severity) Rank() int {
+	switch s {
+	case SEVERITY_LOW:
+		return 1
+	case SEVERITY_MEDIUM:
+		return 2
+	case SEVERITY_HIGH:
+		return 3
+	}
+	return 0
+}
+
+// returns the severity named name, false if unknown
+func ParseSeverity(name string) (Severity, bool) {
+	severity := Severity(strings.ToLower(name))
+	switch severity {
+	case SEVERITY_INFO, SEVERITY_LOW, SEVERITY_MEDIUM, SEVERITY_HIGH:
+		return severity, true
+	}
+	return SEVERITY_INFO, false
+}
+
+// a structured result attached to the PageResult of the request it was found on
+type Finding struct {
+	// the kind of the finding ("secret", "sql-error"...)
+	Type     string   `json:"type"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+
+	// the data proving the finding (a matched value, a response excerpt...)
+	Evidence string `json:"evidence,omitempty"`
+
+	// where the evidence was found in the response ("body:12", "header:Set-Cookie"...)
+	Location string `json:"location,omitempty"`
+
+	// the plugin or analyzer which produced the finding
+	Source string `json:"source,omitempty"`
+}
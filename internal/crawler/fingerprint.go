@@ -0,0 +1,59 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+)
+
+// the number of consecutive words hashed together when fingerprinting a body
+const SHINGLE_SIZE = 3
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// computes the simhash of a body over shingles of SHINGLE_SIZE words,
+// near-duplicate bodies having fingerprints with a low hamming distance
+func Fingerprint(body []byte) uint64 {
+	words := wordPattern.FindAll(body, -1)
+	if len(words) == 0 {
+		return 0
+	}
+
+	shingleCount := len(words) - SHINGLE_SIZE + 1
+	if shingleCount < 1 {
+		shingleCount = 1
+	}
+
+	var weights [64]int
+	hash := fnv.New64a()
+	for i := 0; i < shingleCount; i++ {
+		hash.Reset()
+		for j := i; j < i+SHINGLE_SIZE && j < len(words); j++ {
+			hash.Write(words[j])
+			hash.Write([]byte{' '})
+		}
+
+		sum := hash.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+
+	return fingerprint
+}
+
+// returns the number of bits differing between two fingerprints
+func HammingDistance(f1 uint64, f2 uint64) int {
+	return bits.OnesCount64(f1 ^ f2)
+}
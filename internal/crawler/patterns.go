@@ -0,0 +1,34 @@
+package crawler
+
+// the endpoints (base urls) added to the urls to fetch per url pattern of every domain,
+// see UrlPattern. The endpoints without any variable segment are not kept.
+// The endpoints are added when their urls are pushed, for the filters clustering
+// the urls not to go through the urls to fetch and the fetched urls.
+type EndpointPatterns map[string]map[string]map[string]bool
+
+// adds the endpoint of url to the endpoints of its pattern
+func (p EndpointPatterns) Add(url PageRequest) {
+	pattern := UrlPattern(url.BaseUrl)
+	if pattern == url.BaseUrl {
+		return
+	}
+
+	domainName := ExtractDomainName(url.BaseUrl)
+	domainPatterns, ok := p[domainName]
+	if !ok {
+		domainPatterns = make(map[string]map[string]bool)
+		p[domainName] = domainPatterns
+	}
+
+	endpoints, ok := domainPatterns[pattern]
+	if !ok {
+		endpoints = make(map[string]bool)
+		domainPatterns[pattern] = endpoints
+	}
+	endpoints[url.BaseUrl] = true
+}
+
+// returns the endpoints of pattern in the domain, which must not be modified
+func (p EndpointPatterns) Endpoints(domainName string, pattern string) map[string]bool {
+	return p[domainName][pattern]
+}
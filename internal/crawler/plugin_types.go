@@ -1,5 +1,7 @@
 package crawler
 
+import "net/http"
+
 // the keys of Attachements
 type Attachements map[string]string
 
@@ -18,3 +20,42 @@ type OnPageResultAdded func(
 	pageResults PageResult,
 	domainResults DomainResultEntry,
 ) Attachements
+
+// called with the body of every fetched page before the hooks,
+// enriches the page result (detected technologies...)
+type PageAnalyzer func(body []byte, pageResult *PageResult)
+
+// called with the body of every fetched page, returns the findings
+// attached to the PageResult of the page
+type AnalyzePage func(body []byte, pageResult PageResult) []Finding
+
+// called once when the crawl starts with the urls to fetch
+type OnStart func(urlsToFetch []PageRequest)
+
+// called before a request is sent, the request can be modified (headers, signature, url...)
+// returns false if the request should be skipped
+type BeforeRequest func(request *http.Request, url PageRequest) bool
+
+// called with the urls found on a fetched page, returns the urls to keep (filtered or extended)
+type OnUrlsFound func(pageResult PageResult, foundUrls []PageRequest) []PageRequest
+
+// called when a request could not be made or its response could not be read
+type OnError func(url PageRequest, err error)
+
+// called once when the crawl ends, done being false if the crawl has been stopped
+type OnFinish func(data CrawlerData, done bool)
+
+// the hooks called for the pages of a domain, nil hooks are ignored
+type DomainHooks struct {
+	BeforeRequest
+	OnPageResultAdded
+	OnUrlsFound
+	OnError
+	AnalyzePage
+}
+
+// the hooks called once per crawl, nil hooks are ignored
+type CrawlHooks struct {
+	OnStart
+	OnFinish
+}
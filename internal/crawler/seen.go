@@ -0,0 +1,257 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// the capacity of the first filter of a scalable bloom filter
+const BLOOM_INITIAL_CAPACITY = 100000
+
+// the capacity ratio between two consecutive filters of a scalable bloom filter
+const BLOOM_GROWTH_FACTOR = 2
+
+// the error rate ratio between two consecutive filters of a scalable bloom filter
+const BLOOM_TIGHTENING_RATIO = 0.8
+
+type bloomFilter struct {
+	Bits     []byte `json:"bits"`
+	Hashes   int    `json:"hashes"`
+	Capacity int    `json:"capacity"`
+	Count    int    `json:"count"`
+}
+
+// returns a filter holding capacity keys with the given false positive rate
+func newBloomFilter(capacity int, errorRate float64) *bloomFilter {
+	bits := math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Ceil(bits / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &bloomFilter{
+		Bits:     make([]byte, (int(bits)+7)/8),
+		Hashes:   hashes,
+		Capacity: capacity,
+	}
+}
+
+// returns the two hashes of key combined into the positions of the bits of key
+func bloomHashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+
+	// an odd step visits distinct positions
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+func (f *bloomFilter) position(h1 uint64, h2 uint64, i int) (int, byte) {
+	bit := (h1 + uint64(i)*h2) % uint64(len(f.Bits)*8)
+	return int(bit / 8), 1 << (bit % 8)
+}
+
+func (f *bloomFilter) contains(h1 uint64, h2 uint64) bool {
+	for i := 0; i < f.Hashes; i++ {
+		index, mask := f.position(h1, h2, i)
+		if f.Bits[index]&mask == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(h1 uint64, h2 uint64) {
+	for i := 0; i < f.Hashes; i++ {
+		index, mask := f.position(h1, h2, i)
+		f.Bits[index] |= mask
+	}
+	f.Count++
+}
+
+// a bloom filter growing with the number of keys, its false positive rate
+// staying under ErrorRate (Almeida et al., Scalable Bloom Filters)
+type ScalableBloomFilter struct {
+	ErrorRate float64        `json:"error_rate"`
+	Filters   []*bloomFilter `json:"filters"`
+}
+
+func NewScalableBloomFilter(capacity int, errorRate float64) *ScalableBloomFilter {
+	if capacity <= 0 {
+		capacity = BLOOM_INITIAL_CAPACITY
+	}
+
+	return &ScalableBloomFilter{
+		ErrorRate: errorRate,
+		Filters: []*bloomFilter{
+			newBloomFilter(capacity, errorRate*(1-BLOOM_TIGHTENING_RATIO)),
+		},
+	}
+}
+
+func (b *ScalableBloomFilter) Contains(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for _, f := range b.Filters {
+		if f.contains(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// adds key to the filter, returns false if key is (probably) already present
+func (b *ScalableBloomFilter) Add(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for _, f := range b.Filters {
+		if f.contains(h1, h2) {
+			return false
+		}
+	}
+
+	last := b.Filters[len(b.Filters)-1]
+	if last.Count >= last.Capacity {
+		// the error rates of the filters are a geometric series summing up to ErrorRate
+		errorRate := b.ErrorRate * (1 - BLOOM_TIGHTENING_RATIO) * math.Pow(BLOOM_TIGHTENING_RATIO, float64(len(b.Filters)))
+		last = newBloomFilter(last.Capacity*BLOOM_GROWTH_FACTOR, errorRate)
+		b.Filters = append(b.Filters, last)
+	}
+
+	last.add(h1, h2)
+	return true
+}
+
+func (b *ScalableBloomFilter) Len() int {
+	count := 0
+	for _, f := range b.Filters {
+		count += f.Count
+	}
+	return count
+}
+
+func (b *ScalableBloomFilter) copy() *ScalableBloomFilter {
+	result := &ScalableBloomFilter{
+		ErrorRate: b.ErrorRate,
+		Filters:   make([]*bloomFilter, len(b.Filters)),
+	}
+	for i, f := range b.Filters {
+		filterCopy := *f
+		filterCopy.Bits = append([]byte{}, f.Bits...)
+		result.Filters[i] = &filterCopy
+	}
+	return result
+}
+
+// the set of the urls added to the urls to fetch, keyed by PageRequest.Key,
+// either exact or a scalable bloom filter bounding its memory, which can
+// reject a new url as already seen with a probability of its error rate
+type SeenSet struct {
+	keys  map[string]bool
+	bloom *ScalableBloomFilter
+}
+
+// returns an exact set
+func NewSeenSet() *SeenSet {
+	return &SeenSet{
+		keys: make(map[string]bool),
+	}
+}
+
+// returns a set backed by a scalable bloom filter, capacity being the
+// capacity of its first filter (BLOOM_INITIAL_CAPACITY if 0)
+func NewBloomSeenSet(capacity int, errorRate float64) *SeenSet {
+	return &SeenSet{
+		bloom: NewScalableBloomFilter(capacity, errorRate),
+	}
+}
+
+func (s *SeenSet) IsBloom() bool {
+	return s.bloom != nil
+}
+
+func (s *SeenSet) Contains(url PageRequest) bool {
+	if s.bloom != nil {
+		return s.bloom.Contains(url.Key())
+	}
+	return s.keys[url.Key()]
+}
+
+// adds url to the set, returns false if it has already been seen
+func (s *SeenSet) Add(url PageRequest) bool {
+	if s.bloom != nil {
+		return s.bloom.Add(url.Key())
+	}
+
+	key := url.Key()
+	if s.keys[key] {
+		return false
+	}
+	s.keys[key] = true
+	return true
+}
+
+func (s *SeenSet) Len() int {
+	if s.bloom != nil {
+		return s.bloom.Len()
+	}
+	return len(s.keys)
+}
+
+func (s *SeenSet) Copy() *SeenSet {
+	if s.bloom != nil {
+		return &SeenSet{bloom: s.bloom.copy()}
+	}
+
+	keys := make(map[string]bool, len(s.keys))
+	for key := range s.keys {
+		keys[key] = true
+	}
+	return &SeenSet{keys: keys}
+}
+
+type seenSetJson struct {
+	Keys  []string             `json:"keys,omitempty"`
+	Bloom *ScalableBloomFilter `json:"bloom,omitempty"`
+}
+
+func (s *SeenSet) MarshalJSON() ([]byte, error) {
+	if s.bloom != nil {
+		return json.Marshal(seenSetJson{Bloom: s.bloom})
+	}
+
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	return json.Marshal(seenSetJson{Keys: keys})
+}
+
+func (s *SeenSet) UnmarshalJSON(data []byte) error {
+	var value seenSetJson
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	if value.Bloom != nil {
+		if len(value.Bloom.Filters) == 0 {
+			return fmt.Errorf("crawler::SeenSet.UnmarshalJSON -> bloom filter without filters")
+		}
+		for _, f := range value.Bloom.Filters {
+			if len(f.Bits) == 0 || f.Hashes <= 0 {
+				return fmt.Errorf("crawler::SeenSet.UnmarshalJSON -> invalid bloom filter")
+			}
+		}
+		s.keys = nil
+		s.bloom = value.Bloom
+		return nil
+	}
+
+	s.bloom = nil
+	s.keys = make(map[string]bool, len(value.Keys))
+	for _, key := range value.Keys {
+		s.keys[key] = true
+	}
+	return nil
+}
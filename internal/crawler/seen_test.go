@@ -0,0 +1,206 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func testRequest(i int) PageRequest {
+	return PageRequest{
+		BaseUrl:    fmt.Sprintf("https://example.com/page/%d", i),
+		Parameters: map[string]string{"id": fmt.Sprint(i)},
+	}
+}
+
+func TestScalableBloomFilterGrowth(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  int
+		keys      int
+		errorRate float64
+		filters   int
+	}{
+		{"under capacity", 1000, 999, 0.01, 1},
+		{"at capacity", 1000, 1000, 0.01, 1},
+		{"one growth", 1000, 2500, 0.01, 2},
+		{"several growths", 1000, 10000, 0.01, 4},
+		{"default capacity", 0, 1000, 0.001, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := NewScalableBloomFilter(test.capacity, test.errorRate)
+			for i := 0; i < test.keys; i++ {
+				filter.Add(fmt.Sprint("key-", i))
+			}
+
+			// no false negatives
+			for i := 0; i < test.keys; i++ {
+				if !filter.Contains(fmt.Sprint("key-", i)) {
+					t.Fatalf("key %d not found", i)
+				}
+			}
+
+			if len(filter.Filters) != test.filters {
+				t.Errorf("expected %d filters, got %d", test.filters, len(filter.Filters))
+			}
+			for i, f := range filter.Filters {
+				if f.Count > f.Capacity {
+					t.Errorf("filter %d holds %d keys for a capacity of %d", i, f.Count, f.Capacity)
+				}
+				if i > 0 && f.Capacity != filter.Filters[i-1].Capacity*BLOOM_GROWTH_FACTOR {
+					t.Errorf("filter %d has a capacity of %d", i, f.Capacity)
+				}
+			}
+
+			// the keys rejected as already present are not counted
+			if count := filter.Len(); count > test.keys || float64(count) < float64(test.keys)*(1-test.errorRate) {
+				t.Errorf("unexpected count %d for %d keys", count, test.keys)
+			}
+		})
+	}
+}
+
+func TestScalableBloomFilterFalsePositiveRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  int
+		keys      int
+		errorRate float64
+	}{
+		{"single filter", 10000, 10000, 0.01},
+		{"grown filter", 1000, 20000, 0.01},
+		{"low error rate", 1000, 20000, 0.001},
+	}
+
+	const probes = 100000
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := NewScalableBloomFilter(test.capacity, test.errorRate)
+			for i := 0; i < test.keys; i++ {
+				filter.Add(fmt.Sprint("key-", i))
+			}
+
+			falsePositives := 0
+			for i := 0; i < probes; i++ {
+				if filter.Contains(fmt.Sprint("other-", i)) {
+					falsePositives++
+				}
+			}
+
+			if rate := float64(falsePositives) / probes; rate > test.errorRate {
+				t.Errorf("false positive rate %f over %f", rate, test.errorRate)
+			}
+		})
+	}
+}
+
+func TestSeenSetJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		set  *SeenSet
+		keys int
+	}{
+		{"empty exact", NewSeenSet(), 0},
+		{"exact", NewSeenSet(), 1000},
+		{"empty bloom", NewBloomSeenSet(100, 0.01), 0},
+		{"bloom", NewBloomSeenSet(100, 0.01), 1000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := test.keys
+			for j := 0; j < keys; j++ {
+				test.set.Add(testRequest(j))
+			}
+
+			body, err := json.Marshal(test.set)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var set SeenSet
+			if err = json.Unmarshal(body, &set); err != nil {
+				t.Fatal(err)
+			}
+
+			if set.IsBloom() != test.set.IsBloom() || set.Len() != test.set.Len() {
+				t.Errorf("expected bloom %v and %d keys, got %v and %d", test.set.IsBloom(), test.set.Len(), set.IsBloom(), set.Len())
+			}
+			for j := 0; j < keys; j++ {
+				if !set.Contains(testRequest(j)) {
+					t.Fatalf("url %d not found", j)
+				}
+			}
+			if !test.set.IsBloom() && set.Contains(testRequest(keys)) {
+				t.Errorf("url %d found", keys)
+			}
+		})
+	}
+}
+
+func TestSeenSetInvalidJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no filters", `{"bloom": {"error_rate": 0.01, "filters": []}}`},
+		{"empty filter", `{"bloom": {"error_rate": 0.01, "filters": [{"bits": "", "hashes": 3, "capacity": 10}]}}`},
+		{"no hashes", `{"bloom": {"error_rate": 0.01, "filters": [{"bits": "AA==", "hashes": 0, "capacity": 10}]}}`},
+		{"not an object", `[]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var set SeenSet
+			if err := json.Unmarshal([]byte(test.body), &set); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func BenchmarkAddUrlToStore(b *testing.B) {
+	sets := []struct {
+		name    string
+		newSeen func() *SeenSet
+	}{
+		{"exact", NewSeenSet},
+		{"bloom", func() *SeenSet { return NewBloomSeenSet(0, 0.001) }},
+	}
+
+	scope := &Scope{}
+	shouldAdd := func(url PageRequest, data *CrawlerData) bool {
+		return true
+	}
+
+	for _, set := range sets {
+		for _, size := range []int{1e3, 1e5, 1e6} {
+			b.Run(fmt.Sprintf("%s/%d", set.name, size), func(b *testing.B) {
+				store := NewMemoryStore(&CrawlerData{Seen: set.newSeen()})
+				for i := 0; i < size; i++ {
+					store.PushUrl(testRequest(i))
+				}
+
+				// half of the urls already seen
+				urls := make([]PageRequest, b.N)
+				for i := range urls {
+					if i%2 == 0 {
+						urls[i] = testRequest(i % size)
+					} else {
+						urls[i] = testRequest(size + i)
+					}
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for _, url := range urls {
+					if _, err := AddUrlToStore(store, url, shouldAdd, scope); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
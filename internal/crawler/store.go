@@ -0,0 +1,177 @@
+package crawler
+
+// the storage of the urls to fetch and of the results of a crawl,
+// the calls modifying the store being serialized by the crawler
+type Store interface {
+	// adds url to the urls to fetch, returns false if it has already been added
+	PushUrl(url PageRequest) (bool, error)
+
+	// returns true if url has already been added to the urls to fetch
+	IsSeen(url PageRequest) bool
+
+	// puts back urls popped but not fetched (the crawl being stopped), without duplicate check
+	Requeue(urls []PageRequest) error
+
+	// removes and returns the next url to fetch, false if there is none
+	PopUrl() (PageRequest, bool, error)
+
+	// returns the number of urls to fetch
+	Size() int
+
+	// stores the result of a fetched page and the attachements of its endpoint
+	AddPageResult(result PageResult, attachements Attachements) error
+
+	// returns the results and attachements of an endpoint, false if it has not been fetched
+	GetEntry(domainName string, baseUrl string) (DomainResultEntry, bool, error)
+
+	// returns the data the ShouldAddFilters are run against, which must not be modified:
+	// the fetched urls (see the implementations for the fields of their results), the seen set
+	// and the endpoint patterns, the UrlsToFetch being empty whatever the store
+	FilterData() *CrawlerData
+
+	// returns a copy of the urls to fetch
+	GetUrlsToFetch() ([]PageRequest, error)
+
+	// calls handle with a copy of every entry, the entries of a domain being handled
+	// one after the other, stops at the first error returned by handle
+	ForEachEntry(handle func(domainName string, baseUrl string, entry DomainResultEntry) error) error
+
+	// returns a copy of the whole data of the crawl
+	Snapshot() (CrawlerData, error)
+
+	// makes the state of the store durable, pending being the urls being fetched
+	Sync(pending []PageRequest) error
+
+	Close() error
+}
+
+// returns why url would not be added to the urls to fetch of store or REJECT_NONE,
+// without modifying store, for the filters to run without locking it for writing
+func CheckUrlToAdd(store Store, url PageRequest, shouldAdd ShouldAddFilter, scope *Scope) RejectReason {
+
+	if !scope.UrlInScope(url) {
+		return REJECT_OUT_OF_SCOPE
+	}
+
+	// the seen set being consulted first, the filters are only run on new urls
+	if store.IsSeen(url) {
+		return REJECT_DUPLICATE
+	}
+
+	if !shouldAdd(url, store.FilterData()) {
+		return REJECT_FILTERED
+	}
+
+	return REJECT_NONE
+}
+
+// adds url to the urls to fetch of store, returns why it has not been added or REJECT_NONE
+func AddUrlToStore(store Store, url PageRequest, shouldAdd ShouldAddFilter, scope *Scope) (RejectReason, error) {
+
+	if reason := CheckUrlToAdd(store, url, shouldAdd, scope); reason != REJECT_NONE {
+		return reason, nil
+	}
+
+	added, err := store.PushUrl(url)
+	if err != nil {
+		return REJECT_NONE, err
+	}
+
+	if !added {
+		return REJECT_DUPLICATE, nil
+	}
+	return REJECT_NONE, nil
+}
+
+// a Store keeping the whole data of the crawl in memory, the ShouldAddFilters
+// being run against the data itself without its urls to fetch
+type MemoryStore struct {
+	*CrawlerData
+}
+
+func NewMemoryStore(data *CrawlerData) *MemoryStore {
+	if data == nil {
+		data = NewCrawlerData()
+	}
+
+	if data.FetchedUrls == nil {
+		data.FetchedUrls = make(FetchedUrls)
+	}
+
+	// built before the filters read them concurrently
+	data.SeenSet()
+	data.EndpointPatterns()
+
+	return &MemoryStore{data}
+}
+
+func (s *MemoryStore) PushUrl(url PageRequest) (bool, error) {
+	return s.pushUrlToFetch(url), nil
+}
+
+func (s *MemoryStore) IsSeen(url PageRequest) bool {
+	return s.SeenSet().Contains(url)
+}
+
+func (s *MemoryStore) Requeue(urls []PageRequest) error {
+	s.UrlsToFetch = append(s.UrlsToFetch, urls...)
+	return nil
+}
+
+func (s *MemoryStore) PopUrl() (PageRequest, bool, error) {
+	url, ok := s.PopUrlToFetch()
+	return url, ok, nil
+}
+
+func (s *MemoryStore) Size() int {
+	return len(s.UrlsToFetch)
+}
+
+func (s *MemoryStore) AddPageResult(result PageResult, attachements Attachements) error {
+	s.AddFetchedUrl(result)
+	s.FetchedUrls[ExtractDomainName(result.Url.BaseUrl)].AddAttachements(result.Url.BaseUrl, attachements)
+	return nil
+}
+
+func (s *MemoryStore) GetEntry(domainName string, baseUrl string) (DomainResultEntry, bool, error) {
+	entry, ok := s.FetchedUrls[domainName][baseUrl]
+	if !ok {
+		return DomainResultEntry{}, false, nil
+	}
+	return entry.Copy(), true, nil
+}
+
+func (s *MemoryStore) FilterData() *CrawlerData {
+	return &CrawlerData{
+		FetchedUrls: s.FetchedUrls,
+		Seen:        s.Seen,
+		Patterns:    s.Patterns,
+	}
+}
+
+func (s *MemoryStore) GetUrlsToFetch() ([]PageRequest, error) {
+	return append(make([]PageRequest, 0, len(s.UrlsToFetch)), s.UrlsToFetch...), nil
+}
+
+func (s *MemoryStore) ForEachEntry(handle func(domainName string, baseUrl string, entry DomainResultEntry) error) error {
+	for domainName, domainResults := range s.FetchedUrls {
+		for baseUrl, entry := range domainResults {
+			if err := handle(domainName, baseUrl, entry.Copy()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Snapshot() (CrawlerData, error) {
+	return s.Copy(), nil
+}
+
+func (s *MemoryStore) Sync(pending []PageRequest) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
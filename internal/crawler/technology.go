@@ -0,0 +1,60 @@
+package crawler
+
+import "sort"
+
+// a technology (server, framework, cms, library...) detected on a page
+type Technology struct {
+	Name       string   `json:"name"`
+	Version    string   `json:"version,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+
+	// the confidence of the detection, from 0 to 100
+	Confidence int `json:"confidence"`
+}
+
+// merges the technologies detected on several pages, keeping for each
+// technology the highest confidence and the most precise version
+func MergeTechnologies(technologies ...[]Technology) []Technology {
+	merged := make(map[string]*Technology)
+	names := make([]string, 0)
+
+	for _, list := range technologies {
+		for _, technology := range list {
+			current, ok := merged[technology.Name]
+			if !ok {
+				entry := technology
+				merged[technology.Name] = &entry
+				names = append(names, technology.Name)
+				continue
+			}
+
+			if technology.Confidence > current.Confidence {
+				current.Confidence = technology.Confidence
+			}
+			if len(technology.Version) > len(current.Version) {
+				current.Version = technology.Version
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	result := make([]Technology, len(names))
+	for i, name := range names {
+		result[i] = *merged[name]
+	}
+
+	return result
+}
+
+// returns the technologies detected on the pages of domainName
+func (fetchedUrls FetchedUrls) GetTechnologies(domainName string) []Technology {
+	technologies := make([][]Technology, 0)
+	for _, entry := range fetchedUrls[domainName] {
+		for _, pageResult := range entry.PageResults {
+			technologies = append(technologies, pageResult.Technologies)
+		}
+	}
+
+	return MergeTechnologies(technologies...)
+}
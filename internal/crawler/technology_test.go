@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeTechnologies(t *testing.T) {
+	tests := []struct {
+		name         string
+		technologies [][]Technology
+		result       []Technology
+	}{
+		{"empty", nil, []Technology{}},
+		{"sorted", [][]Technology{
+			{{Name: "PHP", Confidence: 100}, {Name: "Nginx", Confidence: 100}},
+		}, []Technology{{Name: "Nginx", Confidence: 100}, {Name: "PHP", Confidence: 100}}},
+		{"highest confidence", [][]Technology{
+			{{Name: "Rails", Confidence: 40}},
+			{{Name: "Rails", Confidence: 90}},
+			{{Name: "Rails", Confidence: 50}},
+		}, []Technology{{Name: "Rails", Confidence: 90}}},
+		{"most precise version", [][]Technology{
+			{{Name: "WordPress", Version: "6", Confidence: 100}},
+			{{Name: "WordPress", Version: "6.4.2", Confidence: 100}},
+			{{Name: "WordPress", Confidence: 100}},
+		}, []Technology{{Name: "WordPress", Version: "6.4.2", Confidence: 100}}},
+		{"categories of the first", [][]Technology{
+			{{Name: "Nginx", Categories: []string{"Web servers"}, Confidence: 50}},
+			{{Name: "Nginx", Categories: []string{"Other"}, Version: "1.2", Confidence: 100}},
+		}, []Technology{{Name: "Nginx", Categories: []string{"Web servers"}, Version: "1.2", Confidence: 100}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := MergeTechnologies(test.technologies...); !reflect.DeepEqual(result, test.result) {
+				t.Errorf("expected %+v, got %+v", test.result, result)
+			}
+		})
+	}
+}
+
+func TestGetTechnologies(t *testing.T) {
+	data := NewCrawlerData()
+	for _, pageResult := range []PageResult{
+		{Url: PageRequestFromUrl("https://example.com/a"), Technologies: []Technology{{Name: "Nginx", Confidence: 100}}},
+		{Url: PageRequestFromUrl("https://example.com/b"), Technologies: []Technology{{Name: "Nginx", Version: "1.2", Confidence: 100}, {Name: "PHP", Confidence: 50}}},
+		{Url: PageRequestFromUrl("https://other.com/"), Technologies: []Technology{{Name: "Caddy", Confidence: 100}}},
+	} {
+		data.AddFetchedUrl(pageResult)
+	}
+
+	expected := []Technology{{Name: "Nginx", Version: "1.2", Confidence: 100}, {Name: "PHP", Confidence: 50}}
+	if result := data.FetchedUrls.GetTechnologies("example.com"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+}
@@ -4,27 +4,54 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 // a struct representing a request url
 //  PageRequest.BaseUrl: the url without any parameter nor anchors
 //  PageRequest.Parameters: the parameters
 //  PageRequest.Anchor: the anchor
+//  PageRequest.Method: the http method, GET if empty, the method of the form for a form action
+//  PageRequest.Depth: the number of links followed from the base urls of the crawl
+//  PageRequest.Parent: the url of the page the url was found on, empty for the base urls
 type PageRequest struct {
 	BaseUrl    string            `json:"base_url"`
 	Parameters map[string]string `json:"params"`
 	Anchor     string            `json:"anchor"`
+	Method     string            `json:"method,omitempty"`
+	Depth      int               `json:"depth,omitempty"`
+	Parent     string            `json:"parent,omitempty"`
 }
 
+const DEFAULT_METHOD = "GET"
+
+func (req *PageRequest) GetMethod() string {
+	if len(req.Method) == 0 {
+		return DEFAULT_METHOD
+	}
+	return strings.ToUpper(req.Method)
+}
+
+// returns true if both requests have the same Key, the method being compared unlike ToUrl
 func (req *PageRequest) Equals(r2 PageRequest) bool {
-	return req.ToUrl() == r2.ToUrl()
+	return req.Key() == r2.Key()
 }
 
 func (req *PageRequest) GetRootUrl() string {
 	return rootUrlPattern.FindString(req.BaseUrl)
 }
 
+// returns the path of the url without the root url, "/" if empty
+func (req *PageRequest) GetPath() string {
+	path := req.BaseUrl[len(req.GetRootUrl()):]
+	if len(path) == 0 {
+		return "/"
+	}
+	return path
+}
+
 func (req *PageRequest) getExtensions() string {
 	urlParts := strings.Split(req.BaseUrl, "/")
 	if len(urlParts[len(urlParts)-1]) <= 0 {
@@ -54,6 +81,37 @@ func (p PageRequest) ToUrl() string {
 	return url
 }
 
+// returns a key identifying the request, the parameters being sorted
+// unlike ToUrl, and the method being part of the key
+func (p PageRequest) Key() string {
+	names := make([]string, 0, len(p.Parameters))
+	for name := range p.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(p.GetMethod())
+	key.WriteString(" ")
+	key.WriteString(p.BaseUrl)
+	for i, name := range names {
+		if i == 0 {
+			key.WriteString("?")
+		} else {
+			key.WriteString("&")
+		}
+		key.WriteString(name)
+		key.WriteString("=")
+		key.WriteString(p.Parameters[name])
+	}
+	if len(p.Anchor) > 0 {
+		key.WriteString("#")
+		key.WriteString(p.Anchor)
+	}
+
+	return key.String()
+}
+
 func PageRequestFromUrl(url string) PageRequest {
 	parts := strings.Split(url, "?")
 	var req PageRequest
@@ -93,8 +151,50 @@ type PageResult struct {
 	ContentLength int64       `json:"content_length"`
 	Headers       http.Header `json:"headers"`
 
-	// the urls found on the fetched page
+	// the simhash of the body, see Fingerprint
+	Fingerprint uint64 `json:"fingerprint"`
+
+	// the technologies detected on the page
+	Technologies []Technology `json:"technologies,omitempty"`
+
+	// the findings of the plugins and analyzers on the page
+	Findings []Finding `json:"findings,omitempty"`
+
+	// the urls found on the fetched page, in scope or not
 	FoundUrls []PageRequest `json:"-"`
+
+	// the headers sent with the request, saved with the scan once the
+	// credentials they hold have been redacted, see RedactHeaders
+	RequestHeaders http.Header `json:"request_headers,omitempty"`
+
+	// the http version of the response ("HTTP/1.1")
+	Protocol string `json:"protocol,omitempty"`
+
+	Timings *Timings `json:"timings,omitempty"`
+
+	// the links in scope found on the page, before the OnUrlsFound hooks
+	Links []Link `json:"links,omitempty"`
+}
+
+// a link found on a page
+type Link struct {
+	Url string `json:"url"`
+
+	// the element and attribute the link was found in ("a[href]", "script[src]"),
+	// LINK_SOURCE_TEXT outside of a tag and LINK_SOURCE_ROBOTS in robots.txt
+	Source string `json:"source"`
+}
+
+// the timings of the request of a fetched page
+type Timings struct {
+	// the time the request was sent at
+	Start time.Time `json:"start"`
+
+	// the time waited for the headers of the response
+	Wait time.Duration `json:"wait"`
+
+	// the time spent reading the body of the response
+	Receive time.Duration `json:"receive"`
 }
 
 type DomainResultEntry struct {
@@ -109,6 +209,17 @@ func NewDomainResultEntry() *DomainResultEntry {
 	}
 }
 
+// returns a copy of the entry whose results and attachements can be read
+// while the entry is modified, the page results themselves being shared
+func (entry *DomainResultEntry) Copy() DomainResultEntry {
+	result := DomainResultEntry{
+		PageResults:  append(make([]PageResult, 0, len(entry.PageResults)), entry.PageResults...),
+		Attachements: make(Attachements, len(entry.Attachements)),
+	}
+	result.Attachements.AddAll(entry.Attachements)
+	return result
+}
+
 // a structure grouping PageResults per domain
 type DomainResults map[string]*DomainResultEntry
 
@@ -129,11 +240,29 @@ func (res DomainResults) AddAttachements(url string, attachements Attachements)
 
 }
 
+// returns a copy of the results of the domain, see DomainResultEntry.Copy
+func (res DomainResults) Copy() DomainResults {
+	result := make(DomainResults, len(res))
+	for url, entry := range res {
+		entryCopy := entry.Copy()
+		result[url] = &entryCopy
+	}
+	return result
+}
+
 type FetchedUrls map[string]DomainResults
 
 type CrawlerData struct {
 	UrlsToFetch []PageRequest `json:"urls_to_fetch"`
 	FetchedUrls `json:"fetched_urls"`
+
+	// the urls added to the urls to fetch, built from the urls to fetch and
+	// the fetched urls if nil (data of an older scan)
+	Seen *SeenSet `json:"seen,omitempty"`
+
+	// the endpoints of the urls added to the urls to fetch per url pattern,
+	// built from the urls to fetch and the fetched urls if nil
+	Patterns EndpointPatterns `json:"-"`
 }
 
 func (fetchedUrls FetchedUrls) IsDomainPresent(domainName string) bool {
@@ -143,9 +272,69 @@ func (fetchedUrls FetchedUrls) IsDomainPresent(domainName string) bool {
 
 func NewCrawlerData() *CrawlerData {
 	return &CrawlerData{
-		make([]PageRequest, 0),
-		make(map[string]DomainResults),
+		UrlsToFetch: make([]PageRequest, 0),
+		FetchedUrls: make(map[string]DomainResults),
+		Seen:        NewSeenSet(),
+	}
+}
+
+// returns the seen set of the data, building an exact set if it is nil
+func (d *CrawlerData) SeenSet() *SeenSet {
+	if d.Seen == nil {
+		d.UseSeenSet(NewSeenSet())
+	}
+	return d.Seen
+}
+
+// replaces the seen set of the data by seen, the urls to fetch and the fetched urls being added to it
+func (d *CrawlerData) UseSeenSet(seen *SeenSet) {
+	for _, url := range d.UrlsToFetch {
+		seen.Add(url)
+	}
+	for _, domainResults := range d.FetchedUrls {
+		for _, entry := range domainResults {
+			for _, pageResult := range entry.PageResults {
+				seen.Add(pageResult.Url)
+			}
+		}
+	}
+	d.Seen = seen
+}
+
+// returns the endpoint patterns of the data, building them if they are nil
+func (d *CrawlerData) EndpointPatterns() EndpointPatterns {
+	if d.Patterns == nil {
+		d.Patterns = make(EndpointPatterns)
+		for _, url := range d.UrlsToFetch {
+			d.Patterns.Add(url)
+		}
+		for _, domainResults := range d.FetchedUrls {
+			for _, entry := range domainResults {
+				for _, pageResult := range entry.PageResults {
+					d.Patterns.Add(pageResult.Url)
+				}
+			}
+		}
+	}
+	return d.Patterns
+}
+
+// returns a copy of the data which can be read while d is modified
+func (d *CrawlerData) Copy() CrawlerData {
+	result := CrawlerData{
+		UrlsToFetch: append(make([]PageRequest, 0, len(d.UrlsToFetch)), d.UrlsToFetch...),
+		FetchedUrls: make(FetchedUrls, len(d.FetchedUrls)),
+	}
+
+	for domainName, domainResults := range d.FetchedUrls {
+		result.FetchedUrls[domainName] = domainResults.Copy()
+	}
+
+	if d.Seen != nil {
+		result.Seen = d.Seen.Copy()
 	}
+
+	return result
 }
 
 type ShouldAddFilter func(foundUrl PageRequest, data *CrawlerData) bool
@@ -165,16 +354,58 @@ func (d *CrawlerData) AddUrlsToFetch(urls []PageRequest, shouldAdd ShouldAddFilt
 }
 
 func (d *CrawlerData) AddUrlToFetch(url PageRequest, shouldAdd ShouldAddFilter, scope *Scope) bool {
+	return d.TryAddUrlToFetch(url, shouldAdd, scope) == REJECT_NONE
+}
 
-	if scope.UrlInScope(url) && shouldAdd(url, d) {
-		newArr := FilterArray(append(d.UrlsToFetch, url))
-		if len(FilterArray(d.UrlsToFetch)) == len(newArr) {
-			return false
-		}
-		d.UrlsToFetch = newArr
-		return true
+// the reason why a found url is not added to the urls to fetch
+type RejectReason string
+
+const (
+	// the url has been added
+	REJECT_NONE RejectReason = ""
+
+	REJECT_OUT_OF_SCOPE RejectReason = "out_of_scope"
+
+	// the url has been rejected by the ShouldAddFilter
+	REJECT_FILTERED RejectReason = "filtered"
+
+	// the url is already in the urls to fetch
+	REJECT_DUPLICATE RejectReason = "duplicate"
+
+	// the request has been skipped by a BeforeRequest hook
+	REJECT_SKIPPED RejectReason = "skipped"
+)
+
+// adds url to the urls to fetch, returns why it has not been added or REJECT_NONE
+func (d *CrawlerData) TryAddUrlToFetch(url PageRequest, shouldAdd ShouldAddFilter, scope *Scope) RejectReason {
+
+	if !scope.UrlInScope(url) {
+		return REJECT_OUT_OF_SCOPE
+	}
+
+	// the seen set being consulted first, the filters are only run on new urls
+	if d.SeenSet().Contains(url) {
+		return REJECT_DUPLICATE
+	}
+
+	if !shouldAdd(url, d) {
+		return REJECT_FILTERED
+	}
+
+	if !d.pushUrlToFetch(url) {
+		return REJECT_DUPLICATE
+	}
+	return REJECT_NONE
+}
+
+// adds url to the urls to fetch, returns false if it has already been added
+func (d *CrawlerData) pushUrlToFetch(url PageRequest) bool {
+	if !d.SeenSet().Add(url) {
+		return false
 	}
-	return false
+	d.EndpointPatterns().Add(url)
+	d.UrlsToFetch = append(d.UrlsToFetch, url)
+	return true
 }
 
 func (d *CrawlerData) AddFetchedUrl(res PageResult) {
@@ -226,8 +457,30 @@ func (p PageResult) ContentType() string {
 }
 
 type RegexScope struct {
-	Includes []string `json:"includes"`
-	Excludes []string `json:"excludes"`
+	Includes []string `json:"includes" yaml:"includes"`
+	Excludes []string `json:"excludes" yaml:"excludes"`
+}
+
+// returns a RegexScope containing the includes and excludes of both scopes,
+// a nil scope is considered as absent
+func mergeRegexScopes(r1 *RegexScope, r2 *RegexScope) *RegexScope {
+	if r1 == nil && r2 == nil {
+		return nil
+	}
+
+	result := &RegexScope{
+		Includes: make([]string, 0),
+		Excludes: make([]string, 0),
+	}
+
+	for _, r := range []*RegexScope{r1, r2} {
+		if r != nil {
+			result.Includes = append(result.Includes, r.Includes...)
+			result.Excludes = append(result.Excludes, r.Excludes...)
+		}
+	}
+
+	return result
 }
 
 func (r *RegexScope) matchesRegexScope(value string) bool {
@@ -261,10 +514,48 @@ func (r *RegexScope) matchesRegexScope(value string) bool {
 	return true
 }
 
+// checks every parameter formatted as "name=value" against the RegexScope,
+// a single parameter out of the scope puts the whole set of parameters out of the scope
+func (r *RegexScope) matchesParameters(parameters map[string]string) bool {
+
+	for name, value := range parameters {
+		if !r.matchesRegexScope(fmt.Sprintf("%s=%s", name, value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type Scope struct {
-	Urls         *RegexScope `json:"urls"`
-	ContentTypes *RegexScope `json:"content-type"`
-	Extensions   *RegexScope `json:"extensions"`
+	Urls         *RegexScope `json:"urls" yaml:"urls"`
+	ContentTypes *RegexScope `json:"content-type" yaml:"content-type"`
+	Extensions   *RegexScope `json:"extensions" yaml:"extensions"`
+
+	// regexes matched against every parameter of the url formatted as "name=value"
+	Parameters *RegexScope `json:"parameters" yaml:"parameters"`
+
+	// regexes matched against the http method of the request, GET being the only method
+	// in scope if nil, and the other methods having to be matched by an include
+	Methods *RegexScope `json:"methods" yaml:"methods"`
+}
+
+// returns a new scope whose includes and excludes are the concatenation
+// of the ones of s and other
+func (s *Scope) Merge(other *Scope) *Scope {
+	result := &Scope{}
+	for _, scope := range []*Scope{s, other} {
+		if scope == nil {
+			continue
+		}
+		result.Urls = mergeRegexScopes(result.Urls, scope.Urls)
+		result.ContentTypes = mergeRegexScopes(result.ContentTypes, scope.ContentTypes)
+		result.Extensions = mergeRegexScopes(result.Extensions, scope.Extensions)
+		result.Parameters = mergeRegexScopes(result.Parameters, scope.Parameters)
+		result.Methods = mergeRegexScopes(result.Methods, scope.Methods)
+	}
+
+	return result
 }
 
 func (s *Scope) UrlInScope(url PageRequest) bool {
@@ -277,9 +568,31 @@ func (s *Scope) UrlInScope(url PageRequest) bool {
 		return false
 	}
 
+	if !s.MethodInScope(url.GetMethod()) {
+		return false
+	}
+
+	if s.Parameters != nil && !s.Parameters.matchesParameters(url.Parameters) {
+		return false
+	}
+
 	return true
 }
 
+// returns true if the requests with method can be sent, the methods other than GET
+// (form actions) never being sent unless an include of Methods matches them
+func (s *Scope) MethodInScope(method string) bool {
+	method = strings.ToUpper(method)
+	if s.Methods == nil {
+		return method == DEFAULT_METHOD
+	}
+
+	if method != DEFAULT_METHOD && len(s.Methods.Includes) == 0 {
+		return false
+	}
+	return s.Methods.matchesRegexScope(method)
+}
+
 func (s *Scope) PageInScope(p PageResult) bool {
 
 	if !s.UrlInScope(p.Url) {
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 )
 
 func FilterArray(pages []PageRequest) []PageRequest {
@@ -14,9 +15,9 @@ func FilterArray(pages []PageRequest) []PageRequest {
 
 	size := 0
 	for _, req := range pages {
-		if _, ok := index[req.ToUrl()]; !ok {
+		if _, ok := index[req.Key()]; !ok {
 			pages[size] = req
-			index[req.ToUrl()] = false
+			index[req.Key()] = false
 			size++
 		}
 	}
@@ -35,18 +36,90 @@ func GetProtocol(url string) string {
 	return strings.Split(url, "://")[0]
 }
 
-/* a function that extracts urls from any page
- */
-func ExtractUrlsFromHtml(page string, url string) []PageRequest {
+// the number of bytes before a link searched for the tag it is in
+const LINK_SOURCE_LOOKBEHIND = 512
+
+// the source of the links found outside of a tag attribute
+const LINK_SOURCE_TEXT = "text"
+
+// the source of the links found in robots.txt
+const LINK_SOURCE_ROBOTS = "robots.txt"
+
+var linkAttributePattern = regexp.MustCompile(`^<([a-zA-Z][\w-]*)\b[^>]*\s([\w-]+)\s*=\s*["']?$`)
+
+// returns the element and attribute ("a[href]") of the link starting at
+// index in page, LINK_SOURCE_TEXT if it is not in a tag attribute
+func linkSource(page string, index int) string {
+	start := index - LINK_SOURCE_LOOKBEHIND
+	if start < 0 {
+		start = 0
+	}
+	before := page[start:index]
+
+	tagIndex := strings.LastIndexByte(before, '<')
+	if tagIndex < 0 {
+		return LINK_SOURCE_TEXT
+	}
+
+	match := linkAttributePattern.FindStringSubmatch(before[tagIndex:])
+	if match == nil {
+		return LINK_SOURCE_TEXT
+	}
+	return strings.ToLower(match[1]) + "[" + strings.ToLower(match[2]) + "]"
+}
+
+// the source of the links of the actions of forms
+const LINK_SOURCE_FORM_ACTION = "form[action]"
+
+var formMethodPattern = regexp.MustCompile(`(?i)\smethod\s*=\s*["']?([a-z]+)`)
+
+// returns the method of the form tag around index in page, DEFAULT_METHOD if it has none
+func formMethod(page string, index int) string {
+	start := strings.LastIndexByte(page[:index], '<')
+	end := strings.IndexByte(page[index:], '>')
+	if start < 0 || end < 0 {
+		return DEFAULT_METHOD
+	}
+
+	match := formMethodPattern.FindStringSubmatch(page[start : index+end])
+	if match == nil {
+		return DEFAULT_METHOD
+	}
+	return strings.ToUpper(match[1])
+}
+
+// returns the urls found in page and the sources of the urls (see linkSource),
+// the urls of form actions having the method of their form
+func extractLinks(page string, url string) ([]PageRequest, []string) {
 	foundLinks := make([]PageRequest, 0)
+	sources := make([]string, 0)
+	index := make(map[string]bool)
+
+	addLink := func(link string, at int) {
+		request := PageRequestFromUrl(html.UnescapeString(link))
+		source := linkSource(page, at)
+		if source == LINK_SOURCE_FORM_ACTION {
+			if method := formMethod(page, at); method != DEFAULT_METHOD {
+				request.Method = method
+			}
+		}
+
+		if index[request.Key()] {
+			return
+		}
+		index[request.Key()] = true
+		foundLinks = append(foundLinks, request)
+		sources = append(sources, source)
+	}
 
 	rootUrl := rootUrlPattern.FindString(url)
 
-	for _, v := range urlPattern.FindAllString(page, -1) {
-		foundLinks = append(foundLinks, PageRequestFromUrl(html.UnescapeString(v)))
+	for _, bounds := range urlPattern.FindAllStringIndex(page, -1) {
+		addLink(page[bounds[0]:bounds[1]], bounds[0])
 	}
 
-	for _, loc := range locationPattern.FindAllString(page, -1) {
+	for _, bounds := range locationPattern.FindAllStringIndex(page, -1) {
+		loc := page[bounds[0]:bounds[1]]
 
 		if len(loc) <= 2 {
 			continue
@@ -60,12 +133,66 @@ func ExtractUrlsFromHtml(page string, url string) []PageRequest {
 				effectiveLink = rootUrl + loc
 			}
 
-			effectiveLink = html.UnescapeString(effectiveLink)
-			foundLinks = append(foundLinks, PageRequestFromUrl(effectiveLink))
+			// the match starting with the quote of the attribute
+			addLink(effectiveLink, bounds[0]+1)
 		}
 	}
 
-	return FilterArray(foundLinks)
+	return foundLinks, sources
+}
+
+/* a function that extracts urls from any page
+ */
+func ExtractUrlsFromHtml(page string, url string) []PageRequest {
+	foundLinks, _ := extractLinks(page, url)
+	return foundLinks
+}
+
+var uuidSegmentPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+var dateSegmentPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}|(19|20)\d{2}(0[1-9]|1[0-2])(0[1-9]|[12]\d|3[01]))$`)
+var hashSegmentPattern = regexp.MustCompile(`^[0-9a-fA-F]*\d[0-9a-fA-F]*$`)
+var idSegmentPattern = regexp.MustCompile(`^\d+$`)
+
+// the minimum length of an hexadecimal segment to be considered as a hash
+const HASH_SEGMENT_MIN_LENGTH = 16
+
+func segmentPattern(segment string) string {
+
+	// keeps the extension of the segment
+	var extension string
+	if index := strings.Index(segment, "."); index > 0 {
+		segment, extension = segment[:index], segment[index:]
+	}
+
+	switch {
+	case uuidSegmentPattern.MatchString(segment):
+		segment = "{uuid}"
+	case dateSegmentPattern.MatchString(segment):
+		segment = "{date}"
+	case idSegmentPattern.MatchString(segment):
+		segment = "{id}"
+	case len(segment) >= HASH_SEGMENT_MIN_LENGTH && hashSegmentPattern.MatchString(segment):
+		segment = "{hash}"
+	}
+
+	return segment + extension
+}
+
+// returns the structural pattern of an url by replacing the variable segments
+// of its path (ids, uuids, dates and hashes) by placeholders
+//  https://example.com/users/123 => https://example.com/users/{id}
+func UrlPattern(baseUrl string) string {
+	rootUrl := rootUrlPattern.FindString(baseUrl)
+	if !strings.HasPrefix(baseUrl, rootUrl) {
+		return baseUrl
+	}
+
+	segments := strings.Split(baseUrl[len(rootUrl):], "/")
+	for i, segment := range segments {
+		segments[i] = segmentPattern(segment)
+	}
+
+	return rootUrl + strings.Join(segments, "/")
 }
 
 func ExtractDomainName(url string) string {
@@ -77,23 +204,86 @@ func ExtractDomainName(url string) string {
 	return rootUrl[len(GetProtocol(rootUrl))+3:]
 }
 
+// the value replacing the credentials of the redacted headers
+const REDACTED_VALUE = "REDACTED"
+
+// the headers holding credentials, see RedactHeaders
+var CREDENTIAL_HEADERS = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// returns a copy of headers with the credentials of CREDENTIAL_HEADERS redacted,
+// the scheme of the authorizations and the names of the cookies being kept
+func RedactHeaders(headers http.Header) http.Header {
+	if headers == nil {
+		return nil
+	}
+
+	result := headers.Clone()
+	for _, name := range CREDENTIAL_HEADERS {
+		values := result.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		redacted := make([]string, 0, len(values))
+		if name == "Cookie" {
+			request := http.Request{Header: http.Header{"Cookie": values}}
+			for _, cookie := range request.Cookies() {
+				redacted = append(redacted, cookie.Name+"="+REDACTED_VALUE)
+			}
+			if len(redacted) > 0 {
+				redacted = []string{strings.Join(redacted, "; ")}
+			}
+		} else {
+			for _, value := range values {
+				if fields := strings.Fields(value); len(fields) > 1 {
+					redacted = append(redacted, fields[0]+" "+REDACTED_VALUE)
+				} else {
+					redacted = append(redacted, REDACTED_VALUE)
+				}
+			}
+		}
+
+		result.Del(name)
+		for _, value := range redacted {
+			result.Add(name, value)
+		}
+	}
+
+	return result
+}
+
+// fetches url, the urls found on the page being returned whether they are in
+// scope or not, for the caller to report the rejected ones (see AddUrlToStore),
+// the Links of the page only holding the ones in scope.
+// The urls whose method is not in scope (see Scope.MethodInScope) are never requested.
 func FetchPage(httpClient *http.Client, url PageRequest, scope *Scope, fetchedUrls FetchedUrls, request *http.Request) (PageResult, []byte, error) {
 
+	if !scope.MethodInScope(url.GetMethod()) {
+		return PageResult{}, nil, fmt.Errorf("crawler::FetchPage -> method %s of %s out of scope", url.GetMethod(), url.ToUrl())
+	}
+
 	if request == nil {
-		request, _ = http.NewRequest("GET", url.ToUrl(), nil)
+		request, _ = http.NewRequest(url.GetMethod(), url.ToUrl(), nil)
 	}
 
+	startTime := time.Now()
 	res, err := httpClient.Do(request)
 	if err != nil {
 		return PageResult{}, nil, err
 	}
 
 	result := PageResult{
-		Url:           url,
-		StatusCode:    res.StatusCode,
-		ContentLength: res.ContentLength,
-		Headers:       res.Header.Clone(),
-		FoundUrls:     make([]PageRequest, 0),
+		Url:            url,
+		StatusCode:     res.StatusCode,
+		ContentLength:  res.ContentLength,
+		Headers:        res.Header.Clone(),
+		FoundUrls:      make([]PageRequest, 0),
+		RequestHeaders: request.Header.Clone(),
+		Protocol:       res.Proto,
+		Timings: &Timings{
+			Start: startTime,
+			Wait:  time.Since(startTime),
+		},
 	}
 
 	defer res.Body.Close()
@@ -102,11 +292,14 @@ func FetchPage(httpClient *http.Client, url PageRequest, scope *Scope, fetchedUr
 	if err != nil {
 		return PageResult{}, nil, err
 	}
+	result.Timings.Receive = time.Since(startTime) - result.Timings.Wait
 
 	if result.ContentLength < 0 {
 		result.ContentLength = int64(len(body))
 	}
 
+	result.Fingerprint = Fingerprint(body)
+
 	shouldExtractUrls := false
 	for _, mimeType := range INCLUDED_MIME_TYPES {
 		if strings.HasPrefix(result.ContentType(), mimeType) {
@@ -116,19 +309,15 @@ func FetchPage(httpClient *http.Client, url PageRequest, scope *Scope, fetchedUr
 	}
 
 	if shouldExtractUrls {
-		urls := ExtractUrlsFromHtml(string(body), url.BaseUrl)
+		urls, sources := extractLinks(string(body), url.BaseUrl)
 
-		data := make([]PageRequest, len(urls))
-
-		size := 0
-		for _, v := range urls {
+		result.FoundUrls = urls
+		result.Links = make([]Link, 0, len(urls))
+		for i, v := range urls {
 			if scope.UrlInScope(v) {
-				data[size] = v
-				size++
+				result.Links = append(result.Links, Link{Url: v.ToUrl(), Source: sources[i]})
 			}
 		}
-
-		result.FoundUrls = data[:size]
 	}
 
 	return result, body, nil
@@ -0,0 +1,197 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestExtractLinksMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    string
+		methods []string
+	}{
+		{"link", `<a href="/delete">x</a>`, []string{""}},
+		{"form without method", `<form action="/search"><input name="q"></form>`, []string{""}},
+		{"get form", `<form method="get" action="/search"></form>`, []string{""}},
+		{"post form", `<form method="post" action="/login"></form>`, []string{"POST"}},
+		{"method after action", `<form action="/login" class="f" method=POST>`, []string{"POST"}},
+		{"link and form", `<a href="/login">login</a><form method="post" action="/login">`, []string{"", "POST"}},
+		{"method of another tag", `<div method="post"><form action="/search">`, []string{""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			links, _ := extractLinks(test.page, "https://example.com/")
+			if len(links) != len(test.methods) {
+				t.Fatalf("expected %d links, got %v", len(test.methods), links)
+			}
+
+			for i, link := range links {
+				if link.Method != test.methods[i] {
+					t.Errorf("link %d: expected method %q, got %q", i, test.methods[i], link.Method)
+				}
+			}
+		})
+	}
+}
+
+func TestPageRequestEquals(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      PageRequest
+		b      PageRequest
+		equals bool
+	}{
+		{"same url", PageRequestFromUrl("https://a.com/x?a=1&b=2"), PageRequestFromUrl("https://a.com/x?b=2&a=1"), true},
+		{"other parameter", PageRequestFromUrl("https://a.com/x?a=1"), PageRequestFromUrl("https://a.com/x?a=2"), false},
+		{"default method", PageRequest{BaseUrl: "https://a.com/x"}, PageRequest{BaseUrl: "https://a.com/x", Method: "get"}, true},
+		{"other method", PageRequest{BaseUrl: "https://a.com/x"}, PageRequest{BaseUrl: "https://a.com/x", Method: "POST"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if equals := test.a.Equals(test.b); equals != test.equals {
+				t.Errorf("expected %v, got %v", test.equals, equals)
+			}
+			if equals := test.a.Key() == test.b.Key(); equals != test.equals {
+				t.Errorf("keys: expected %v, got %v", test.equals, equals)
+			}
+		})
+	}
+}
+
+func TestUrlPattern(t *testing.T) {
+	tests := []struct {
+		url     string
+		pattern string
+	}{
+		{"https://example.com", "https://example.com"},
+		{"https://example.com/", "https://example.com/"},
+		{"https://example.com/users/123", "https://example.com/users/{id}"},
+		{"https://example.com/users/123/posts/4", "https://example.com/users/{id}/posts/{id}"},
+		{"https://example.com/users/123.json", "https://example.com/users/{id}.json"},
+		{"https://example.com/orders/3f2a9c1e-8b4d-4f6a-9e2b-1c3d5e7f9a0b", "https://example.com/orders/{uuid}"},
+		{"https://example.com/archive/2024-01-31", "https://example.com/archive/{date}"},
+		{"https://example.com/archive/20240131", "https://example.com/archive/{date}"},
+		{"https://example.com/static/9f86d081884c7d65.js", "https://example.com/static/{hash}.js"},
+		{"https://example.com/static/deadbeefdeadbeef", "https://example.com/static/deadbeefdeadbeef"},
+		{"https://example.com/v2/api", "https://example.com/v2/api"},
+		{"https://example.com/abc123", "https://example.com/abc123"},
+		{"http://localhost:8080/items/42", "http://localhost:8080/items/{id}"},
+		{"not an url", "not an url"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			if pattern := UrlPattern(test.url); pattern != test.pattern {
+				t.Errorf("expected %s, got %s", test.pattern, pattern)
+			}
+		})
+	}
+}
+
+func TestMethodInScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods *RegexScope
+		method  string
+		inScope bool
+	}{
+		{"default get", nil, "GET", true},
+		{"default post", nil, "POST", false},
+		{"default delete", nil, "delete", false},
+		{"excludes only get", &RegexScope{Excludes: []string{"^DELETE$"}}, "GET", true},
+		{"excludes only post", &RegexScope{Excludes: []string{"^DELETE$"}}, "POST", false},
+		{"included post", &RegexScope{Includes: []string{"^(GET|POST)$"}}, "POST", true},
+		{"not included get", &RegexScope{Includes: []string{"^POST$"}}, "GET", false},
+		{"included and excluded", &RegexScope{Includes: []string{".*"}, Excludes: []string{"^DELETE$"}}, "DELETE", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scope := &Scope{Methods: test.methods}
+			if inScope := scope.MethodInScope(test.method); inScope != test.inScope {
+				t.Errorf("expected %v, got %v", test.inScope, inScope)
+			}
+		})
+	}
+}
+
+func TestFormActionNotFetched(t *testing.T) {
+	var lock sync.Mutex
+	methods := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		lock.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<form method="post" action="/delete"><button>delete</button></form>`))
+	}))
+	defer server.Close()
+
+	scope := &Scope{}
+	result, _, err := FetchPage(server.Client(), PageRequestFromUrl(server.URL+"/"), scope, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the form action is recorded with its method but not followed
+	if len(result.FoundUrls) != 1 || result.FoundUrls[0].GetMethod() != "POST" {
+		t.Fatalf("form action not recorded: %v", result.FoundUrls)
+	}
+	if len(result.Links) != 0 {
+		t.Errorf("form action linked: %v", result.Links)
+	}
+
+	store := NewMemoryStore(nil)
+	reason, err := AddUrlToStore(store, result.FoundUrls[0], func(PageRequest, *CrawlerData) bool { return true }, scope)
+	if err != nil || reason != REJECT_OUT_OF_SCOPE {
+		t.Errorf("expected %s, got %s (%v)", REJECT_OUT_OF_SCOPE, reason, err)
+	}
+
+	if _, _, err = FetchPage(server.Client(), result.FoundUrls[0], scope, nil, nil); err == nil {
+		t.Errorf("form action fetched")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(methods) != 1 || methods[0] != "GET /" {
+		t.Errorf("unexpected requests %v", methods)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  http.Header
+		redacted http.Header
+	}{
+		{"nil", nil, nil},
+		{"no credentials", http.Header{"User-Agent": {"go-crawler"}}, http.Header{"User-Agent": {"go-crawler"}}},
+		{"authorization scheme kept", http.Header{"Authorization": {"Bearer abc.def"}, "Proxy-Authorization": {"Basic dXNlcjpwYXNz"}}, http.Header{"Authorization": {"Bearer REDACTED"}, "Proxy-Authorization": {"Basic REDACTED"}}},
+		{"authorization without scheme", http.Header{"Authorization": {"secret-token"}}, http.Header{"Authorization": {"REDACTED"}}},
+		{"cookie names kept", http.Header{"Cookie": {"session=abc; theme=dark", "csrf=xyz"}, "Accept": {"*/*"}}, http.Header{"Cookie": {"session=REDACTED; theme=REDACTED; csrf=REDACTED"}, "Accept": {"*/*"}}},
+		{"invalid cookies removed", http.Header{"Cookie": {"=abc"}}, http.Header{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var original http.Header
+			if test.headers != nil {
+				original = test.headers.Clone()
+			}
+
+			if redacted := RedactHeaders(test.headers); !reflect.DeepEqual(redacted, test.redacted) {
+				t.Errorf("expected %v, got %v", test.redacted, redacted)
+			}
+			if !reflect.DeepEqual(test.headers, original) {
+				t.Errorf("headers modified: %v", test.headers)
+			}
+		})
+	}
+}
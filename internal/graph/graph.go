@@ -0,0 +1,248 @@
+package graph
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// how the pages are grouped into the nodes of a graph
+type Collapse string
+
+const (
+	COLLAPSE_NONE      Collapse = "none"
+	COLLAPSE_DIRECTORY Collapse = "directory"
+	COLLAPSE_HOST      Collapse = "host"
+)
+
+var COLLAPSE_MODES = []string{
+	string(COLLAPSE_NONE),
+	string(COLLAPSE_DIRECTORY),
+	string(COLLAPSE_HOST),
+}
+
+// the source of the edges of the scans saved without the links of the pages,
+// built from PageRequest.Parent
+const SOURCE_PARENT = "parent"
+
+type Options struct {
+	Collapse Collapse
+
+	// drops the nodes of the urls found but not fetched
+	FetchedOnly bool
+}
+
+// a page, or the pages of a directory or host when collapsed
+type Node struct {
+	// the url of the page, directory or host
+	Id string `json:"id"`
+
+	// the number of fetched pages of the node
+	Pages int `json:"pages"`
+
+	// the min depth of the pages of the node, -1 if none has been fetched
+	Depth int `json:"depth"`
+
+	// the status and content type of a single fetched page
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+
+	// the number of pages per status and content type of a collapsed node
+	Statuses     map[int]int    `json:"statuses,omitempty"`
+	ContentTypes map[string]int `json:"content_types,omitempty"`
+}
+
+func (n *Node) Fetched() bool {
+	return n.Pages > 0
+}
+
+// the links from the pages of a node to the pages of another one
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// the element and attribute of the links ("a[href]"), see crawler.Link
+	Source string `json:"source"`
+
+	// the number of links
+	Count int `json:"count"`
+}
+
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// returns the id of the node of rawUrl
+func nodeId(rawUrl string, collapse Collapse) string {
+	if collapse == COLLAPSE_NONE {
+		return rawUrl
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil || len(u.Host) == 0 {
+		return rawUrl
+	}
+
+	root := u.Scheme + "://" + u.Host
+	if collapse == COLLAPSE_HOST {
+		return root
+	}
+
+	if len(u.Path) == 0 {
+		return root + "/"
+	}
+	return root + u.Path[:strings.LastIndex(u.Path, "/")+1]
+}
+
+// returns the link graph of the pages of data
+func Build(data *crawler.CrawlerData, options Options) *Graph {
+	if len(options.Collapse) == 0 {
+		options.Collapse = COLLAPSE_NONE
+	}
+	collapsed := options.Collapse != COLLAPSE_NONE
+
+	nodes := make(map[string]*Node)
+	getNode := func(rawUrl string) *Node {
+		id := nodeId(rawUrl, options.Collapse)
+		node, ok := nodes[id]
+		if !ok {
+			node = &Node{Id: id, Depth: -1}
+			nodes[id] = node
+		}
+		return node
+	}
+
+	type edgeKey struct {
+		from, to, source string
+	}
+	edges := make(map[edgeKey]*Edge)
+	addEdge := func(from string, to string, source string) {
+		key := edgeKey{nodeId(from, options.Collapse), nodeId(to, options.Collapse), source}
+
+		// the links between the pages of a collapsed node being hidden
+		if collapsed && key.from == key.to {
+			return
+		}
+
+		edge, ok := edges[key]
+		if !ok {
+			edge = &Edge{From: key.from, To: key.to, Source: source}
+			edges[key] = edge
+		}
+		edge.Count++
+	}
+
+	// the scans saved before the links were recorded only having the parent of the pages
+	hasLinks := false
+	for _, domainResults := range data.FetchedUrls {
+		for _, entry := range domainResults {
+			for _, pageResult := range entry.PageResults {
+				hasLinks = hasLinks || len(pageResult.Links) > 0
+			}
+		}
+	}
+
+	for _, domainResults := range data.FetchedUrls {
+		for _, entry := range domainResults {
+			for _, pageResult := range entry.PageResults {
+				pageUrl := pageResult.Url.ToUrl()
+				node := getNode(pageUrl)
+
+				node.Pages++
+				if node.Depth < 0 || pageResult.Url.Depth < node.Depth {
+					node.Depth = pageResult.Url.Depth
+				}
+
+				contentType := pageResult.ContentType()
+				if index := strings.Index(contentType, ";"); index >= 0 {
+					contentType = strings.TrimSpace(contentType[:index])
+				}
+
+				if collapsed {
+					if node.Statuses == nil {
+						node.Statuses = make(map[int]int)
+						node.ContentTypes = make(map[string]int)
+					}
+					node.Statuses[pageResult.StatusCode]++
+					if len(contentType) > 0 {
+						node.ContentTypes[contentType]++
+					}
+				} else {
+					node.Status = pageResult.StatusCode
+					node.ContentType = contentType
+				}
+
+				for _, link := range pageResult.Links {
+					getNode(link.Url)
+					addEdge(pageUrl, link.Url, link.Source)
+				}
+
+				if !hasLinks && len(pageResult.Url.Parent) > 0 {
+					getNode(pageResult.Url.Parent)
+					addEdge(pageResult.Url.Parent, pageUrl, SOURCE_PARENT)
+				}
+			}
+		}
+	}
+
+	graph := &Graph{
+		Nodes: make([]*Node, 0, len(nodes)),
+		Edges: make([]*Edge, 0, len(edges)),
+	}
+
+	for _, node := range nodes {
+		if options.FetchedOnly && !node.Fetched() {
+			continue
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].Id < graph.Nodes[j].Id
+	})
+
+	for _, edge := range edges {
+		if options.FetchedOnly && (!nodes[edge.From].Fetched() || !nodes[edge.To].Fetched()) {
+			continue
+		}
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		} else if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Source < b.Source
+	})
+
+	return graph
+}
+
+// returns the label of a node, its path and its status and content type
+func (n *Node) label() string {
+	label := n.Id
+	if !n.Fetched() {
+		return label
+	}
+
+	if len(n.Statuses) > 0 {
+		statuses := make([]int, 0, len(n.Statuses))
+		for status := range n.Statuses {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+
+		parts := make([]string, len(statuses))
+		for i, status := range statuses {
+			parts[i] = fmt.Sprintf("%d: %d", status, n.Statuses[status])
+		}
+		return fmt.Sprintf("%s\n%d page(s), %s", label, n.Pages, strings.Join(parts, ", "))
+	}
+
+	return fmt.Sprintf("%s\n%d %s", label, n.Status, n.ContentType)
+}
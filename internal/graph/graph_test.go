@@ -0,0 +1,248 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+type testPage struct {
+	url    string
+	depth  int
+	status int
+	parent string
+	links  []crawler.Link
+}
+
+func testData(pages []testPage) *crawler.CrawlerData {
+	data := crawler.NewCrawlerData()
+	for _, page := range pages {
+		url := crawler.PageRequestFromUrl(page.url)
+		url.Depth = page.depth
+		url.Parent = page.parent
+		data.AddFetchedUrl(crawler.PageResult{
+			Url:        url,
+			StatusCode: page.status,
+			Headers:    http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			Links:      page.links,
+		})
+	}
+	return data
+}
+
+// a.com links to its docs, a script and b.com, the docs linking to each other
+var testPages = []testPage{
+	{"https://a.com", 0, 200, "", []crawler.Link{
+		{Url: "https://a.com/docs/x", Source: "a[href]"},
+		{Url: "https://a.com/docs/y", Source: "a[href]"},
+		{Url: "https://a.com/app.js", Source: "script[src]"},
+		{Url: "https://b.com/ext", Source: "a[href]"},
+	}},
+	{"https://a.com/docs/x", 1, 200, "https://a.com", []crawler.Link{
+		{Url: "https://a.com/docs/y", Source: "a[href]"},
+	}},
+	{"https://a.com/docs/y", 1, 404, "https://a.com", nil},
+}
+
+// the pages of a scan saved before the links were recorded
+var testLegacyPages = []testPage{
+	{"https://a.com", 0, 200, "", nil},
+	{"https://a.com/docs/x", 1, 200, "https://a.com", nil},
+	{"https://a.com/docs/y", 2, 200, "https://a.com/docs/x", nil},
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   []testPage
+		options Options
+		nodes   []string
+		edges   []string
+	}{
+		{
+			name:  "pages",
+			pages: testPages,
+			nodes: []string{"https://a.com", "https://a.com/app.js", "https://a.com/docs/x", "https://a.com/docs/y", "https://b.com/ext"},
+			edges: []string{
+				"https://a.com -> https://a.com/app.js script[src] 1",
+				"https://a.com -> https://a.com/docs/x a[href] 1",
+				"https://a.com -> https://a.com/docs/y a[href] 1",
+				"https://a.com -> https://b.com/ext a[href] 1",
+				"https://a.com/docs/x -> https://a.com/docs/y a[href] 1",
+			},
+		},
+		{
+			name:    "fetched only",
+			pages:   testPages,
+			options: Options{FetchedOnly: true},
+			nodes:   []string{"https://a.com", "https://a.com/docs/x", "https://a.com/docs/y"},
+			edges: []string{
+				"https://a.com -> https://a.com/docs/x a[href] 1",
+				"https://a.com -> https://a.com/docs/y a[href] 1",
+				"https://a.com/docs/x -> https://a.com/docs/y a[href] 1",
+			},
+		},
+		{
+			name:    "directories",
+			pages:   testPages,
+			options: Options{Collapse: COLLAPSE_DIRECTORY},
+			nodes:   []string{"https://a.com/", "https://a.com/docs/", "https://b.com/"},
+			edges: []string{
+				"https://a.com/ -> https://a.com/docs/ a[href] 2",
+				"https://a.com/ -> https://b.com/ a[href] 1",
+			},
+		},
+		{
+			name:    "hosts",
+			pages:   testPages,
+			options: Options{Collapse: COLLAPSE_HOST},
+			nodes:   []string{"https://a.com", "https://b.com"},
+			edges:   []string{"https://a.com -> https://b.com a[href] 1"},
+		},
+		{
+			name:  "parents",
+			pages: testLegacyPages,
+			nodes: []string{"https://a.com", "https://a.com/docs/x", "https://a.com/docs/y"},
+			edges: []string{
+				"https://a.com -> https://a.com/docs/x parent 1",
+				"https://a.com/docs/x -> https://a.com/docs/y parent 1",
+			},
+		},
+		{
+			name:  "empty",
+			pages: nil,
+			nodes: []string{},
+			edges: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := Build(testData(test.pages), test.options)
+
+			nodes := make([]string, len(graph.Nodes))
+			for i, node := range graph.Nodes {
+				nodes[i] = node.Id
+			}
+			if strings.Join(nodes, "\n") != strings.Join(test.nodes, "\n") {
+				t.Errorf("expected nodes %v, got %v", test.nodes, nodes)
+			}
+
+			edges := make([]string, len(graph.Edges))
+			for i, edge := range graph.Edges {
+				edges[i] = fmt.Sprintf("%s -> %s %s %d", edge.From, edge.To, edge.Source, edge.Count)
+			}
+			if strings.Join(edges, "\n") != strings.Join(test.edges, "\n") {
+				t.Errorf("expected edges %v, got %v", test.edges, edges)
+			}
+		})
+	}
+}
+
+func TestBuildNodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		collapse Collapse
+		id       string
+		pages    int
+		depth    int
+		status   int
+		statuses map[int]int
+	}{
+		{"page", COLLAPSE_NONE, "https://a.com/docs/y", 1, 1, 404, nil},
+		{"not fetched", COLLAPSE_NONE, "https://b.com/ext", 0, -1, 0, nil},
+		{"directory", COLLAPSE_DIRECTORY, "https://a.com/docs/", 2, 1, 0, map[int]int{200: 1, 404: 1}},
+		{"host", COLLAPSE_HOST, "https://a.com", 3, 0, 0, map[int]int{200: 2, 404: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := Build(testData(testPages), Options{Collapse: test.collapse})
+
+			var node *Node
+			for _, n := range graph.Nodes {
+				if n.Id == test.id {
+					node = n
+				}
+			}
+			if node == nil {
+				t.Fatalf("node %s not found", test.id)
+			}
+
+			if node.Pages != test.pages || node.Depth != test.depth || node.Status != test.status {
+				t.Errorf("expected %d pages, depth %d and status %d, got %d, %d and %d", test.pages, test.depth, test.status, node.Pages, node.Depth, node.Status)
+			}
+			if len(node.Statuses) != len(test.statuses) {
+				t.Errorf("expected statuses %v, got %v", test.statuses, node.Statuses)
+			}
+			for status, count := range test.statuses {
+				if node.Statuses[status] != count {
+					t.Errorf("expected statuses %v, got %v", test.statuses, node.Statuses)
+				}
+			}
+			if node.Status != 0 && node.ContentType != "text/html" {
+				t.Errorf("unexpected content type %q", node.ContentType)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	graph := Build(testData(testPages), Options{})
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, output []byte)
+	}{
+		{"dot", func(t *testing.T, output []byte) {
+			for _, line := range []string{
+				"digraph crawl {",
+				`"https://a.com/docs/y" [label="https://a.com/docs/y\n404 text/html", color=red];`,
+				`"https://b.com/ext" [label="https://b.com/ext", color=gray, style=dashed];`,
+				`"https://a.com" -> "https://a.com/app.js" [label="script[src]"];`,
+			} {
+				if !bytes.Contains(output, []byte(line)) {
+					t.Errorf("line %s not found in\n%s", line, output)
+				}
+			}
+		}},
+		{"graphml", func(t *testing.T, output []byte) {
+			var document graphMLDocument
+			if err := xml.Unmarshal(output, &document); err != nil {
+				t.Fatalf("invalid GraphML: %s", err)
+			}
+			if len(document.Graph.Nodes) != len(graph.Nodes) || len(document.Graph.Edges) != len(graph.Edges) {
+				t.Errorf("got %d nodes and %d edges", len(document.Graph.Nodes), len(document.Graph.Edges))
+			}
+		}},
+		{"json", func(t *testing.T, output []byte) {
+			var decoded Graph
+			if err := json.Unmarshal(output, &decoded); err != nil {
+				t.Fatalf("invalid json: %s", err)
+			}
+			if len(decoded.Nodes) != len(graph.Nodes) || len(decoded.Edges) != len(graph.Edges) {
+				t.Errorf("got %d nodes and %d edges", len(decoded.Nodes), len(decoded.Edges))
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := Write(&buffer, graph, test.format); err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, buffer.Bytes())
+		})
+	}
+
+	if err := Write(&bytes.Buffer{}, graph, "svg"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,223 @@
+package graph
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var FORMATS = []string{
+	"dot",
+	"graphml",
+	"json",
+}
+
+// writes graph in format, one of FORMATS
+func Write(w io.Writer, graph *Graph, format string) error {
+	switch format {
+	case "dot":
+		return WriteDot(w, graph)
+	case "graphml":
+		return WriteGraphML(w, graph)
+	case "json":
+		return WriteJson(w, graph)
+	}
+	return fmt.Errorf("graph::Write -> unknown format %s", format)
+}
+
+func WriteJson(w io.Writer, graph *Graph) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(graph); err != nil {
+		return fmt.Errorf("graph::WriteJson -> %s", err)
+	}
+	return nil
+}
+
+// returns a quoted graphviz id
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, "\"", "\\\"")
+	value = strings.ReplaceAll(value, "\n", "\\n")
+	return "\"" + value + "\""
+}
+
+// returns the color of a node from the status of its pages
+func dotColor(node *Node) string {
+	status := node.Status
+	for s := range node.Statuses {
+		// the worst status of a collapsed node
+		if s > status {
+			status = s
+		}
+	}
+
+	switch {
+	case !node.Fetched():
+		return "gray"
+	case status >= 400:
+		return "red"
+	case status >= 300:
+		return "blue"
+	}
+	return "black"
+}
+
+// writes graph in the graphviz DOT language, the nodes of the urls not
+// fetched being dashed and the edges labeled by their source
+func WriteDot(w io.Writer, graph *Graph) error {
+	var builder strings.Builder
+
+	builder.WriteString("digraph crawl {\n")
+	builder.WriteString("\tnode [shape=box];\n")
+
+	for _, node := range graph.Nodes {
+		attributes := fmt.Sprintf("label=%s, color=%s", dotQuote(node.label()), dotColor(node))
+		if !node.Fetched() {
+			attributes += ", style=dashed"
+		}
+		fmt.Fprintf(&builder, "\t%s [%s];\n", dotQuote(node.Id), attributes)
+	}
+
+	for _, edge := range graph.Edges {
+		label := edge.Source
+		if edge.Count > 1 {
+			label += " (" + strconv.Itoa(edge.Count) + ")"
+		}
+		fmt.Fprintf(&builder, "\t%s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(label))
+	}
+
+	builder.WriteString("}\n")
+
+	if _, err := io.WriteString(w, builder.String()); err != nil {
+		return fmt.Errorf("graph::WriteDot -> %s", err)
+	}
+	return nil
+}
+
+type graphMLKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+	Id          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+// returns the counts of a collapsed node as "key: count, ..."
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s: %d", key, counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writes graph as GraphML, the attributes of the nodes and edges being
+// declared as keys
+func WriteGraphML(w io.Writer, graph *Graph) error {
+	document := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{"pages", "node", "pages", "int"},
+			{"depth", "node", "depth", "int"},
+			{"status", "node", "status", "int"},
+			{"content_type", "node", "content_type", "string"},
+			{"statuses", "node", "statuses", "string"},
+			{"content_types", "node", "content_types", "string"},
+			{"source", "edge", "source", "string"},
+			{"count", "edge", "count", "int"},
+		},
+		Graph: graphMLGraph{
+			Id:          "crawl",
+			EdgeDefault: "directed",
+			Nodes:       make([]graphMLNode, len(graph.Nodes)),
+			Edges:       make([]graphMLEdge, len(graph.Edges)),
+		},
+	}
+
+	for i, node := range graph.Nodes {
+		data := []graphMLData{
+			{"pages", strconv.Itoa(node.Pages)},
+			{"depth", strconv.Itoa(node.Depth)},
+		}
+
+		if node.Status != 0 {
+			data = append(data, graphMLData{"status", strconv.Itoa(node.Status)}, graphMLData{"content_type", node.ContentType})
+		}
+
+		if len(node.Statuses) > 0 {
+			statuses := make(map[string]int, len(node.Statuses))
+			for status, count := range node.Statuses {
+				statuses[strconv.Itoa(status)] = count
+			}
+			data = append(data, graphMLData{"statuses", formatCounts(statuses)}, graphMLData{"content_types", formatCounts(node.ContentTypes)})
+		}
+
+		document.Graph.Nodes[i] = graphMLNode{Id: node.Id, Data: data}
+	}
+
+	for i, edge := range graph.Edges {
+		document.Graph.Edges[i] = graphMLEdge{
+			Source: edge.From,
+			Target: edge.To,
+			Data: []graphMLData{
+				{"source", edge.Source},
+				{"count", strconv.Itoa(edge.Count)},
+			},
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("graph::WriteGraphML -> %s", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("graph::WriteGraphML -> %s", err)
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("graph::WriteGraphML -> %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+const HAR_VERSION = "1.2"
+
+const CREATOR_NAME = "go-crawler"
+
+// the http version of the entries of pages fetched without recording it
+const DEFAULT_HTTP_VERSION = "HTTP/1.1"
+
+// the types of a HAR 1.2 file (http://www.softwareishard.com/blog/har-12-spec/),
+// the optional fields not known by the crawler being omitted
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Cookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HttpOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	Url         string      `json:"url"`
+	HttpVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HttpVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectUrl string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// the timings of an entry in milliseconds
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// the root of a HAR file
+type File struct {
+	Log Log `json:"log"`
+}
+
+// returns the headers sorted by name
+func headersToNameValues(headers http.Header) []NameValue {
+	result := make([]NameValue, 0, len(headers))
+	for name, values := range headers {
+		for _, value := range values {
+			result = append(result, NameValue{name, value})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func toCookies(cookies []*http.Cookie) []Cookie {
+	result := make([]Cookie, len(cookies))
+	for i, cookie := range cookies {
+		result[i] = Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HttpOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			result[i].Expires = cookie.Expires.Format(time.RFC3339)
+		}
+	}
+	return result
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+// returns the entry of a fetched page, body being included up to
+// maxBodySize bytes (not at all if 0, entirely if negative)
+func NewEntry(pageResult crawler.PageResult, body []byte, maxBodySize int) Entry {
+	url := pageResult.Url
+
+	queryString := make([]NameValue, 0, len(url.Parameters))
+	for name, value := range url.Parameters {
+		queryString = append(queryString, NameValue{name, value})
+	}
+	sort.Slice(queryString, func(i, j int) bool {
+		return queryString[i].Name < queryString[j].Name
+	})
+
+	httpVersion := pageResult.Protocol
+	if len(httpVersion) == 0 {
+		httpVersion = DEFAULT_HTTP_VERSION
+	}
+
+	entry := Entry{
+		Request: Request{
+			Method:      url.GetMethod(),
+			Url:         url.ToUrl(),
+			HttpVersion: httpVersion,
+			Cookies:     toCookies((&http.Request{Header: pageResult.RequestHeaders}).Cookies()),
+			Headers:     headersToNameValues(pageResult.RequestHeaders),
+			QueryString: queryString,
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: Response{
+			Status:      pageResult.StatusCode,
+			StatusText:  http.StatusText(pageResult.StatusCode),
+			HttpVersion: httpVersion,
+			Cookies:     toCookies((&http.Response{Header: pageResult.Headers}).Cookies()),
+			Headers:     headersToNameValues(pageResult.Headers),
+			Content: Content{
+				Size:     pageResult.ContentLength,
+				MimeType: pageResult.Headers.Get("Content-Type"),
+			},
+			RedirectUrl: pageResult.Headers.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    pageResult.ContentLength,
+		},
+	}
+
+	if body != nil {
+		entry.Response.Content.Size = int64(len(body))
+	}
+
+	// scans saved before the timings were recorded having none
+	if pageResult.Timings != nil {
+		entry.StartedDateTime = pageResult.Timings.Start
+		entry.Timings = Timings{
+			Wait:    milliseconds(pageResult.Timings.Wait),
+			Receive: milliseconds(pageResult.Timings.Receive),
+		}
+		entry.Time = milliseconds(pageResult.Timings.Wait + pageResult.Timings.Receive)
+	}
+
+	if maxBodySize != 0 && len(body) > 0 {
+		if maxBodySize > 0 && len(body) > maxBodySize {
+			body = body[:maxBodySize]
+			entry.Response.Content.Comment = fmt.Sprintf("truncated to %d bytes", maxBodySize)
+		}
+
+		if utf8.Valid(body) {
+			entry.Response.Content.Text = string(body)
+		} else {
+			entry.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+			entry.Response.Content.Encoding = "base64"
+		}
+	}
+
+	return entry
+}
+
+// writes the entries of a HAR file as they are added, the file being
+// complete once closed
+type Writer struct {
+	writer      io.Writer
+	maxBodySize int
+	entries     int
+
+	// the first error of the analyzer, returned by Close
+	err error
+	sync.Mutex
+}
+
+// writes the beginning of the HAR file to writer, maxBodySize being the
+// max size of the bodies of the entries (see NewEntry)
+func NewWriter(writer io.Writer, creator Creator, maxBodySize int) (*Writer, error) {
+	body, err := json.Marshal(creator)
+	if err != nil {
+		return nil, fmt.Errorf("har::NewWriter -> %s", err)
+	}
+
+	if _, err = fmt.Fprintf(writer, "{\"log\":{\"version\":%q,\"creator\":%s,\"entries\":[\n", HAR_VERSION, body); err != nil {
+		return nil, fmt.Errorf("har::NewWriter -> %s", err)
+	}
+
+	return &Writer{
+		writer:      writer,
+		maxBodySize: maxBodySize,
+	}, nil
+}
+
+func (w *Writer) WriteEntry(entry Entry) error {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.writeEntry(entry)
+}
+
+func (w *Writer) writeEntry(entry Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("har::Writer.WriteEntry -> %s", err)
+	}
+
+	if w.entries > 0 {
+		body = append([]byte(",\n"), body...)
+	}
+
+	if _, err = w.writer.Write(body); err != nil {
+		return fmt.Errorf("har::Writer.WriteEntry -> %s", err)
+	}
+	w.entries++
+	return nil
+}
+
+// returns an analyzer writing an entry per fetched page, the errors being
+// returned by Close
+func (w *Writer) Analyzer() crawler.PageAnalyzer {
+	return func(body []byte, pageResult *crawler.PageResult) {
+		entry := NewEntry(*pageResult, body, w.maxBodySize)
+
+		w.Lock()
+		defer w.Unlock()
+
+		if w.err != nil {
+			return
+		}
+		w.err = w.writeEntry(entry)
+	}
+}
+
+// writes the end of the HAR file, returns the first error of the analyzer
+func (w *Writer) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if _, err := io.WriteString(w.writer, "\n]}}\n"); err != nil && w.err == nil {
+		w.err = fmt.Errorf("har::Writer.Close -> %s", err)
+	}
+	return w.err
+}
+
+// writes the HAR file of the pages of a saved scan ordered by start time,
+// the bodies not being saved and the credentials of the request headers
+// being redacted, see crawler.RedactHeaders
+func Export(writer io.Writer, creator Creator, data *crawler.CrawlerData) error {
+	entries := make([]Entry, 0)
+
+	domainNames := make([]string, 0, len(data.FetchedUrls))
+	for domainName := range data.FetchedUrls {
+		domainNames = append(domainNames, domainName)
+	}
+	sort.Strings(domainNames)
+
+	for _, domainName := range domainNames {
+		domainResults := data.FetchedUrls[domainName]
+
+		baseUrls := make([]string, 0, len(domainResults))
+		for baseUrl := range domainResults {
+			baseUrls = append(baseUrls, baseUrl)
+		}
+		sort.Strings(baseUrls)
+
+		for _, baseUrl := range baseUrls {
+			for _, pageResult := range domainResults[baseUrl].PageResults {
+				entries = append(entries, NewEntry(pageResult, nil, 0))
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	harWriter, err := NewWriter(writer, creator, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err = harWriter.WriteEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return harWriter.Close()
+}
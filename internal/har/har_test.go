@@ -0,0 +1,199 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func testPageResult() crawler.PageResult {
+	return crawler.PageResult{
+		Url:           crawler.PageRequestFromUrl("https://example.com/search?q=test&a=1"),
+		StatusCode:    302,
+		ContentLength: 11,
+		Headers: http.Header{
+			"Content-Type": {"text/html"},
+			"Location":     {"https://example.com/login"},
+			"Set-Cookie":   {"session=abc; Path=/; HttpOnly"},
+		},
+		RequestHeaders: http.Header{
+			"User-Agent": {"go-crawler"},
+			"Cookie":     {"a=b"},
+		},
+		Protocol: "HTTP/2.0",
+		Timings: &crawler.Timings{
+			Start:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Wait:    80 * time.Millisecond,
+			Receive: 4 * time.Millisecond,
+		},
+	}
+}
+
+func TestNewEntry(t *testing.T) {
+	entry := NewEntry(testPageResult(), nil, 0)
+
+	if entry.Request.Method != "GET" || entry.Request.HttpVersion != "HTTP/2.0" {
+		t.Errorf("unexpected request line: %s %s", entry.Request.Method, entry.Request.HttpVersion)
+	}
+	if len(entry.Request.QueryString) != 2 || entry.Request.QueryString[0].Name != "a" {
+		t.Errorf("query string not sorted: %v", entry.Request.QueryString)
+	}
+	if len(entry.Request.Cookies) != 1 || entry.Request.Cookies[0].Value != "b" {
+		t.Errorf("unexpected request cookies: %v", entry.Request.Cookies)
+	}
+	if len(entry.Response.Cookies) != 1 || !entry.Response.Cookies[0].HttpOnly {
+		t.Errorf("unexpected response cookies: %v", entry.Response.Cookies)
+	}
+	if entry.Response.RedirectUrl != "https://example.com/login" || entry.Response.StatusText != "Found" {
+		t.Errorf("unexpected response: %s %s", entry.Response.StatusText, entry.Response.RedirectUrl)
+	}
+	if entry.Time != 84 || entry.Timings.Wait != 80 || entry.Timings.Receive != 4 {
+		t.Errorf("unexpected timings: %v %v", entry.Time, entry.Timings)
+	}
+	if len(entry.Response.Content.Text) != 0 {
+		t.Errorf("body included without maxBodySize")
+	}
+}
+
+func TestNewEntryBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		maxBodySize int
+		text        string
+		encoding    string
+		truncated   bool
+	}{
+		{"no body", []byte("hello world"), 0, "", "", false},
+		{"whole body", []byte("hello world"), -1, "hello world", "", false},
+		{"under the limit", []byte("hello world"), 100, "hello world", "", false},
+		{"truncated", []byte("hello world"), 5, "hello", "", true},
+		{"binary", []byte{0xff, 0xfe, 0x00}, -1, "//4A", "base64", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := NewEntry(testPageResult(), test.body, test.maxBodySize).Response.Content
+			if content.Text != test.text || content.Encoding != test.encoding {
+				t.Errorf("got %q (%s), expected %q (%s)", content.Text, content.Encoding, test.text, test.encoding)
+			}
+			if (len(content.Comment) > 0) != test.truncated {
+				t.Errorf("unexpected comment %q", content.Comment)
+			}
+			if content.Size != int64(len(test.body)) {
+				t.Errorf("got size %d, expected %d", content.Size, len(test.body))
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries int
+	}{
+		{"empty", 0},
+		{"single entry", 1},
+		{"several entries", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			writer, err := NewWriter(&buffer, Creator{Name: CREATOR_NAME, Version: "test"}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			analyze := writer.Analyzer()
+			for i := 0; i < test.entries; i++ {
+				pageResult := testPageResult()
+				analyze([]byte("body"), &pageResult)
+			}
+
+			if err = writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var file File
+			if err = json.Unmarshal(buffer.Bytes(), &file); err != nil {
+				t.Fatalf("invalid HAR file: %s\n%s", err, buffer.String())
+			}
+			if file.Log.Version != HAR_VERSION || len(file.Log.Entries) != test.entries {
+				t.Errorf("got version %s and %d entries", file.Log.Version, len(file.Log.Entries))
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	data := crawler.NewCrawlerData()
+
+	late := testPageResult()
+	early := testPageResult()
+	early.Url = crawler.PageRequestFromUrl("https://example.com/first")
+	early.Timings = &crawler.Timings{Start: late.Timings.Start.Add(-time.Minute)}
+	data.AddFetchedUrl(late)
+	data.AddFetchedUrl(early)
+
+	var buffer bytes.Buffer
+	if err := Export(&buffer, Creator{Name: CREATOR_NAME}, data); err != nil {
+		t.Fatal(err)
+	}
+
+	var file File
+	if err := json.Unmarshal(buffer.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Log.Entries) != 2 || !strings.HasSuffix(file.Log.Entries[0].Request.Url, "/first") {
+		t.Errorf("entries not ordered by start time: %v", file.Log.Entries)
+	}
+}
+
+func TestExportSavedScan(t *testing.T) {
+	pageResult := testPageResult()
+	pageResult.RequestHeaders.Set("Authorization", "Bearer secret")
+	pageResult.RequestHeaders = crawler.RedactHeaders(pageResult.RequestHeaders)
+
+	data := crawler.NewCrawlerData()
+	data.AddFetchedUrl(pageResult)
+
+	// the scan being read back from its file
+	saved, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = crawler.NewCrawlerData()
+	if err = json.Unmarshal(saved, data); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err = Export(&buffer, Creator{Name: CREATOR_NAME}, data); err != nil {
+		t.Fatal(err)
+	}
+
+	var file File
+	if err = json.Unmarshal(buffer.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+	request := file.Log.Entries[0].Request
+
+	headers := make(map[string]string)
+	for _, header := range request.Headers {
+		headers[header.Name] = header.Value
+	}
+	expected := map[string]string{"User-Agent": "go-crawler", "Cookie": "a=REDACTED", "Authorization": "Bearer REDACTED"}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("expected request headers %v, got %v", expected, headers)
+	}
+	if len(request.Cookies) != 1 || request.Cookies[0].Name != "a" || request.Cookies[0].Value != "REDACTED" {
+		t.Errorf("unexpected cookies %v", request.Cookies)
+	}
+}
@@ -0,0 +1,672 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// the version of the stdio protocol spoken with exec plugins, version 2
+// adding the beforeRequest, onUrlsFound, onError and analyzePage entry hooks
+const EXEC_PROTOCOL_VERSION = 2
+
+// the oldest version of the stdio protocol supported by the crawler
+const MIN_EXEC_PROTOCOL_VERSION = 1
+
+const JSON_RPC_VERSION = "2.0"
+
+// the rpc methods of the exec plugin protocol
+const (
+	EXEC_METHOD_INITIALIZE           = "initialize"
+	EXEC_METHOD_ON_PAGE_RESULT_ADDED = "onPageResultAdded"
+	EXEC_METHOD_SHUTDOWN             = "shutdown"
+
+	// since protocol version 2
+	EXEC_METHOD_BEFORE_REQUEST = "beforeRequest"
+	EXEC_METHOD_ON_URLS_FOUND  = "onUrlsFound"
+	EXEC_METHOD_ON_ERROR       = "onError"
+	EXEC_METHOD_ANALYZE_PAGE   = "analyzePage"
+)
+
+var DEFAULT_EXEC_TIMEOUT = 10 * time.Second
+
+const DEFAULT_EXEC_MAX_RESTARTS = 3
+
+var ErrExecTimeout = errors.New("exec plugin: call timed out")
+var ErrExecStopped = errors.New("exec plugin: process stopped")
+
+type rpcRequest struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Id      uint64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+type ExecInitializeParams struct {
+	ProtocolVersion int               `json:"protocol_version"`
+	Settings        map[string]string `json:"settings"`
+}
+
+type ExecEntry struct {
+	DomainName   string   `json:"domain_name"`
+	MatchMode    string   `json:"match_mode"`
+	PathPrefix   string   `json:"path_prefix"`
+	ContentTypes []string `json:"content_types"`
+
+	// the methods called for the pages of the entry, onPageResultAdded if empty
+	Hooks []string `json:"hooks"`
+}
+
+type ExecInitializeResult struct {
+	// the version of the protocol spoken by the plugin, 1 if not set
+	ProtocolVersion int `json:"protocol_version"`
+
+	Entries  []ExecEntry     `json:"entries"`
+	Settings []SettingSchema `json:"settings"`
+	Metadata PluginMetadata  `json:"metadata"`
+}
+
+type ExecOnPageResultAddedParams struct {
+	DomainName    string                    `json:"domain_name"`
+	PageResult    crawler.PageResult        `json:"page_result"`
+	Body          []byte                    `json:"body"`
+	DomainResults crawler.DomainResultEntry `json:"domain_results"`
+}
+
+type ExecOnPageResultAddedResult struct {
+	Attachements crawler.Attachements `json:"attachements"`
+}
+
+type ExecBeforeRequestParams struct {
+	DomainName string              `json:"domain_name"`
+	Url        crawler.PageRequest `json:"url"`
+	Method     string              `json:"method"`
+	Headers    http.Header         `json:"headers"`
+}
+
+type ExecBeforeRequestResult struct {
+	// the request is skipped if true
+	Skip bool `json:"skip"`
+
+	// the headers set on the request, replacing their values
+	Headers map[string]string `json:"headers"`
+}
+
+type ExecOnUrlsFoundParams struct {
+	DomainName string                `json:"domain_name"`
+	PageResult crawler.PageResult    `json:"page_result"`
+	FoundUrls  []crawler.PageRequest `json:"found_urls"`
+}
+
+type ExecOnUrlsFoundResult struct {
+	// the urls to keep, all the found urls being kept if not set
+	Urls []crawler.PageRequest `json:"urls"`
+}
+
+type ExecOnErrorParams struct {
+	DomainName string              `json:"domain_name"`
+	Url        crawler.PageRequest `json:"url"`
+	Error      string              `json:"error"`
+}
+
+type ExecAnalyzePageParams struct {
+	DomainName string             `json:"domain_name"`
+	PageResult crawler.PageResult `json:"page_result"`
+	Body       []byte             `json:"body"`
+}
+
+type ExecAnalyzePageResult struct {
+	Findings []crawler.Finding `json:"findings"`
+}
+
+type ExecPluginOptions struct {
+	// the max duration of a call to the plugin
+	Timeout time.Duration
+
+	// the number of times the process is restarted after crashing or timing out
+	MaxRestarts int
+
+	// the settings sent to the process on initialization, set by Initialize
+	// once validated against the schema of the plugin
+	Settings map[string]string
+
+	// sends the requests whose beforeRequest call fails, the requests being skipped if false
+	FailOpen bool
+}
+
+func NewExecPluginOptions() *ExecPluginOptions {
+	return &ExecPluginOptions{
+		Timeout:     DEFAULT_EXEC_TIMEOUT,
+		MaxRestarts: DEFAULT_EXEC_MAX_RESTARTS,
+	}
+}
+
+// a running instance of an exec plugin
+type execProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	nextId  uint64
+	pending map[uint64]chan rpcResponse
+	done    chan struct{}
+
+	writeLock sync.Mutex
+	sync.Mutex
+}
+
+func startExecProcess(path string, args []string) (*execProcess, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &execProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[uint64]chan rpcResponse),
+		done:    make(chan struct{}),
+	}
+
+	go proc.readResponses(stdout)
+
+	return proc, nil
+}
+
+func (proc *execProcess) readResponses(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var response rpcResponse
+			if jsonErr := json.Unmarshal(line, &response); jsonErr == nil {
+				proc.Lock()
+				ch, ok := proc.pending[response.Id]
+				delete(proc.pending, response.Id)
+				proc.Unlock()
+				if ok {
+					ch <- response
+				}
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	close(proc.done)
+	proc.cmd.Wait()
+}
+
+func (proc *execProcess) isRunning() bool {
+	select {
+	case <-proc.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (proc *execProcess) send(request rpcRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	proc.writeLock.Lock()
+	defer proc.writeLock.Unlock()
+	_, err = proc.stdin.Write(append(body, '\n'))
+	return err
+}
+
+func (proc *execProcess) call(method string, params interface{}, result interface{}, timeout time.Duration) error {
+
+	id := atomic.AddUint64(&proc.nextId, 1)
+	ch := make(chan rpcResponse, 1)
+
+	proc.Lock()
+	proc.pending[id] = ch
+	proc.Unlock()
+
+	defer func() {
+		proc.Lock()
+		delete(proc.pending, id)
+		proc.Unlock()
+	}()
+
+	err := proc.send(rpcRequest{
+		JsonRpc: JSON_RPC_VERSION,
+		Id:      id,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case response := <-ch:
+		if response.Error != nil {
+			return fmt.Errorf("exec plugin: %s failed (%d): %s", method, response.Error.Code, response.Error.Message)
+		}
+		if result != nil {
+			return json.Unmarshal(response.Result, result)
+		}
+		return nil
+	case <-proc.done:
+		return ErrExecStopped
+	case <-time.After(timeout):
+		return ErrExecTimeout
+	}
+}
+
+func (proc *execProcess) kill() {
+	if proc.cmd.Process != nil {
+		proc.cmd.Process.Kill()
+	}
+}
+
+// asks the process to stop, killing it if it is still running after timeout
+func (proc *execProcess) stop(timeout time.Duration) {
+	if !proc.isRunning() {
+		return
+	}
+
+	proc.send(rpcRequest{
+		JsonRpc: JSON_RPC_VERSION,
+		Method:  EXEC_METHOD_SHUTDOWN,
+	})
+	proc.stdin.Close()
+
+	select {
+	case <-proc.done:
+	case <-time.After(timeout):
+		proc.kill()
+	}
+}
+
+// a plugin running as a separate executable speaking json-rpc over stdin/stdout,
+// the process is restarted when it crashes or times out
+type ExecPlugin struct {
+	path     string
+	args     []string
+	options  ExecPluginOptions
+	process  *execProcess
+	restarts int
+	closed   bool
+	sync.Mutex
+}
+
+func NewExecPlugin(path string, args []string, options *ExecPluginOptions) *ExecPlugin {
+	if options == nil {
+		options = NewExecPluginOptions()
+	}
+
+	return &ExecPlugin{
+		path:    path,
+		args:    args,
+		options: *options,
+	}
+}
+
+// returns the running process, starting it if needed
+func (p *ExecPlugin) getProcess() (*execProcess, *ExecInitializeResult, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return nil, nil, ErrExecStopped
+	}
+
+	if p.process != nil && p.process.isRunning() {
+		return p.process, nil, nil
+	}
+
+	if p.process != nil {
+		if p.restarts >= p.options.MaxRestarts {
+			return nil, nil, fmt.Errorf("exec plugin: %s stopped after %d restarts", p.path, p.restarts)
+		}
+		p.restarts++
+		log.Printf("exec plugin: restarting %s (%d/%d)\n", p.path, p.restarts, p.options.MaxRestarts)
+	}
+
+	proc, err := startExecProcess(p.path, p.args)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.process = proc
+
+	var initResult ExecInitializeResult
+	err = proc.call(EXEC_METHOD_INITIALIZE, ExecInitializeParams{
+		ProtocolVersion: EXEC_PROTOCOL_VERSION,
+		Settings:        p.options.Settings,
+	}, &initResult, p.options.Timeout)
+
+	if err == nil {
+		err = checkExecProtocolVersion(p.path, initResult.ProtocolVersion)
+	}
+
+	if err != nil {
+		proc.kill()
+		return nil, nil, err
+	}
+
+	return proc, &initResult, nil
+}
+
+// returns an error if the protocol version returned by an exec plugin is not supported by the crawler
+func checkExecProtocolVersion(path string, version int) error {
+	if version <= 0 {
+		version = 1
+	}
+
+	if version >= MIN_EXEC_PROTOCOL_VERSION && version <= EXEC_PROTOCOL_VERSION {
+		return nil
+	}
+
+	return &LoadError{
+		Path:   path,
+		Reason: fmt.Sprintf("incompatible exec protocol version %d", version),
+		Details: []string{
+			fmt.Sprintf("the crawler supports exec protocol versions %d to %d", MIN_EXEC_PROTOCOL_VERSION, EXEC_PROTOCOL_VERSION),
+			"upgrade the crawler or the plugin",
+		},
+	}
+}
+
+// starts the plugin process and returns its initialization result
+func (p *ExecPlugin) Start() (*ExecInitializeResult, error) {
+	_, initResult, err := p.getProcess()
+	if err == nil && initResult == nil {
+		err = errors.New("exec plugin: already started")
+	}
+	return initResult, err
+}
+
+// calls method on the plugin process, a process timing out is killed
+// to be restarted on the next call, a call interrupted by a crash is retried once
+func (p *ExecPlugin) Call(method string, params interface{}, result interface{}) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var proc *execProcess
+		proc, _, err = p.getProcess()
+		if err != nil {
+			return err
+		}
+
+		err = proc.call(method, params, result, p.options.Timeout)
+		if errors.Is(err, ErrExecTimeout) {
+			proc.kill()
+		}
+
+		if !errors.Is(err, ErrExecStopped) {
+			break
+		}
+	}
+
+	return err
+}
+
+// asks the plugin process to stop, killing it if it is still running after the timeout
+func (p *ExecPlugin) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.closed = true
+	if p.process != nil {
+		p.process.stop(p.options.Timeout)
+	}
+
+	return nil
+}
+
+func equalSettings(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sets the settings sent on initialization, the process being restarted
+// to be initialized with them if they differ from the ones it was started with
+func (p *ExecPlugin) SetSettings(settings map[string]string) error {
+	p.Lock()
+	if equalSettings(settings, p.options.Settings) {
+		p.Unlock()
+		return nil
+	}
+
+	p.options.Settings = settings
+	proc := p.process
+	p.process = nil
+	p.Unlock()
+
+	if proc != nil {
+		proc.stop(p.options.Timeout)
+	}
+
+	_, _, err := p.getProcess()
+	return err
+}
+
+func (p *ExecPlugin) onPageResultAdded(crPlugin *CrawlerPlugin) crawler.OnPageResultAdded {
+	return func(body []byte, pageResult crawler.PageResult, domainResults crawler.DomainResultEntry) crawler.Attachements {
+		var result ExecOnPageResultAddedResult
+		err := p.Call(EXEC_METHOD_ON_PAGE_RESULT_ADDED, ExecOnPageResultAddedParams{
+			DomainName:    crawler.ExtractDomainName(pageResult.Url.BaseUrl),
+			PageResult:    pageResult,
+			Body:          body,
+			DomainResults: domainResults,
+		}, &result)
+
+		if err != nil {
+			crPlugin.hookFailed("OnPageResultAdded", err)
+			return crawler.NewAttachements()
+		}
+
+		return result.Attachements
+	}
+}
+
+func (p *ExecPlugin) beforeRequest(crPlugin *CrawlerPlugin) crawler.BeforeRequest {
+	return func(request *http.Request, url crawler.PageRequest) bool {
+		var result ExecBeforeRequestResult
+		err := p.Call(EXEC_METHOD_BEFORE_REQUEST, ExecBeforeRequestParams{
+			DomainName: crawler.ExtractDomainName(url.BaseUrl),
+			Url:        url,
+			Method:     request.Method,
+			Headers:    request.Header,
+		}, &result)
+
+		if err != nil {
+			crPlugin.hookFailed("BeforeRequest", err)
+			return p.options.FailOpen
+		}
+
+		for name, value := range result.Headers {
+			request.Header.Set(name, value)
+		}
+		return !result.Skip
+	}
+}
+
+func (p *ExecPlugin) onUrlsFound(crPlugin *CrawlerPlugin) crawler.OnUrlsFound {
+	return func(pageResult crawler.PageResult, foundUrls []crawler.PageRequest) []crawler.PageRequest {
+		var result ExecOnUrlsFoundResult
+		err := p.Call(EXEC_METHOD_ON_URLS_FOUND, ExecOnUrlsFoundParams{
+			DomainName: crawler.ExtractDomainName(pageResult.Url.BaseUrl),
+			PageResult: pageResult,
+			FoundUrls:  foundUrls,
+		}, &result)
+
+		if err != nil {
+			crPlugin.hookFailed("OnUrlsFound", err)
+			return foundUrls
+		}
+
+		if result.Urls == nil {
+			return foundUrls
+		}
+		return result.Urls
+	}
+}
+
+func (p *ExecPlugin) onError(crPlugin *CrawlerPlugin) crawler.OnError {
+	return func(url crawler.PageRequest, urlErr error) {
+		err := p.Call(EXEC_METHOD_ON_ERROR, ExecOnErrorParams{
+			DomainName: crawler.ExtractDomainName(url.BaseUrl),
+			Url:        url,
+			Error:      urlErr.Error(),
+		}, nil)
+
+		if err != nil {
+			crPlugin.hookFailed("OnError", err)
+		}
+	}
+}
+
+func (p *ExecPlugin) analyzePage(crPlugin *CrawlerPlugin) crawler.AnalyzePage {
+	return func(body []byte, pageResult crawler.PageResult) []crawler.Finding {
+		var result ExecAnalyzePageResult
+		err := p.Call(EXEC_METHOD_ANALYZE_PAGE, ExecAnalyzePageParams{
+			DomainName: crawler.ExtractDomainName(pageResult.Url.BaseUrl),
+			PageResult: pageResult,
+			Body:       body,
+		}, &result)
+
+		if err != nil {
+			crPlugin.hookFailed("AnalyzePage", err)
+			return nil
+		}
+
+		return result.Findings
+	}
+}
+
+// sets the hooks of entry calling the methods of the exec plugin listed in hooks
+func (p *ExecPlugin) setEntryHooks(crPlugin *CrawlerPlugin, entry *CrawlerPluginEntry, hooks []string) error {
+	if len(hooks) == 0 {
+		hooks = []string{EXEC_METHOD_ON_PAGE_RESULT_ADDED}
+	}
+
+	for _, hook := range hooks {
+		switch hook {
+		case EXEC_METHOD_ON_PAGE_RESULT_ADDED:
+			handler := p.onPageResultAdded(crPlugin)
+			entry.OnPageResultAdded = &handler
+		case EXEC_METHOD_BEFORE_REQUEST:
+			handler := p.beforeRequest(crPlugin)
+			entry.BeforeRequest = &handler
+		case EXEC_METHOD_ON_URLS_FOUND:
+			handler := p.onUrlsFound(crPlugin)
+			entry.OnUrlsFound = &handler
+		case EXEC_METHOD_ON_ERROR:
+			handler := p.onError(crPlugin)
+			entry.OnError = &handler
+		case EXEC_METHOD_ANALYZE_PAGE:
+			handler := p.analyzePage(crPlugin)
+			entry.AnalyzePage = &handler
+		default:
+			return &LoadError{
+				Path:   p.path,
+				Reason: fmt.Sprintf("unknown hook %s of entry %s", hook, entry.DomainName),
+				Details: []string{
+					fmt.Sprintf("the hooks of an entry are %s, %s, %s, %s and %s", EXEC_METHOD_ON_PAGE_RESULT_ADDED, EXEC_METHOD_BEFORE_REQUEST,
+						EXEC_METHOD_ON_URLS_FOUND, EXEC_METHOD_ON_ERROR, EXEC_METHOD_ANALYZE_PAGE),
+				},
+			}
+		}
+	}
+
+	return nil
+}
+
+// starts the executable at path and returns a CrawlerPlugin forwarding
+// its calls to the process, the failed calls being reported to the
+// error handler of the plugin, see CrawlerPlugin.SetErrorHandler
+func GetExecPlugin(path string, args []string, options *ExecPluginOptions) (*CrawlerPlugin, error) {
+	execPlugin := NewExecPlugin(path, args, options)
+
+	initResult, err := execPlugin.Start()
+	if err != nil {
+		execPlugin.Close()
+		return nil, err
+	}
+
+	// the process being initialized with the settings validated against its schema by Initialize
+	var onInit OnInit = execPlugin.SetSettings
+
+	crPlugin := &CrawlerPlugin{
+		Entries:  make([]*CrawlerPluginEntry, len(initResult.Entries)),
+		Filters:  make(map[string]*crawler.ShouldAddFilter),
+		Settings: initResult.Settings,
+		Metadata: initResult.Metadata,
+		OnInit:   &onInit,
+		closer:   execPlugin,
+	}
+
+	for i, entry := range initResult.Entries {
+		matchMode, err := ParseDomainMatchMode(entry.MatchMode)
+		if err != nil {
+			execPlugin.Close()
+			return nil, err
+		}
+
+		crPlugin.Entries[i] = &CrawlerPluginEntry{
+			DomainName:   entry.DomainName,
+			MatchMode:    matchMode,
+			PathPrefix:   entry.PathPrefix,
+			ContentTypes: entry.ContentTypes,
+		}
+		if err = execPlugin.setEntryHooks(crPlugin, crPlugin.Entries[i], entry.Hooks); err != nil {
+			execPlugin.Close()
+			return nil, err
+		}
+	}
+
+	if err = compileEntries(path, crPlugin); err != nil {
+		execPlugin.Close()
+		return nil, err
+	}
+
+	return crPlugin, nil
+}
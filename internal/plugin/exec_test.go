@@ -0,0 +1,291 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// the argument making the test binary run as a fake exec plugin, see runFakePlugin
+const FAKE_PLUGIN_ARG = "-fake-exec-plugin"
+
+func TestMain(m *testing.M) {
+	if len(os.Args) == 3 && os.Args[1] == FAKE_PLUGIN_ARG {
+		runFakePlugin(os.Args[2])
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// answers the requests read from stdin, the hook calls failing according to mode:
+// "crash" exits, "timeout" never answers, "malformed" answers an invalid result,
+// "error" answers an error and "version" declares an unsupported protocol version
+func runFakePlugin(mode string) {
+	reader := bufio.NewReader(os.Stdin)
+	settings := make(map[string]string)
+
+	respond := func(id uint64, result interface{}) {
+		body, _ := json.Marshal(result)
+		response, _ := json.Marshal(rpcResponse{JsonRpc: JSON_RPC_VERSION, Id: id, Result: body})
+		fmt.Println(string(response))
+	}
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+
+		var request struct {
+			Id     uint64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err = json.Unmarshal(line, &request); err != nil {
+			return
+		}
+
+		switch request.Method {
+		case EXEC_METHOD_INITIALIZE:
+			var params ExecInitializeParams
+			json.Unmarshal(request.Params, &params)
+			settings = params.Settings
+
+			version := EXEC_PROTOCOL_VERSION
+			if mode == "version" {
+				version = EXEC_PROTOCOL_VERSION + 1
+			}
+			respond(request.Id, ExecInitializeResult{
+				ProtocolVersion: version,
+				Entries: []ExecEntry{{
+					DomainName: "example.com",
+					Hooks:      []string{EXEC_METHOD_ON_PAGE_RESULT_ADDED, EXEC_METHOD_BEFORE_REQUEST, EXEC_METHOD_ON_URLS_FOUND, EXEC_METHOD_ON_ERROR, EXEC_METHOD_ANALYZE_PAGE},
+				}},
+				Settings: []SettingSchema{{Name: "token", Default: "default"}},
+				Metadata: PluginMetadata{Name: "fake", Version: "1.0.0"},
+			})
+			continue
+		case EXEC_METHOD_SHUTDOWN:
+			return
+		}
+
+		switch mode {
+		case "crash":
+			os.Exit(1)
+		case "timeout":
+			time.Sleep(time.Hour)
+		case "malformed":
+			fmt.Println("not json")
+			fmt.Printf(`{"jsonrpc": "2.0", "id": %d, "result": {"attachements": "value", "skip": "no", "urls": {}, "findings": 1}}`+"\n", request.Id)
+			continue
+		case "error":
+			fmt.Printf(`{"jsonrpc": "2.0", "id": %d, "error": {"code": -32000, "message": "failed"}}`+"\n", request.Id)
+			continue
+		}
+
+		switch request.Method {
+		case EXEC_METHOD_ON_PAGE_RESULT_ADDED:
+			var params ExecOnPageResultAddedParams
+			json.Unmarshal(request.Params, &params)
+			respond(request.Id, ExecOnPageResultAddedResult{Attachements: crawler.Attachements{
+				"token": settings["token"],
+				"body":  string(params.Body),
+			}})
+		case EXEC_METHOD_BEFORE_REQUEST:
+			var params ExecBeforeRequestParams
+			json.Unmarshal(request.Params, &params)
+			respond(request.Id, ExecBeforeRequestResult{
+				Skip:    params.Url.GetPath() == "/skip",
+				Headers: map[string]string{"X-Token": settings["token"]},
+			})
+		case EXEC_METHOD_ON_URLS_FOUND:
+			var params ExecOnUrlsFoundParams
+			json.Unmarshal(request.Params, &params)
+			respond(request.Id, ExecOnUrlsFoundResult{Urls: params.FoundUrls[:1]})
+		case EXEC_METHOD_ON_ERROR:
+			respond(request.Id, nil)
+		case EXEC_METHOD_ANALYZE_PAGE:
+			respond(request.Id, ExecAnalyzePageResult{Findings: []crawler.Finding{{Type: "fake", Severity: crawler.SEVERITY_LOW, Message: "found"}}})
+		}
+	}
+}
+
+func startFakePlugin(t *testing.T, mode string, options *ExecPluginOptions) (*CrawlerPlugin, error) {
+	p, err := GetExecPlugin(os.Args[0], []string{FAKE_PLUGIN_ARG, mode}, options)
+	if err == nil {
+		t.Cleanup(func() { p.Close() })
+	}
+	return p, err
+}
+
+func testPage(url string) crawler.PageResult {
+	return crawler.PageResult{
+		Url:        crawler.PageRequestFromUrl(url),
+		StatusCode: 200,
+		Headers:    http.Header{"Content-Type": {"text/html"}},
+	}
+}
+
+func TestExecPlugin(t *testing.T) {
+	p, err := startFakePlugin(t, "ok", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Metadata.Name != "fake" || len(p.Settings) != 1 || len(p.Entries) != 1 {
+		t.Fatalf("unexpected plugin %+v", p)
+	}
+	if hooks := strings.Join(p.SupportedHooks(), ","); hooks != "OnInit,BeforeRequest,OnPageResultAdded,OnUrlsFound,OnError,AnalyzePage" {
+		t.Errorf("unexpected hooks %s", hooks)
+	}
+
+	// the process is restarted with the validated settings
+	if err = p.Initialize(map[string]string{"token": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Initialize(map[string]string{"other": "value"}); err == nil {
+		t.Errorf("expected an error for an unknown setting")
+	}
+
+	failures := make([]string, 0)
+	p.SetErrorHandler(func(hook string, err error) { failures = append(failures, hook) })
+	hooks := p.Entries[0].GetHooks()
+
+	attachements := hooks.OnPageResultAdded([]byte("<html>"), testPage("https://example.com/"), *crawler.NewDomainResultEntry())
+	if attachements["token"] != "secret" || attachements["body"] != "<html>" {
+		t.Errorf("unexpected attachements %v", attachements)
+	}
+
+	tests := []struct {
+		url    string
+		sent   bool
+		header string
+	}{
+		{"https://example.com/", true, "secret"},
+		{"https://example.com/skip", false, "secret"},
+		{"https://other.com/", true, ""},
+	}
+	for _, test := range tests {
+		request, _ := http.NewRequest("GET", test.url, nil)
+		if sent := hooks.BeforeRequest(request, crawler.PageRequestFromUrl(test.url)); sent != test.sent {
+			t.Errorf("%s: expected sent %v, got %v", test.url, test.sent, sent)
+		}
+		if header := request.Header.Get("X-Token"); header != test.header {
+			t.Errorf("%s: expected header %q, got %q", test.url, test.header, header)
+		}
+	}
+
+	found := []crawler.PageRequest{crawler.PageRequestFromUrl("https://example.com/a"), crawler.PageRequestFromUrl("https://example.com/b")}
+	if urls := hooks.OnUrlsFound(testPage("https://example.com/"), found); len(urls) != 1 || urls[0].BaseUrl != "https://example.com/a" {
+		t.Errorf("unexpected urls %v", urls)
+	}
+
+	hooks.OnError(crawler.PageRequestFromUrl("https://example.com/"), errors.New("connection refused"))
+
+	if findings := hooks.AnalyzePage(nil, testPage("https://example.com/")); len(findings) != 1 || findings[0].Type != "fake" {
+		t.Errorf("unexpected findings %v", findings)
+	}
+
+	if len(failures) != 0 {
+		t.Errorf("unexpected failures %v", failures)
+	}
+}
+
+func TestExecPluginFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		failOpen bool
+		// the error of the first call, the process being restarted once
+		err string
+	}{
+		{"malformed response", "malformed", false, "cannot unmarshal"},
+		{"error response", "error", false, "failed (-32000): failed"},
+		{"crash", "crash", false, ErrExecStopped.Error()},
+		{"crash fail open", "crash", true, ErrExecStopped.Error()},
+		{"timeout", "timeout", false, ErrExecTimeout.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := NewExecPluginOptions()
+			options.Timeout = 500 * time.Millisecond
+			options.MaxRestarts = 1
+			options.FailOpen = test.failOpen
+
+			p, err := startFakePlugin(t, test.mode, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lock sync.Mutex
+			failures := make([]string, 0)
+			p.SetErrorHandler(func(hook string, err error) {
+				lock.Lock()
+				defer lock.Unlock()
+				failures = append(failures, hook+": "+err.Error())
+			})
+			hooks := p.Entries[0].GetHooks()
+
+			// the failed calls returning as if the hooks did nothing
+			if attachements := hooks.OnPageResultAdded(nil, testPage("https://example.com/"), *crawler.NewDomainResultEntry()); len(attachements) != 0 {
+				t.Errorf("unexpected attachements %v", attachements)
+			}
+			request, _ := http.NewRequest("GET", "https://example.com/", nil)
+			if sent := hooks.BeforeRequest(request, crawler.PageRequestFromUrl("https://example.com/")); sent != test.failOpen {
+				t.Errorf("expected sent %v, got %v", test.failOpen, sent)
+			}
+			if findings := hooks.AnalyzePage(nil, testPage("https://example.com/")); findings != nil {
+				t.Errorf("unexpected findings %v", findings)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if len(failures) != 3 {
+				t.Fatalf("expected 3 failures, got %v", failures)
+			}
+			if !strings.HasPrefix(failures[0], "OnPageResultAdded: ") || !strings.Contains(failures[0], test.err) {
+				t.Errorf("unexpected failures %v", failures)
+			}
+		})
+	}
+}
+
+func TestExecPluginLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		args   []string
+		reason string
+	}{
+		{"protocol version", os.Args[0], []string{FAKE_PLUGIN_ARG, "version"}, "incompatible exec protocol version"},
+		{"missing executable", "/nonexistent/plugin", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := GetExecPlugin(test.path, test.args, nil)
+			if err == nil {
+				p.Close()
+				t.Fatal("expected an error")
+			}
+
+			var loadErr *LoadError
+			if isLoadError := errors.As(err, &loadErr); isLoadError != (len(test.reason) > 0) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if loadErr != nil && !strings.Contains(loadErr.Reason, test.reason) {
+				t.Errorf("expected reason %q, got %q", test.reason, loadErr.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+var DEFAULT_GUARD_TIMEOUT = 30 * time.Second
+
+const DEFAULT_GUARD_MAX_FAILURES = 10
+
+type GuardOptions struct {
+	// the max duration of a hook call, including the wait for a concurrency slot
+	Timeout time.Duration
+
+	// the max number of concurrent hook calls, unlimited if <= 0, a call which
+	// timed out keeping its slot until it returns
+	MaxConcurrency int
+
+	// the number of consecutive failures after which the plugin is disabled, never disabled if <= 0
+	MaxFailures int
+
+	// sends the requests whose BeforeRequest hook fails or whose plugin is disabled,
+	// the requests being skipped if false (fail-closed)
+	FailOpen bool
+}
+
+func NewGuardOptions() *GuardOptions {
+	return &GuardOptions{
+		Timeout:     DEFAULT_GUARD_TIMEOUT,
+		MaxFailures: DEFAULT_GUARD_MAX_FAILURES,
+	}
+}
+
+// the counters of a PluginGuard
+type GuardStats struct {
+	Calls    uint64
+	Errors   uint64
+	Panics   uint64
+	Timeouts uint64
+	Disabled bool
+
+	// the last failure of the plugin
+	LastError string
+}
+
+func (stats GuardStats) String() string {
+	result := fmt.Sprintf("%d calls, %d errors, %d panics, %d timeouts", stats.Calls, stats.Errors, stats.Panics, stats.Timeouts)
+	if stats.Disabled {
+		result += ", disabled"
+	}
+	if len(stats.LastError) > 0 {
+		result += " (last error: " + stats.LastError + ")"
+	}
+	return result
+}
+
+// isolates the hooks of a plugin: panics are recovered, calls are bounded
+// by a timeout and a concurrency limit, and the plugin is disabled after
+// too many consecutive failures
+type PluginGuard struct {
+	Name    string
+	options GuardOptions
+
+	semaphore chan struct{}
+
+	calls               uint64
+	errors              uint64
+	panics              uint64
+	timeouts            uint64
+	consecutiveFailures int32
+	disabled            int32
+
+	lastError string
+	sync.Mutex
+}
+
+func NewPluginGuard(name string, options *GuardOptions) *PluginGuard {
+	if options == nil {
+		options = NewGuardOptions()
+	}
+
+	guard := &PluginGuard{
+		Name:    name,
+		options: *options,
+	}
+
+	if options.MaxConcurrency > 0 {
+		guard.semaphore = make(chan struct{}, options.MaxConcurrency)
+	}
+
+	return guard
+}
+
+func (g *PluginGuard) IsDisabled() bool {
+	return atomic.LoadInt32(&g.disabled) != 0
+}
+
+func (g *PluginGuard) GetStats() GuardStats {
+	g.Lock()
+	defer g.Unlock()
+
+	return GuardStats{
+		Calls:     atomic.LoadUint64(&g.calls),
+		Errors:    atomic.LoadUint64(&g.errors),
+		Panics:    atomic.LoadUint64(&g.panics),
+		Timeouts:  atomic.LoadUint64(&g.timeouts),
+		Disabled:  g.IsDisabled(),
+		LastError: g.lastError,
+	}
+}
+
+func (g *PluginGuard) fail(message string) {
+	g.Lock()
+	g.lastError = message
+	g.Unlock()
+
+	failures := atomic.AddInt32(&g.consecutiveFailures, 1)
+	if g.options.MaxFailures > 0 && int(failures) >= g.options.MaxFailures {
+		if atomic.CompareAndSwapInt32(&g.disabled, 0, 1) {
+			log.Printf("plugin %s disabled after %d consecutive failures: %s\n", g.Name, failures, message)
+		}
+	}
+}
+
+// counts err as a failure of a call to hook, for the hooks which cannot return
+// their errors, such as the calls of exec plugins (see CrawlerPlugin.SetErrorHandler)
+func (g *PluginGuard) Fail(hook string, err error) {
+	atomic.AddUint64(&g.errors, 1)
+	g.fail(fmt.Sprintf("%s: %s", hook, err))
+}
+
+// runs fn in its own goroutine, returns false if the plugin is disabled
+// or if fn panicked or did not complete in time, the concurrency slot of
+// a call being released when fn returns, even after it timed out
+func (g *PluginGuard) run(hook string, fn func()) bool {
+	if g.IsDisabled() {
+		return false
+	}
+
+	atomic.AddUint64(&g.calls, 1)
+	errors := atomic.LoadUint64(&g.errors)
+
+	var timeout <-chan time.Time
+	if g.options.Timeout > 0 {
+		timer := time.NewTimer(g.options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	if g.semaphore != nil {
+		select {
+		case g.semaphore <- struct{}{}:
+		case <-timeout:
+			atomic.AddUint64(&g.timeouts, 1)
+			g.fail(fmt.Sprintf("%s: timed out waiting for a concurrency slot", hook))
+			return false
+		}
+	}
+
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			recovered := recover()
+			if g.semaphore != nil {
+				<-g.semaphore
+			}
+			done <- recovered
+		}()
+		fn()
+	}()
+
+	select {
+	case recovered := <-done:
+		if recovered != nil {
+			atomic.AddUint64(&g.panics, 1)
+			g.fail(fmt.Sprintf("%s: panic: %v", hook, recovered))
+			return false
+		}
+
+		// the failures are not reset by a call which reported an error through Fail
+		if atomic.LoadUint64(&g.errors) == errors {
+			atomic.StoreInt32(&g.consecutiveFailures, 0)
+		}
+		return true
+	case <-timeout:
+		atomic.AddUint64(&g.timeouts, 1)
+		g.fail(fmt.Sprintf("%s: timed out after %s", hook, g.options.Timeout))
+		return false
+	}
+}
+
+// returns hooks running the given hooks through the guard, a failing
+// hook behaving as if it did nothing, except BeforeRequest skipping
+// the request unless the guard fails open
+func (g *PluginGuard) WrapDomainHooks(hooks crawler.DomainHooks) crawler.DomainHooks {
+	var result crawler.DomainHooks
+
+	if hooks.BeforeRequest != nil {
+		result.BeforeRequest = func(request *http.Request, url crawler.PageRequest) bool {
+			// the hook works on a copy to avoid races with a timed out call
+			requestCopy := request.Clone(request.Context())
+			var keep bool
+			if !g.run("BeforeRequest", func() { keep = hooks.BeforeRequest(requestCopy, url) }) {
+				return g.options.FailOpen
+			}
+			*request = *requestCopy
+			return keep
+		}
+	}
+
+	if hooks.OnPageResultAdded != nil {
+		result.OnPageResultAdded = func(body []byte, pageResult crawler.PageResult, domainResults crawler.DomainResultEntry) crawler.Attachements {
+			var attachements crawler.Attachements
+			if !g.run("OnPageResultAdded", func() { attachements = hooks.OnPageResultAdded(body, pageResult, domainResults) }) {
+				return crawler.NewAttachements()
+			}
+			return attachements
+		}
+	}
+
+	if hooks.OnUrlsFound != nil {
+		result.OnUrlsFound = func(pageResult crawler.PageResult, foundUrls []crawler.PageRequest) []crawler.PageRequest {
+			urlsCopy := append([]crawler.PageRequest(nil), foundUrls...)
+			var urls []crawler.PageRequest
+			if !g.run("OnUrlsFound", func() { urls = hooks.OnUrlsFound(pageResult, urlsCopy) }) {
+				return foundUrls
+			}
+			return urls
+		}
+	}
+
+	if hooks.OnError != nil {
+		result.OnError = func(url crawler.PageRequest, err error) {
+			g.run("OnError", func() { hooks.OnError(url, err) })
+		}
+	}
+
+	if hooks.AnalyzePage != nil {
+		result.AnalyzePage = func(body []byte, pageResult crawler.PageResult) []crawler.Finding {
+			var findings []crawler.Finding
+			if !g.run("AnalyzePage", func() { findings = hooks.AnalyzePage(body, pageResult) }) {
+				return nil
+			}
+			return findings
+		}
+	}
+
+	return result
+}
+
+// returns hooks running the given hooks through the guard
+func (g *PluginGuard) WrapCrawlHooks(hooks crawler.CrawlHooks) crawler.CrawlHooks {
+	var result crawler.CrawlHooks
+
+	if hooks.OnStart != nil {
+		result.OnStart = func(urlsToFetch []crawler.PageRequest) {
+			urlsCopy := append([]crawler.PageRequest(nil), urlsToFetch...)
+			g.run("OnStart", func() { hooks.OnStart(urlsCopy) })
+		}
+	}
+
+	if hooks.OnFinish != nil {
+		result.OnFinish = func(data crawler.CrawlerData, done bool) {
+			g.run("OnFinish", func() { hooks.OnFinish(data, done) })
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,159 @@
+package plugin
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func TestGuardBeforeRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		hook     func(request *http.Request, url crawler.PageRequest) bool
+		failOpen bool
+		sent     bool
+		header   string
+	}{
+		{"kept", func(request *http.Request, url crawler.PageRequest) bool {
+			request.Header.Set("X-Test", "1")
+			return true
+		}, false, true, "1"},
+		{"skipped", func(request *http.Request, url crawler.PageRequest) bool { return false }, false, false, ""},
+		{"panic", func(request *http.Request, url crawler.PageRequest) bool { panic("hook") }, false, false, ""},
+		{"panic fail open", func(request *http.Request, url crawler.PageRequest) bool { panic("hook") }, true, true, ""},
+		{"timeout", func(request *http.Request, url crawler.PageRequest) bool {
+			request.Header.Set("X-Test", "1")
+			time.Sleep(200 * time.Millisecond)
+			return true
+		}, false, false, ""},
+		{"timeout fail open", func(request *http.Request, url crawler.PageRequest) bool {
+			request.Header.Set("X-Test", "1")
+			time.Sleep(200 * time.Millisecond)
+			return true
+		}, true, true, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			guard := NewPluginGuard("test", &GuardOptions{Timeout: 50 * time.Millisecond, FailOpen: test.failOpen})
+			hooks := guard.WrapDomainHooks(crawler.DomainHooks{BeforeRequest: test.hook})
+
+			request, _ := http.NewRequest("GET", "https://example.com/", nil)
+			if sent := hooks.BeforeRequest(request, crawler.PageRequestFromUrl("https://example.com/")); sent != test.sent {
+				t.Errorf("expected sent %v, got %v", test.sent, sent)
+			}
+			if header := request.Header.Get("X-Test"); header != test.header {
+				t.Errorf("expected header %q, got %q", test.header, header)
+			}
+		})
+	}
+}
+
+func TestGuardDisabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		failOpen bool
+	}{
+		{"fail closed", false},
+		{"fail open", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			guard := NewPluginGuard("test", &GuardOptions{MaxFailures: 2, FailOpen: test.failOpen})
+			calls := 0
+			hooks := guard.WrapDomainHooks(crawler.DomainHooks{BeforeRequest: func(request *http.Request, url crawler.PageRequest) bool {
+				calls++
+				panic("hook")
+			}})
+
+			for i := 0; i < 4; i++ {
+				request, _ := http.NewRequest("GET", "https://example.com/", nil)
+				if sent := hooks.BeforeRequest(request, crawler.PageRequestFromUrl("https://example.com/")); sent != test.failOpen {
+					t.Errorf("call %d: expected sent %v, got %v", i, test.failOpen, sent)
+				}
+			}
+
+			if calls != 2 || !guard.IsDisabled() {
+				t.Errorf("expected the plugin disabled after 2 calls, got %d calls", calls)
+			}
+			if stats := guard.GetStats(); stats.Calls != 2 || stats.Panics != 2 {
+				t.Errorf("unexpected stats %s", stats)
+			}
+		})
+	}
+}
+
+func TestGuardConcurrencySlot(t *testing.T) {
+	guard := NewPluginGuard("test", &GuardOptions{Timeout: 50 * time.Millisecond, MaxConcurrency: 1})
+
+	// the slot of a timed out call is kept until it returns
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	if guard.run("hook", func() {
+		<-release
+		close(returned)
+	}) {
+		t.Fatal("expected a timeout")
+	}
+
+	ran := false
+	if guard.run("hook", func() { ran = true }) || ran {
+		t.Errorf("ran while the slot was used by a timed out call")
+	}
+
+	close(release)
+	<-returned
+	deadline := time.Now().Add(time.Second)
+	for !guard.run("hook", func() { ran = true }) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !ran {
+		t.Errorf("slot not released when the timed out call returned")
+	}
+
+	if stats := guard.GetStats(); stats.Timeouts != 2 {
+		t.Errorf("expected 2 timeouts, got %s", stats)
+	}
+}
+
+func TestGuardFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []bool
+		disabled bool
+	}{
+		{"consecutive errors", []bool{true, true, true}, true},
+		{"reset by a success", []bool{true, true, false, true, true}, false},
+		{"successes", []bool{false, false, false}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			guard := NewPluginGuard("test", &GuardOptions{MaxFailures: 3})
+			p := &CrawlerPlugin{}
+			p.SetErrorHandler(guard.Fail)
+
+			errors := 0
+			for _, failure := range test.failures {
+				// a hook reporting its error instead of returning it
+				if !guard.run("OnPageResultAdded", func() {
+					if failure {
+						p.hookFailed("OnPageResultAdded", http.ErrAbortHandler)
+					}
+				}) {
+					t.Fatalf("the call failed")
+				}
+				if failure {
+					errors++
+				}
+			}
+
+			if stats := guard.GetStats(); stats.Errors != uint64(errors) || stats.Disabled != test.disabled {
+				t.Errorf("expected %d errors and disabled %v, got %s", errors, test.disabled, stats)
+			}
+		})
+	}
+}
@@ -1,30 +1,69 @@
 package plugin
 
 import (
-	"errors"
+	"fmt"
 	plg "plugin"
+	"strings"
 )
 
 const CRAWLER_PLUGIN_NAME = "CrawlerPlugin"
 
+// loads the go plugin at path, errors being *LoadError
 func GetCrawlerPlugin(path string) (*CrawlerPlugin, error) {
 
 	p, err := plg.Open(path)
 	if err != nil {
-		return nil, err
+		loadErr := &LoadError{
+			Path:   path,
+			Reason: "could not open plugin",
+			Err:    err,
+		}
+		if strings.Contains(err.Error(), "different version") {
+			loadErr.Reason = "go build mismatch"
+			loadErr.Details = buildMismatchDetails(err)
+		}
+		return nil, loadErr
 	}
 
 	var symbol plg.Symbol
 	symbol, err = p.Lookup(CRAWLER_PLUGIN_NAME)
 	if err != nil {
+		return nil, &LoadError{
+			Path:   path,
+			Reason: fmt.Sprintf("missing symbol %s", CRAWLER_PLUGIN_NAME),
+			Details: []string{
+				fmt.Sprintf("the plugin must export a variable named %s of type plugin.CrawlerPlugin", CRAWLER_PLUGIN_NAME),
+			},
+		}
+	}
+
+	var crPlugin *CrawlerPlugin
+	switch value := symbol.(type) {
+	case *CrawlerPlugin:
+		crPlugin = value
+	case **CrawlerPlugin:
+		crPlugin = *value
+	}
+
+	if crPlugin == nil {
+		return nil, &LoadError{
+			Path:   path,
+			Reason: fmt.Sprintf("symbol %s has type %T", CRAWLER_PLUGIN_NAME, symbol),
+			Details: []string{
+				fmt.Sprintf("expected a variable of type plugin.CrawlerPlugin: var %s = plugin.CrawlerPlugin{...}", CRAWLER_PLUGIN_NAME),
+			},
+		}
+	}
+
+	if err = checkApiVersion(path, crPlugin); err != nil {
 		return nil, err
 	}
 
-	crPlugin, ok := symbol.(*CrawlerPlugin)
-	if ok {
-		return crPlugin, nil
+	if err = compileEntries(path, crPlugin); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("GetCrawlerPlugin: could not cast symbol to CrawlerPlugin")
+
+	return crPlugin, nil
 
 }
 
@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// the ways CrawlerPluginEntry.DomainName is matched against the domain of a page
+type DomainMatchMode int
+
+const (
+	// "" and "*" match all domains, "*.example.com" matches example.com and its subdomains,
+	// any other domain name matches the exact host
+	MATCH_AUTO DomainMatchMode = iota
+
+	// matches the exact host, the port is ignored if the domain name has none
+	MATCH_EXACT
+
+	// matches the domain name and all its subdomains, the leading "*." being optional
+	MATCH_WILDCARD
+
+	// the domain name is a regex matched against the domain of the page
+	MATCH_REGEX
+
+	// matches all domains
+	MATCH_ALL
+)
+
+var domainMatchModeNames = map[string]DomainMatchMode{
+	"auto":     MATCH_AUTO,
+	"exact":    MATCH_EXACT,
+	"wildcard": MATCH_WILDCARD,
+	"regex":    MATCH_REGEX,
+	"all":      MATCH_ALL,
+}
+
+// returns the DomainMatchMode named name, MATCH_AUTO if empty
+func ParseDomainMatchMode(name string) (DomainMatchMode, error) {
+	if len(name) == 0 {
+		return MATCH_AUTO, nil
+	}
+
+	mode, ok := domainMatchModeNames[strings.ToLower(name)]
+	if !ok {
+		return MATCH_AUTO, fmt.Errorf("unknown domain match mode %s", name)
+	}
+	return mode, nil
+}
+
+func removePort(domainName string) string {
+	if index := strings.LastIndex(domainName, ":"); index >= 0 {
+		return domainName[:index]
+	}
+	return domainName
+}
+
+func matchesHost(domainName string, host string) bool {
+	if !strings.Contains(host, ":") {
+		domainName = removePort(domainName)
+	}
+	return strings.EqualFold(domainName, host)
+}
+
+func matchesSubdomain(domainName string, host string) bool {
+	host = strings.TrimPrefix(host, "*.")
+	if !strings.Contains(host, ":") {
+		domainName = removePort(domainName)
+	}
+
+	domainName = strings.ToLower(domainName)
+	host = strings.ToLower(host)
+	return domainName == host || strings.HasSuffix(domainName, "."+host)
+}
+
+// returns true if the entry should be called for the pages of domainName
+func (entry *CrawlerPluginEntry) MatchesDomain(domainName string) bool {
+	switch entry.MatchMode {
+	case MATCH_ALL:
+		return true
+	case MATCH_EXACT:
+		return matchesHost(domainName, entry.DomainName)
+	case MATCH_WILDCARD:
+		return matchesSubdomain(domainName, entry.DomainName)
+	case MATCH_REGEX:
+		return entry.domainPattern != nil && entry.domainPattern.MatchString(domainName)
+	}
+
+	switch {
+	case len(entry.DomainName) == 0 || entry.DomainName == "*":
+		return true
+	case strings.HasPrefix(entry.DomainName, "*."):
+		return matchesSubdomain(domainName, entry.DomainName)
+	}
+
+	return matchesHost(domainName, entry.DomainName)
+}
+
+// compiles the domain names of the regex entries of p, returns a *LoadError
+// if one of them is invalid
+func compileEntries(path string, p *CrawlerPlugin) error {
+	for i, entry := range p.Entries {
+		if entry.MatchMode != MATCH_REGEX {
+			continue
+		}
+
+		pattern, err := regexp.Compile(entry.DomainName)
+		if err != nil {
+			return &LoadError{
+				Path:   path,
+				Reason: fmt.Sprintf("invalid domain name regex of entry %d", i),
+				Err:    err,
+			}
+		}
+		entry.domainPattern = pattern
+	}
+
+	return nil
+}
+
+// returns true if the entry should be called for url
+func (entry *CrawlerPluginEntry) MatchesUrl(url crawler.PageRequest) bool {
+	if !entry.MatchesDomain(crawler.ExtractDomainName(url.BaseUrl)) {
+		return false
+	}
+
+	return len(entry.PathPrefix) == 0 || strings.HasPrefix(url.GetPath(), entry.PathPrefix)
+}
+
+// returns true if the entry should be called for the page
+func (entry *CrawlerPluginEntry) MatchesPage(pageResult crawler.PageResult) bool {
+	if !entry.MatchesUrl(pageResult.Url) {
+		return false
+	}
+
+	if len(entry.ContentTypes) == 0 {
+		return true
+	}
+
+	contentType := pageResult.ContentType()
+	for _, prefix := range entry.ContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
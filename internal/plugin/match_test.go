@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func TestParseDomainMatchMode(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  DomainMatchMode
+		valid bool
+	}{
+		{"", MATCH_AUTO, true},
+		{"auto", MATCH_AUTO, true},
+		{"Exact", MATCH_EXACT, true},
+		{"WILDCARD", MATCH_WILDCARD, true},
+		{"regex", MATCH_REGEX, true},
+		{"all", MATCH_ALL, true},
+		{"glob", MATCH_AUTO, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mode, err := ParseDomainMatchMode(test.name)
+			if (err == nil) != test.valid || mode != test.mode {
+				t.Errorf("expected %d (valid %v), got %d: %v", test.mode, test.valid, mode, err)
+			}
+		})
+	}
+}
+
+func TestMatchesDomain(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       DomainMatchMode
+		entry      string
+		domainName string
+		matches    bool
+	}{
+		{"auto empty", MATCH_AUTO, "", "example.com", true},
+		{"auto star", MATCH_AUTO, "*", "example.com", true},
+		{"auto exact", MATCH_AUTO, "example.com", "example.com", true},
+		{"auto subdomain", MATCH_AUTO, "example.com", "api.example.com", false},
+		{"auto wildcard", MATCH_AUTO, "*.example.com", "api.example.com", true},
+		{"auto wildcard root", MATCH_AUTO, "*.example.com", "example.com", true},
+		{"auto wildcard suffix", MATCH_AUTO, "*.example.com", "badexample.com", false},
+		{"exact case", MATCH_EXACT, "Example.com", "example.COM", true},
+		{"exact port ignored", MATCH_EXACT, "example.com", "example.com:8080", true},
+		{"exact port", MATCH_EXACT, "example.com:8080", "example.com:8080", true},
+		{"exact other port", MATCH_EXACT, "example.com:8080", "example.com:9090", false},
+		{"exact star", MATCH_EXACT, "*", "example.com", false},
+		{"wildcard without star", MATCH_WILDCARD, "example.com", "a.b.example.com", true},
+		{"wildcard other domain", MATCH_WILDCARD, "example.com", "example.org", false},
+		{"regex", MATCH_REGEX, `^(api|www)\.example\.com$`, "api.example.com", true},
+		{"regex no match", MATCH_REGEX, `^(api|www)\.example\.com$`, "cdn.example.com", false},
+		{"all", MATCH_ALL, "example.com", "other.org", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &CrawlerPlugin{Entries: []*CrawlerPluginEntry{{DomainName: test.entry, MatchMode: test.mode}}}
+			if err := compileEntries("test", p); err != nil {
+				t.Fatal(err)
+			}
+
+			if matches := p.Entries[0].MatchesDomain(test.domainName); matches != test.matches {
+				t.Errorf("expected %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestMatchesPage(t *testing.T) {
+	tests := []struct {
+		name         string
+		pathPrefix   string
+		contentTypes []string
+		url          string
+		contentType  string
+		matches      bool
+	}{
+		{"no filter", "", nil, "https://example.com/x", "image/png", true},
+		{"path prefix", "/api", nil, "https://example.com/api/users", "", true},
+		{"other path", "/api", nil, "https://example.com/docs", "", false},
+		{"content type", "", []string{"text/"}, "https://example.com/", "text/html; charset=utf-8", true},
+		{"other content type", "", []string{"text/", "application/json"}, "https://example.com/", "image/png", false},
+		{"other domain", "", nil, "https://other.com/", "text/html", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &CrawlerPluginEntry{DomainName: "example.com", PathPrefix: test.pathPrefix, ContentTypes: test.contentTypes}
+			page := crawler.PageResult{
+				Url:     crawler.PageRequestFromUrl(test.url),
+				Headers: http.Header{"Content-Type": {test.contentType}},
+			}
+
+			if matches := entry.MatchesPage(page); matches != test.matches {
+				t.Errorf("expected %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestCompileEntries(t *testing.T) {
+	p := &CrawlerPlugin{Entries: []*CrawlerPluginEntry{
+		{DomainName: "[", MatchMode: MATCH_EXACT},
+		{DomainName: "(", MatchMode: MATCH_REGEX},
+	}}
+
+	var loadErr *LoadError
+	if err := compileEntries("test.so", p); !errors.As(err, &loadErr) || loadErr.Reason != "invalid domain name regex of entry 1" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
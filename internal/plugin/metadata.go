@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// the oldest version of the plugin api supported by the crawler
+const MIN_API_VERSION = 1
+
+// the description of a plugin displayed by `crawler config check` and `crawler config list -v`
+type PluginMetadata struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Author      string `json:"author"`
+	Description string `json:"description"`
+}
+
+// an error describing why a plugin could not be loaded
+type LoadError struct {
+	Path   string
+	Reason string
+
+	// additional lines helping to fix the error
+	Details []string
+
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	message := fmt.Sprintf("could not load plugin at %s: %s", e.Path, e.Reason)
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// returns an error if the plugin api version of p is not supported by the crawler
+func checkApiVersion(path string, p *CrawlerPlugin) error {
+	version := p.GetApiVersion()
+	if version >= MIN_API_VERSION && version <= API_VERSION {
+		return nil
+	}
+
+	return &LoadError{
+		Path:   path,
+		Reason: fmt.Sprintf("incompatible plugin api version %d", version),
+		Details: []string{
+			fmt.Sprintf("the crawler supports plugin api versions %d to %d", MIN_API_VERSION, API_VERSION),
+			"upgrade the crawler or rebuild the plugin against a matching version of github.com/m1dugh/crawler",
+		},
+	}
+}
+
+var differentPackagePattern = regexp.MustCompile(`different version of package ([^\s:"]+)`)
+
+// returns the lines explaining a go build mismatch between the crawler and a plugin
+func buildMismatchDetails(err error) []string {
+	details := []string{
+		fmt.Sprintf("crawler built with %s for %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH),
+	}
+
+	var pkg string
+	if match := differentPackagePattern.FindStringSubmatch(err.Error()); match != nil {
+		pkg = match[1]
+		details = append(details, fmt.Sprintf("package %s differs between the crawler and the plugin", pkg))
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		details = append(details, fmt.Sprintf("crawler module %s@%s", info.Main.Path, info.Main.Version))
+		for _, dep := range info.Deps {
+			if len(pkg) > 0 && strings.HasPrefix(pkg, dep.Path) {
+				details = append(details, fmt.Sprintf("crawler depends on %s@%s", dep.Path, dep.Version))
+			}
+		}
+	}
+
+	if len(pkg) > 0 && !strings.Contains(pkg, ".") {
+		details = append(details, "the plugin was most likely built with another go toolchain")
+	}
+
+	details = append(details, "go plugins must be built with the same go version, build flags and module versions as the crawler")
+
+	return details
+}
+
+// returns the names of the hooks implemented by the plugin
+func (p *CrawlerPlugin) SupportedHooks() []string {
+	hooks := make([]string, 0)
+	if p.OnInit != nil {
+		hooks = append(hooks, "OnInit")
+	}
+	if p.OnStart != nil {
+		hooks = append(hooks, "OnStart")
+	}
+
+	entryHooks := map[string]bool{}
+	for _, entry := range p.Entries {
+		entryHooks["OnPageResultAdded"] = entryHooks["OnPageResultAdded"] || entry.OnPageResultAdded != nil
+		entryHooks["BeforeRequest"] = entryHooks["BeforeRequest"] || entry.BeforeRequest != nil
+		entryHooks["OnUrlsFound"] = entryHooks["OnUrlsFound"] || entry.OnUrlsFound != nil
+		entryHooks["OnError"] = entryHooks["OnError"] || entry.OnError != nil
+		entryHooks["AnalyzePage"] = entryHooks["AnalyzePage"] || entry.AnalyzePage != nil
+	}
+
+	for _, name := range []string{"BeforeRequest", "OnPageResultAdded", "OnUrlsFound", "OnError", "AnalyzePage"} {
+		if entryHooks[name] {
+			hooks = append(hooks, name)
+		}
+	}
+
+	if p.OnFinish != nil {
+		hooks = append(hooks, "OnFinish")
+	}
+
+	return hooks
+}
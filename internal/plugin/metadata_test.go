@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func TestCheckApiVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		valid   bool
+	}{
+		{"unset", 0, true},
+		{"first", 1, true},
+		{"current", API_VERSION, true},
+		{"newer", API_VERSION + 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkApiVersion("test.so", &CrawlerPlugin{ApiVersion: test.version})
+			if (err == nil) != test.valid {
+				t.Errorf("expected valid %v, got %v", test.valid, err)
+			}
+
+			var loadErr *LoadError
+			if err != nil && (!errors.As(err, &loadErr) || len(loadErr.Details) == 0) {
+				t.Errorf("expected a LoadError with details, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	cause := errors.New("plugin.Open: not found")
+	tests := []struct {
+		name    string
+		err     *LoadError
+		message string
+	}{
+		{"reason", &LoadError{Path: "a.so", Reason: "missing symbol"}, "could not load plugin at a.so: missing symbol"},
+		{"cause", &LoadError{Path: "a.so", Reason: "could not open plugin", Err: cause}, "could not load plugin at a.so: could not open plugin: plugin.Open: not found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if message := test.err.Error(); message != test.message {
+				t.Errorf("expected %q, got %q", test.message, message)
+			}
+			if unwrapped := errors.Unwrap(test.err); unwrapped != test.err.Err {
+				t.Errorf("expected %v, got %v", test.err.Err, unwrapped)
+			}
+		})
+	}
+}
+
+func TestBuildMismatchDetails(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       string
+		details   []string
+		toolchain bool
+	}{
+		{"standard package", `plugin.Open("a"): plugin was built with a different version of package runtime/internal/sys`, []string{"package runtime/internal/sys differs"}, true},
+		{"module package", `plugin.Open("a"): plugin was built with a different version of package github.com/m1dugh/crawler/internal/crawler`, []string{"package github.com/m1dugh/crawler/internal/crawler differs"}, false},
+		{"unknown", `plugin.Open("a"): some other error`, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			details := strings.Join(buildMismatchDetails(errors.New(test.err)), "\n")
+			if !strings.HasPrefix(details, "crawler built with go") {
+				t.Errorf("toolchain missing in %s", details)
+			}
+			for _, detail := range test.details {
+				if !strings.Contains(details, detail) {
+					t.Errorf("%q not found in %s", detail, details)
+				}
+			}
+			if toolchain := strings.Contains(details, "another go toolchain"); toolchain != test.toolchain {
+				t.Errorf("expected toolchain hint %v in %s", test.toolchain, details)
+			}
+		})
+	}
+}
+
+func TestSupportedHooks(t *testing.T) {
+	var onInit OnInit = func(map[string]string) error { return nil }
+	var onFinish crawler.OnFinish = func(crawler.CrawlerData, bool) {}
+	var onPageResultAdded crawler.OnPageResultAdded = func([]byte, crawler.PageResult, crawler.DomainResultEntry) crawler.Attachements { return nil }
+	var beforeRequest crawler.BeforeRequest = func(*http.Request, crawler.PageRequest) bool { return true }
+
+	tests := []struct {
+		name   string
+		plugin *CrawlerPlugin
+		hooks  string
+	}{
+		{"none", &CrawlerPlugin{}, ""},
+		{"crawl hooks", &CrawlerPlugin{OnInit: &onInit, OnFinish: &onFinish}, "OnInit,OnFinish"},
+		{"entry hooks", &CrawlerPlugin{Entries: []*CrawlerPluginEntry{
+			{OnPageResultAdded: &onPageResultAdded},
+			{BeforeRequest: &beforeRequest},
+			{OnPageResultAdded: &onPageResultAdded},
+		}}, "BeforeRequest,OnPageResultAdded"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if hooks := strings.Join(test.plugin.SupportedHooks(), ","); hooks != test.hooks {
+				t.Errorf("expected %q, got %q", test.hooks, hooks)
+			}
+		})
+	}
+}
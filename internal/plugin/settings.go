@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// called once when the plugin is loaded with its validated settings,
+// an error prevents the plugin from being loaded
+type OnInit func(settings map[string]string) error
+
+// the declaration of a setting accepted by a plugin
+type SettingSchema struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// the plugin is not loaded if a required setting is missing
+	Required bool `json:"required"`
+
+	// the value of the setting when not set
+	Default string `json:"default"`
+
+	// if set, a regex the value must match
+	Pattern string `json:"pattern"`
+}
+
+// validates settings against schema and returns the settings with the default values,
+// settings are returned as is if schema is empty
+func ValidateSettings(schema []SettingSchema, settings map[string]string) (map[string]string, error) {
+
+	result := make(map[string]string, len(settings))
+	for key, value := range settings {
+		result[key] = value
+	}
+
+	if len(schema) == 0 {
+		return result, nil
+	}
+
+	declared := make(map[string]bool, len(schema))
+	errors := make([]string, 0)
+	for _, setting := range schema {
+		declared[setting.Name] = true
+
+		value, ok := result[setting.Name]
+		if !ok {
+			if setting.Required {
+				errors = append(errors, fmt.Sprintf("missing required setting %s", setting.Name))
+			} else if len(setting.Default) > 0 {
+				result[setting.Name] = setting.Default
+			}
+			continue
+		}
+
+		if len(setting.Pattern) > 0 {
+			r, err := regexp.Compile(setting.Pattern)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("invalid pattern for setting %s: %s", setting.Name, err))
+			} else if !r.MatchString(value) {
+				errors = append(errors, fmt.Sprintf("setting %s does not match %s", setting.Name, setting.Pattern))
+			}
+		}
+	}
+
+	for key := range result {
+		if !declared[key] {
+			errors = append(errors, fmt.Sprintf("unknown setting %s", key))
+		}
+	}
+
+	if len(errors) > 0 {
+		sort.Strings(errors)
+		return nil, fmt.Errorf("invalid settings: %s", strings.Join(errors, ", "))
+	}
+
+	return result, nil
+}
+
+// validates the settings against the schema of the plugin and calls its OnInit hook
+func (p *CrawlerPlugin) Initialize(settings map[string]string) error {
+	validSettings, err := ValidateSettings(p.Settings, settings)
+	if err != nil {
+		return err
+	}
+
+	if p.OnInit != nil {
+		return (*p.OnInit)(validSettings)
+	}
+
+	return nil
+}
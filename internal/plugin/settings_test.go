@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSettings(t *testing.T) {
+	schema := []SettingSchema{
+		{Name: "api_key", Required: true, Pattern: "^[a-z0-9]+$"},
+		{Name: "depth", Default: "2"},
+		{Name: "mode"},
+	}
+
+	tests := []struct {
+		name     string
+		schema   []SettingSchema
+		settings map[string]string
+		result   map[string]string
+		err      string
+	}{
+		{"no schema", nil, map[string]string{"any": "value"}, map[string]string{"any": "value"}, ""},
+		{"defaults", schema, map[string]string{"api_key": "abc"}, map[string]string{"api_key": "abc", "depth": "2"}, ""},
+		{"set", schema, map[string]string{"api_key": "abc", "depth": "5", "mode": "fast"}, map[string]string{"api_key": "abc", "depth": "5", "mode": "fast"}, ""},
+		{"missing required", schema, nil, nil, "invalid settings: missing required setting api_key"},
+		{"pattern", schema, map[string]string{"api_key": "ABC"}, nil, "invalid settings: setting api_key does not match ^[a-z0-9]+$"},
+		{"unknown", schema, map[string]string{"api_key": "abc", "other": "x"}, nil, "invalid settings: unknown setting other"},
+		{"sorted errors", schema, map[string]string{"other": "x"}, nil, "invalid settings: missing required setting api_key, unknown setting other"},
+		{"invalid pattern", []SettingSchema{{Name: "x", Pattern: "("}}, map[string]string{"x": "1"}, nil, "invalid settings: invalid pattern for setting x: error parsing regexp: missing closing ): `(`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ValidateSettings(test.schema, test.settings)
+			if len(test.err) > 0 {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.result) {
+				t.Errorf("expected %v, got %v", test.result, result)
+			}
+		})
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	tests := []struct {
+		name     string
+		onInit   error
+		settings map[string]string
+		called   bool
+		failed   bool
+	}{
+		{"initialized", nil, map[string]string{}, true, false},
+		{"invalid settings", nil, map[string]string{"other": "x"}, false, true},
+		{"init error", errors.New("no api"), map[string]string{}, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received map[string]string
+			var onInit OnInit = func(settings map[string]string) error {
+				received = settings
+				return test.onInit
+			}
+			p := &CrawlerPlugin{
+				Settings: []SettingSchema{{Name: "depth", Default: "2"}},
+				OnInit:   &onInit,
+			}
+
+			if err := p.Initialize(test.settings); (err != nil) != test.failed {
+				t.Errorf("expected failed %v, got %v", test.failed, err)
+			}
+			if called := received != nil; called != test.called {
+				t.Errorf("expected OnInit called %v, got %v", test.called, called)
+			}
+			if test.called && received["depth"] != "2" {
+				t.Errorf("default not applied: %v", received)
+			}
+		})
+	}
+}
@@ -1,19 +1,164 @@
 package plugin
 
-import "github.com/m1dugh/crawler/internal/crawler"
+import (
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// the version of the plugin api implemented by the crawler
+//  1: OnPageResultAdded entries and filters
+//  2: BeforeRequest, OnUrlsFound and OnError entry hooks, OnStart and OnFinish plugin hooks
+//  3: OnInit hook and settings schema
+//  4: AnalyzePage entry hook returning structured findings
+const API_VERSION = 4
 
 type CrawlerPluginEntry struct {
+	// the domain of the pages handled by the entry, see MatchMode
 	DomainName string
 	*crawler.OnPageResultAdded
+
+	// since api version 2
+	*crawler.BeforeRequest
+	*crawler.OnUrlsFound
+	*crawler.OnError
+
+	// since api version 4
+	*crawler.AnalyzePage
+
+	// how DomainName is matched against the domain of the pages
+	MatchMode DomainMatchMode
+
+	// DomainName compiled when the plugin is loaded, for MATCH_REGEX
+	domainPattern *regexp.Regexp
+
+	// if set, only the pages whose path starts with PathPrefix are handled
+	PathPrefix string
+
+	// if set, only the pages whose content type starts with one of ContentTypes are handled
+	ContentTypes []string
+}
+
+// returns the hooks of the entry, nil if not set, the hooks being
+// only called for the pages matching the path prefix and content types of the entry
+func (entry *CrawlerPluginEntry) GetHooks() crawler.DomainHooks {
+	var hooks crawler.DomainHooks
+	if entry.OnPageResultAdded != nil {
+		handler := *entry.OnPageResultAdded
+		hooks.OnPageResultAdded = func(body []byte, pageResult crawler.PageResult, domainResults crawler.DomainResultEntry) crawler.Attachements {
+			if !entry.MatchesPage(pageResult) {
+				return crawler.NewAttachements()
+			}
+			return handler(body, pageResult, domainResults)
+		}
+	}
+	if entry.BeforeRequest != nil {
+		handler := *entry.BeforeRequest
+		hooks.BeforeRequest = func(request *http.Request, url crawler.PageRequest) bool {
+			return !entry.MatchesUrl(url) || handler(request, url)
+		}
+	}
+	if entry.OnUrlsFound != nil {
+		handler := *entry.OnUrlsFound
+		hooks.OnUrlsFound = func(pageResult crawler.PageResult, foundUrls []crawler.PageRequest) []crawler.PageRequest {
+			if !entry.MatchesPage(pageResult) {
+				return foundUrls
+			}
+			return handler(pageResult, foundUrls)
+		}
+	}
+	if entry.OnError != nil {
+		handler := *entry.OnError
+		hooks.OnError = func(url crawler.PageRequest, err error) {
+			if entry.MatchesUrl(url) {
+				handler(url, err)
+			}
+		}
+	}
+	if entry.AnalyzePage != nil {
+		handler := *entry.AnalyzePage
+		hooks.AnalyzePage = func(body []byte, pageResult crawler.PageResult) []crawler.Finding {
+			if !entry.MatchesPage(pageResult) {
+				return nil
+			}
+			return handler(body, pageResult)
+		}
+	}
+	return hooks
 }
 
 type CrawlerPlugin struct {
+	// the version of the plugin api the plugin was written for, 1 if not set
+	ApiVersion int
+
+	// the description of the plugin
+	Metadata PluginMetadata
+
+	// since api version 2
+	*crawler.OnStart
+	*crawler.OnFinish
+
+	// since api version 3
+	*OnInit
+
+	// the settings accepted by the plugin, settings are not validated if empty
+	Settings []SettingSchema
 
 	// PluginEntries for Attachements
 	Entries []*CrawlerPluginEntry
 
 	// a map containing additional should add filters
 	Filters map[string]*crawler.ShouldAddFilter
+
+	// releases the resources of the plugin such as exec plugins processes
+	closer io.Closer
+
+	// receives the errors of the hooks which cannot return them, see SetErrorHandler
+	errorHandler func(hook string, err error)
+}
+
+// returns the crawl hooks of the plugin, nil if not set
+func (p *CrawlerPlugin) GetCrawlHooks() crawler.CrawlHooks {
+	var hooks crawler.CrawlHooks
+	if p.OnStart != nil {
+		hooks.OnStart = *p.OnStart
+	}
+	if p.OnFinish != nil {
+		hooks.OnFinish = *p.OnFinish
+	}
+	return hooks
+}
+
+func (p *CrawlerPlugin) GetApiVersion() int {
+	if p.ApiVersion <= 0 {
+		return 1
+	}
+	return p.ApiVersion
+}
+
+// sets the handler receiving the errors of the hooks of the plugin which cannot return them,
+// such as the failed calls to exec plugins, the hook returning as if it did nothing.
+// The errors are logged if no handler is set, see PluginGuard.Fail
+func (p *CrawlerPlugin) SetErrorHandler(handler func(hook string, err error)) {
+	p.errorHandler = handler
+}
+
+func (p *CrawlerPlugin) hookFailed(hook string, err error) {
+	if p.errorHandler != nil {
+		p.errorHandler(hook, err)
+		return
+	}
+	log.Printf("plugin hook %s failed: %s\n", hook, err)
+}
+
+func (p *CrawlerPlugin) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
 }
 
 type CrawlerPlugins []*CrawlerPlugin
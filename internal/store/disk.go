@@ -0,0 +1,718 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+const (
+	// the urls pushed to the store, one json PageRequest per line
+	FRONTIER_FILE = "frontier.log"
+
+	// the fetched page results, one json PageResult per line
+	RESULTS_FILE = "results.log"
+
+	// the location and summary of every page result, see indexRecord
+	INDEX_FILE = "index.log"
+
+	// the position in the frontier and the urls to fetch again, rewritten by Sync
+	STATE_FILE = "state.json"
+
+	// locked while the store is opened for writing
+	LOCK_FILE = "lock"
+)
+
+var (
+	// returned by the writing methods of a store opened by OpenDiskStoreReadOnly
+	ErrReadOnly = errors.New("store: the store is opened read-only")
+
+	// returned by OpenDiskStore when the store is opened for writing by another process
+	ErrLocked = errors.New("store: the store is used by another process")
+
+	// returned by the handleLine of openLogFile to ignore the rest of the file
+	errEndOfLog = errors.New("end of log")
+)
+
+// the number of bytes of the frontier read at once
+const READ_CHUNK_SIZE = 4096
+
+// a line of the index file, an attachements only record having no Result
+type indexRecord struct {
+	Offset int64 `json:"offset"`
+	Size   int   `json:"size"`
+
+	// the page result without its headers, technologies and findings
+	Result *crawler.PageResult `json:"result,omitempty"`
+
+	BaseUrl      string               `json:"base_url"`
+	Attachements crawler.Attachements `json:"attachements,omitempty"`
+}
+
+type state struct {
+	// the offset in the frontier file of the next url to fetch
+	Cursor int64 `json:"cursor"`
+
+	// the urls popped but not fetched, fetched before the rest of the frontier
+	Requeued []crawler.PageRequest `json:"requeued"`
+}
+
+// the location of a page result in the results file
+type location struct {
+	offset int64
+	size   int
+}
+
+// an appending log file
+type logFile struct {
+	*os.File
+	writer *bufio.Writer
+	size   int64
+}
+
+// a Store keeping the urls to fetch and the page results in append-only files of a directory,
+// only a summary of the page results and the seen set of the pushed urls being kept in memory.
+// Nothing is rewritten but the small state file: the summary is appended to the index and the
+// seen set rebuilt from the frontier, every url being written to it once, when the store is opened.
+// The ShouldAddFilters are run against the summary: the PageResults of FilterData only hold
+// Url, StatusCode, ContentLength and Fingerprint.
+// An url is only pushed once, even after it has been fetched.
+type DiskStore struct {
+	dir string
+
+	frontier *logFile
+	cursor   int64
+	seen     *crawler.SeenSet
+	pushed   int
+	popped   int
+	requeued []crawler.PageRequest
+
+	// the bytes of the frontier read after the cursor
+	readBuffer []byte
+
+	results *logFile
+	index   *logFile
+
+	data      *crawler.CrawlerData
+	locations map[string][]location
+
+	readOnly bool
+	lock     *os.File
+
+	sync.Mutex
+}
+
+// opens the log file, truncating the partial line left by a crash, and calls
+// handleLine for every line with its offset, the file not being read if handleLine is nil.
+// The lines after the one for which handleLine returns errEndOfLog are truncated too.
+// A read-only log file is not truncated, its size ignoring the truncated lines.
+func openLogFile(fileName string, readOnly bool, handleLine func(line []byte, offset int64) error) (*logFile, error) {
+	var file *os.File
+	var err error
+	if readOnly {
+		file, err = os.Open(fileName)
+	} else {
+		file, err = os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if handleLine == nil {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &logFile{
+			File:   file,
+			writer: bufio.NewWriter(file),
+			size:   info.Size(),
+		}, nil
+	}
+
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if err = handleLine(line, size); err == errEndOfLog {
+			break
+		} else if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid line at offset %d of %s: %s", size, fileName, err)
+		}
+		size += int64(len(line))
+	}
+
+	if !readOnly {
+		if err = file.Truncate(size); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &logFile{
+		File:   file,
+		writer: bufio.NewWriter(file),
+		size:   size,
+	}, nil
+}
+
+// appends value as a json line, returns its offset and size
+func (l *logFile) append(value interface{}) (int64, int, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return 0, 0, err
+	}
+	body = append(body, '\n')
+
+	offset := l.size
+	if _, err = l.writer.Write(body); err != nil {
+		return 0, 0, err
+	}
+	l.size += int64(len(body))
+
+	return offset, len(body), nil
+}
+
+func (l *logFile) flush() error {
+	if l.writer.Buffered() == 0 {
+		return nil
+	}
+	return l.writer.Flush()
+}
+
+func (l *logFile) sync() error {
+	if err := l.flush(); err != nil {
+		return err
+	}
+	return l.Sync()
+}
+
+// opens the store of dir, creating it if it does not exist, the keys of the pushed urls
+// being kept in seen (crawler.NewSeenSet() if nil), see crawler.NewBloomSeenSet.
+// The seen set and the summary of the page results are read from the frontier and the index.
+// The store is locked until it is closed, ErrLocked being returned if it is already locked.
+func OpenDiskStore(dir string, seen *crawler.SeenSet) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("store::OpenDiskStore -> could not create %s: %s", dir, err)
+	}
+
+	lock, err := os.OpenFile(filepath.Join(dir, LOCK_FILE), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("store::OpenDiskStore -> could not open %s: %s", LOCK_FILE, err)
+	}
+	if err = lockFile(lock); err != nil {
+		lock.Close()
+		if err == ErrLocked {
+			return nil, fmt.Errorf("store::OpenDiskStore -> %s: %w", dir, err)
+		}
+		return nil, fmt.Errorf("store::OpenDiskStore -> could not lock %s: %s", LOCK_FILE, err)
+	}
+
+	s, err := openDiskStore(dir, seen, false)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	s.lock = lock
+	return s, nil
+}
+
+// opens the existing store of dir without locking nor modifying it, for reading the results of a
+// crawl, even a running one. The writing methods return ErrReadOnly and the partial lines of the
+// files are ignored instead of truncated.
+func OpenDiskStoreReadOnly(dir string) (*DiskStore, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("store::OpenDiskStoreReadOnly -> could not open %s: %s", dir, err)
+	}
+
+	return openDiskStore(dir, nil, true)
+}
+
+func openDiskStore(dir string, seen *crawler.SeenSet, readOnly bool) (*DiskStore, error) {
+	s := &DiskStore{
+		dir:       dir,
+		seen:      seen,
+		data:      crawler.NewCrawlerData(),
+		locations: make(map[string][]location),
+		readOnly:  readOnly,
+	}
+
+	var st state
+	if body, err := os.ReadFile(filepath.Join(dir, STATE_FILE)); err == nil {
+		if err = json.Unmarshal(body, &st); err != nil {
+			return nil, fmt.Errorf("store::OpenDiskStore -> could not unmarshal %s: %s", STATE_FILE, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("store::OpenDiskStore -> could not read %s: %s", STATE_FILE, err)
+	}
+	s.requeued = st.Requeued
+
+	if s.seen == nil {
+		s.seen = crawler.NewSeenSet()
+	}
+	s.data.Seen = s.seen
+	s.data.Patterns = make(crawler.EndpointPatterns)
+
+	var err error
+	s.frontier, err = openLogFile(filepath.Join(dir, FRONTIER_FILE), readOnly, func(line []byte, offset int64) error {
+		var url crawler.PageRequest
+		if err := json.Unmarshal(line, &url); err != nil {
+			return err
+		}
+
+		s.seen.Add(url)
+		s.data.Patterns.Add(url)
+		s.pushed++
+		if offset < st.Cursor {
+			s.popped++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store::OpenDiskStore -> could not open %s: %s", FRONTIER_FILE, err)
+	}
+
+	s.cursor = st.Cursor
+	if s.cursor > s.frontier.size {
+		s.cursor = s.frontier.size
+		s.popped = s.pushed
+	}
+
+	if s.results, err = openLogFile(filepath.Join(dir, RESULTS_FILE), readOnly, nil); err != nil {
+		s.frontier.Close()
+		return nil, fmt.Errorf("store::OpenDiskStore -> could not open %s: %s", RESULTS_FILE, err)
+	}
+
+	// the results written after the last record of the index are dropped, and the index ends
+	// at the first record of a result not written, before a crash or by a running crawl
+	var resultsSize int64
+	s.index, err = openLogFile(filepath.Join(dir, INDEX_FILE), readOnly, func(line []byte, offset int64) error {
+		var record indexRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+
+		if record.Result != nil {
+			end := record.Offset + int64(record.Size)
+			if end > s.results.size {
+				return errEndOfLog
+			}
+			if end > resultsSize {
+				resultsSize = end
+			}
+		}
+
+		s.apply(record)
+		return nil
+	})
+	if err != nil {
+		s.frontier.Close()
+		s.results.Close()
+		return nil, fmt.Errorf("store::OpenDiskStore -> could not open %s: %s", INDEX_FILE, err)
+	}
+
+	if resultsSize < s.results.size && !readOnly {
+		if err = s.results.Truncate(resultsSize); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("store::OpenDiskStore -> could not truncate %s: %s", RESULTS_FILE, err)
+		}
+		s.results.size = resultsSize
+	}
+
+	return s, nil
+}
+
+// adds an index record to the summary kept in memory
+func (s *DiskStore) apply(record indexRecord) {
+	domainName := crawler.ExtractDomainName(record.BaseUrl)
+	domainResults, ok := s.data.FetchedUrls[domainName]
+	if !ok {
+		domainResults = crawler.NewDomainResults()
+		s.data.FetchedUrls[domainName] = domainResults
+	}
+
+	entry, ok := domainResults[record.BaseUrl]
+	if !ok {
+		entry = crawler.NewDomainResultEntry()
+		domainResults[record.BaseUrl] = entry
+	}
+
+	// the results and their locations are kept in the same order
+	if record.Result != nil {
+		entry.PageResults = append(entry.PageResults, *record.Result)
+		s.locations[record.BaseUrl] = append(s.locations[record.BaseUrl], location{record.Offset, record.Size})
+	}
+
+	entry.Attachements.AddAll(record.Attachements)
+}
+
+func (s *DiskStore) PushUrl(url crawler.PageRequest) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	if s.seen.Contains(url) {
+		return false, nil
+	}
+
+	if _, _, err := s.frontier.append(url); err != nil {
+		return false, fmt.Errorf("store::DiskStore.PushUrl -> could not write %s: %s", FRONTIER_FILE, err)
+	}
+
+	s.seen.Add(url)
+	s.data.Patterns.Add(url)
+	s.pushed++
+	return true, nil
+}
+
+func (s *DiskStore) IsSeen(url crawler.PageRequest) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.seen.Contains(url)
+}
+
+func (s *DiskStore) Requeue(urls []crawler.PageRequest) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.requeued = append(s.requeued, urls...)
+	return nil
+}
+
+func (s *DiskStore) PopUrl() (crawler.PageRequest, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.requeued) > 0 {
+		url := s.requeued[len(s.requeued)-1]
+		s.requeued = s.requeued[:len(s.requeued)-1]
+		return url, true, nil
+	}
+
+	if s.popped >= s.pushed {
+		return crawler.PageRequest{}, false, nil
+	}
+
+	if err := s.frontier.flush(); err != nil {
+		return crawler.PageRequest{}, false, fmt.Errorf("store::DiskStore.PopUrl -> could not write %s: %s", FRONTIER_FILE, err)
+	}
+
+	line, err := s.readFrontierLine()
+	if err != nil {
+		return crawler.PageRequest{}, false, fmt.Errorf("store::DiskStore.PopUrl -> could not read %s: %s", FRONTIER_FILE, err)
+	}
+
+	var url crawler.PageRequest
+	if err = json.Unmarshal(line, &url); err != nil {
+		return crawler.PageRequest{}, false, fmt.Errorf("store::DiskStore.PopUrl -> invalid line at offset %d of %s: %s", s.cursor, FRONTIER_FILE, err)
+	}
+
+	s.cursor += int64(len(line))
+	s.popped++
+	return url, true, nil
+}
+
+// returns the line of the frontier starting at the cursor
+func (s *DiskStore) readFrontierLine() ([]byte, error) {
+	for {
+		if i := bytes.IndexByte(s.readBuffer, '\n'); i >= 0 {
+			line := s.readBuffer[:i+1]
+			s.readBuffer = s.readBuffer[i+1:]
+			return line, nil
+		}
+
+		chunk := make([]byte, READ_CHUNK_SIZE)
+		n, err := s.frontier.ReadAt(chunk, s.cursor+int64(len(s.readBuffer)))
+		if n == 0 {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		s.readBuffer = append(s.readBuffer, chunk[:n]...)
+	}
+}
+
+func (s *DiskStore) Size() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.requeued) + s.pushed - s.popped
+}
+
+// returns true if the result of url has already been stored
+func (s *DiskStore) isFetched(url crawler.PageRequest) bool {
+	entry, ok := s.data.FetchedUrls[crawler.ExtractDomainName(url.BaseUrl)][url.BaseUrl]
+	if !ok {
+		return false
+	}
+
+	key := url.Key()
+	for _, pageResult := range entry.PageResults {
+		if pageResult.Url.Key() == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DiskStore) AddPageResult(result crawler.PageResult, attachements crawler.Attachements) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	record := indexRecord{
+		BaseUrl:      result.Url.BaseUrl,
+		Attachements: attachements,
+	}
+
+	if !s.isFetched(result.Url) {
+		offset, size, err := s.results.append(result)
+		if err != nil {
+			return fmt.Errorf("store::DiskStore.AddPageResult -> could not write %s: %s", RESULTS_FILE, err)
+		}
+
+		record.Offset = offset
+		record.Size = size
+		record.Result = &crawler.PageResult{
+			Url:           result.Url,
+			StatusCode:    result.StatusCode,
+			ContentLength: result.ContentLength,
+			Fingerprint:   result.Fingerprint,
+		}
+	} else if len(attachements) == 0 {
+		return nil
+	}
+
+	if _, _, err := s.index.append(record); err != nil {
+		return fmt.Errorf("store::DiskStore.AddPageResult -> could not write %s: %s", INDEX_FILE, err)
+	}
+
+	s.apply(record)
+	return nil
+}
+
+// reads the page result stored at loc
+func (s *DiskStore) readResult(loc location) (crawler.PageResult, error) {
+	var result crawler.PageResult
+
+	if err := s.results.flush(); err != nil {
+		return result, err
+	}
+
+	body := make([]byte, loc.size)
+	if _, err := s.results.ReadAt(body, loc.offset); err != nil {
+		return result, err
+	}
+
+	err := json.Unmarshal(body, &result)
+	return result, err
+}
+
+func (s *DiskStore) getEntry(domainName string, baseUrl string) (crawler.DomainResultEntry, bool, error) {
+	summary, ok := s.data.FetchedUrls[domainName][baseUrl]
+	if !ok {
+		return crawler.DomainResultEntry{}, false, nil
+	}
+
+	entry := summary.Copy()
+	for i, loc := range s.locations[baseUrl] {
+		result, err := s.readResult(loc)
+		if err != nil {
+			return crawler.DomainResultEntry{}, false, fmt.Errorf("store::DiskStore.GetEntry -> could not read %s: %s", RESULTS_FILE, err)
+		}
+		entry.PageResults[i] = result
+	}
+
+	return entry, true, nil
+}
+
+func (s *DiskStore) GetEntry(domainName string, baseUrl string) (crawler.DomainResultEntry, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.getEntry(domainName, baseUrl)
+}
+
+func (s *DiskStore) FilterData() *crawler.CrawlerData {
+	return s.data
+}
+
+// returns the requeued urls and the urls of the frontier after the cursor
+func (s *DiskStore) getUrlsToFetch() ([]crawler.PageRequest, error) {
+	urlsToFetch := append(make([]crawler.PageRequest, 0, len(s.requeued)+s.pushed-s.popped), s.requeued...)
+
+	if err := s.frontier.flush(); err != nil {
+		return nil, fmt.Errorf("could not write %s: %s", FRONTIER_FILE, err)
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(s.frontier, s.cursor, s.frontier.size-s.cursor))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read %s: %s", FRONTIER_FILE, err)
+		}
+
+		var url crawler.PageRequest
+		if err = json.Unmarshal(line, &url); err != nil {
+			return nil, fmt.Errorf("invalid line of %s: %s", FRONTIER_FILE, err)
+		}
+		urlsToFetch = append(urlsToFetch, url)
+	}
+
+	return urlsToFetch, nil
+}
+
+func (s *DiskStore) GetUrlsToFetch() ([]crawler.PageRequest, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	urlsToFetch, err := s.getUrlsToFetch()
+	if err != nil {
+		return nil, fmt.Errorf("store::DiskStore.GetUrlsToFetch -> %s", err)
+	}
+	return urlsToFetch, nil
+}
+
+// reads the entries one at a time, the store being only locked while an entry is read
+func (s *DiskStore) ForEachEntry(handle func(domainName string, baseUrl string, entry crawler.DomainResultEntry) error) error {
+	s.Lock()
+	keys := make(map[string][]string, len(s.data.FetchedUrls))
+	for domainName, domainResults := range s.data.FetchedUrls {
+		for baseUrl := range domainResults {
+			keys[domainName] = append(keys[domainName], baseUrl)
+		}
+	}
+	s.Unlock()
+
+	for domainName, baseUrls := range keys {
+		for _, baseUrl := range baseUrls {
+			entry, ok, err := s.GetEntry(domainName, baseUrl)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			if err = handle(domainName, baseUrl, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *DiskStore) Snapshot() (crawler.CrawlerData, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	data := crawler.CrawlerData{
+		FetchedUrls: make(crawler.FetchedUrls, len(s.data.FetchedUrls)),
+	}
+
+	var err error
+	if data.UrlsToFetch, err = s.getUrlsToFetch(); err != nil {
+		return data, fmt.Errorf("store::DiskStore.Snapshot -> %s", err)
+	}
+
+	for domainName, domainResults := range s.data.FetchedUrls {
+		data.FetchedUrls[domainName] = make(crawler.DomainResults, len(domainResults))
+		for baseUrl := range domainResults {
+			entry, _, err := s.getEntry(domainName, baseUrl)
+			if err != nil {
+				return data, err
+			}
+			data.FetchedUrls[domainName][baseUrl] = &entry
+		}
+	}
+
+	return data, nil
+}
+
+// writes the files to disk and the state of the frontier, pending urls being fetched again when the store is reopened
+func (s *DiskStore) Sync(pending []crawler.PageRequest) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	return s.sync(pending)
+}
+
+func (s *DiskStore) sync(pending []crawler.PageRequest) error {
+	for _, file := range []*logFile{s.frontier, s.results, s.index} {
+		if err := file.sync(); err != nil {
+			return fmt.Errorf("store::DiskStore.Sync -> could not write %s: %s", file.Name(), err)
+		}
+	}
+
+	st := state{
+		Cursor:   s.cursor,
+		Requeued: append(append(make([]crawler.PageRequest, 0, len(s.requeued)+len(pending)), s.requeued...), pending...),
+	}
+
+	err := crawler.WriteFileAtomic(filepath.Join(s.dir, STATE_FILE), func(file *bufio.Writer) error {
+		return json.NewEncoder(file).Encode(st)
+	})
+	if err != nil {
+		return fmt.Errorf("store::DiskStore.Sync -> could not write %s: %s", STATE_FILE, err)
+	}
+
+	return nil
+}
+
+// syncs and closes the files of the store, releasing its lock
+func (s *DiskStore) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	var err error
+	if !s.readOnly {
+		err = s.sync(nil)
+	}
+	for _, file := range []*logFile{s.frontier, s.results, s.index} {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if s.lock != nil {
+		if closeErr := s.lock.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,332 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func testUrl(i int) crawler.PageRequest {
+	return crawler.PageRequestFromUrl(fmt.Sprintf("https://example.com/page%d?id=%d", i%10, i))
+}
+
+// pushes urls urls, pops popped of them and adds the results of the popped urls
+func fillStore(t *testing.T, s *DiskStore, urls int, popped int) {
+	for i := 0; i < urls; i++ {
+		if added, err := s.PushUrl(testUrl(i)); err != nil || !added {
+			t.Fatalf("could not push url %d: %v %v", i, added, err)
+		}
+	}
+
+	for i := 0; i < popped; i++ {
+		url, ok, err := s.PopUrl()
+		if err != nil || !ok {
+			t.Fatalf("could not pop url %d: %v %v", i, ok, err)
+		}
+
+		result := crawler.PageResult{Url: url, StatusCode: 200, ContentLength: int64(i), Headers: map[string][]string{"Server": {"test"}}}
+		if err = s.AddPageResult(result, crawler.Attachements{"index": fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checks the size, the seen set and the stored results of a store filled by fillStore
+func checkStore(t *testing.T, s *DiskStore, urls int, popped int) {
+	if size := s.Size(); size != urls-popped {
+		t.Errorf("expected %d urls to fetch, got %d", urls-popped, size)
+	}
+
+	for i := 0; i < urls; i++ {
+		if !s.IsSeen(testUrl(i)) {
+			t.Errorf("url %d not seen", i)
+		}
+	}
+
+	data, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := 0
+	for _, domainResults := range data.FetchedUrls {
+		for _, entry := range domainResults {
+			results += len(entry.PageResults)
+			for _, result := range entry.PageResults {
+				if result.Headers.Get("Server") != "test" {
+					t.Errorf("headers of %s not read from %s", result.Url.ToUrl(), RESULTS_FILE)
+				}
+			}
+		}
+	}
+	if results != popped {
+		t.Errorf("expected %d results, got %d", popped, results)
+	}
+	if len(data.UrlsToFetch) != urls-popped {
+		t.Errorf("expected %d urls in the snapshot, got %d", urls-popped, len(data.UrlsToFetch))
+	}
+
+	entries := 0
+	err = s.ForEachEntry(func(domainName string, baseUrl string, entry crawler.DomainResultEntry) error {
+		if len(entry.PageResults) != len(data.FetchedUrls[domainName][baseUrl].PageResults) {
+			t.Errorf("unexpected results of %s: %v", baseUrl, entry.PageResults)
+		}
+		entries++
+		return nil
+	})
+	if err != nil || entries != len(data.FetchedUrls["example.com"]) {
+		t.Errorf("expected %d entries, got %d: %v", len(data.FetchedUrls["example.com"]), entries, err)
+	}
+
+	if urlsToFetch, err := s.GetUrlsToFetch(); err != nil || len(urlsToFetch) != urls-popped {
+		t.Errorf("expected %d urls to fetch, got %d: %v", urls-popped, len(urlsToFetch), err)
+	}
+
+	entry, ok, err := s.GetEntry("example.com", "https://example.com/page0")
+	if popped > 0 && (err != nil || !ok || len(entry.PageResults) == 0 || entry.Attachements["index"] == "") {
+		t.Errorf("unexpected entry of page0: %v %v %v", entry, ok, err)
+	}
+}
+
+// closes the files of the store without syncing them
+func crash(s *DiskStore) {
+	for _, file := range []*logFile{s.frontier, s.results, s.index} {
+		file.Close()
+	}
+	s.lock.Close()
+}
+
+func TestDiskStoreReopen(t *testing.T) {
+	tests := []struct {
+		name   string
+		seen   func() *crawler.SeenSet
+		urls   int
+		popped int
+	}{
+		{"empty", nil, 0, 0},
+		{"exact", nil, 50, 20},
+		{"all fetched", nil, 30, 30},
+		{"bloom", func() *crawler.SeenSet { return crawler.NewBloomSeenSet(10, 0.001) }, 50, 20},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			newSeen := func() *crawler.SeenSet {
+				if test.seen == nil {
+					return nil
+				}
+				return test.seen()
+			}
+
+			s, err := OpenDiskStore(dir, newSeen())
+			if err != nil {
+				t.Fatal(err)
+			}
+			fillStore(t, s, test.urls, test.popped)
+			checkStore(t, s, test.urls, test.popped)
+			if err = s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if s, err = OpenDiskStore(dir, newSeen()); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			checkStore(t, s, test.urls, test.popped)
+
+			if added, err := s.PushUrl(testUrl(0)); test.urls > 0 && (err != nil || added) {
+				t.Errorf("seen url pushed again: %v %v", added, err)
+			}
+		})
+	}
+}
+
+func TestDiskStoreAppendOnly(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenDiskStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the files written by the previous syncs are only appended to
+	files := make(map[string][]byte)
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 10; j++ {
+			s.PushUrl(testUrl(i*10 + j))
+		}
+		for j := 0; j < 5; j++ {
+			url, _, _ := s.PopUrl()
+			s.AddPageResult(crawler.PageResult{Url: url, StatusCode: 200}, crawler.Attachements{"index": fmt.Sprint(j)})
+		}
+		if err = s.Sync(nil); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, fileName := range []string{FRONTIER_FILE, RESULTS_FILE, INDEX_FILE} {
+			body, err := os.ReadFile(filepath.Join(dir, fileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if old := files[fileName]; len(body) <= len(old) || string(body[:len(old)]) != string(old) {
+				t.Errorf("sync %d: %s rewritten", i, fileName)
+			}
+			files[fileName] = body
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("unexpected files %v", entries)
+	}
+}
+
+func TestDiskStoreRequeue(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenDiskStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fillStore(t, s, 10, 0)
+	pending := make([]crawler.PageRequest, 0)
+	for i := 0; i < 4; i++ {
+		url, _, _ := s.PopUrl()
+		pending = append(pending, url)
+	}
+	if err = s.Requeue(pending[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// the urls being fetched are fetched again when the store is reopened after a crash
+	if err = s.Sync(pending[1:]); err != nil {
+		t.Fatal(err)
+	}
+	crash(s)
+
+	if s, err = OpenDiskStore(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if size := s.Size(); size != 10 {
+		t.Errorf("expected 10 urls to fetch, got %d", size)
+	}
+}
+
+func TestDiskStorePartialLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+	}{
+		{"truncated", false},
+		{"ignored read-only", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := OpenDiskStore(dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fillStore(t, s, 10, 5)
+			s.Close()
+
+			// a crash while writing, the results after the last index record being dropped
+			for _, fileName := range []string{FRONTIER_FILE, RESULTS_FILE, INDEX_FILE} {
+				file, err := os.OpenFile(filepath.Join(dir, fileName), os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				file.WriteString(`{"partial":`)
+				file.Close()
+			}
+			info, _ := os.Stat(filepath.Join(dir, FRONTIER_FILE))
+
+			if test.readOnly {
+				s, err = OpenDiskStoreReadOnly(dir)
+			} else {
+				s, err = OpenDiskStore(dir, nil)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkStore(t, s, 10, 5)
+			s.Close()
+
+			after, _ := os.Stat(filepath.Join(dir, FRONTIER_FILE))
+			if truncated := after.Size() < info.Size(); truncated == test.readOnly {
+				t.Errorf("expected truncated %v, got %v", !test.readOnly, truncated)
+			}
+		})
+	}
+}
+
+func TestDiskStoreReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenDiskStore(dir, crawler.NewBloomSeenSet(10, 0.01))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fillStore(t, s, 10, 4)
+	if err = s.Sync(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// read while the store is locked, the seen urls of the bloom store being read into an exact set
+	readOnly, err := OpenDiskStoreReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStore(t, readOnly, 10, 4)
+
+	if _, err = readOnly.PushUrl(testUrl(100)); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("PushUrl: expected ErrReadOnly, got %v", err)
+	}
+	if err = readOnly.AddPageResult(crawler.PageResult{Url: testUrl(100)}, nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddPageResult: expected ErrReadOnly, got %v", err)
+	}
+	if err = readOnly.Sync(nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Sync: expected ErrReadOnly, got %v", err)
+	}
+
+	state, _ := os.ReadFile(filepath.Join(dir, STATE_FILE))
+	if err = readOnly.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(filepath.Join(dir, STATE_FILE)); string(after) != string(state) {
+		t.Errorf("%s rewritten by a read-only store", STATE_FILE)
+	}
+
+	if _, err = OpenDiskStoreReadOnly(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("opened a missing store read-only")
+	}
+}
+
+func TestDiskStoreLock(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenDiskStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = OpenDiskStore(dir, nil); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+
+	s.Close()
+	if s, err = OpenDiskStore(dir, nil); err != nil {
+		t.Fatalf("lock not released: %s", err)
+	}
+	s.Close()
+}
@@ -0,0 +1,8 @@
+package store
+
+import "os"
+
+// the stores are not locked on windows
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// a record read from a WARC file
+type Record struct {
+	Version string
+	Header  textproto.MIMEHeader
+	Block   []byte
+}
+
+func (r *Record) Type() string {
+	return r.Header.Get("WARC-Type")
+}
+
+func (r *Record) TargetUri() string {
+	return r.Header.Get("WARC-Target-URI")
+}
+
+// returns the body of the http response of a response record, the block
+// of other records
+func (r *Record) Payload() ([]byte, error) {
+	if r.Type() != RECORD_RESPONSE || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/http") {
+		return r.Block, nil
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(r.Block)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("warc::Record.Payload -> %s", err)
+	}
+	defer res.Body.Close()
+
+	payload, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("warc::Record.Payload -> %s", err)
+	}
+	return payload, nil
+}
+
+// reads the records of a WARC file, gzip compressed or not
+type Reader struct {
+	reader *textproto.Reader
+}
+
+func NewReader(reader io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(reader)
+
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		// the gzip members of the records being read as a single stream
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("warc::NewReader -> %s", err)
+		}
+		buffered = bufio.NewReader(gzipReader)
+	}
+
+	return &Reader{
+		reader: textproto.NewReader(buffered),
+	}, nil
+}
+
+// returns the next record, io.EOF if there is none
+func (r *Reader) ReadRecord() (*Record, error) {
+	version, err := r.reader.ReadLine()
+	for err == nil && len(version) == 0 {
+		version, err = r.reader.ReadLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(version, "WARC/") {
+		return nil, fmt.Errorf("warc::Reader.ReadRecord -> invalid version line: %q", version)
+	}
+
+	header, err := r.reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("warc::Reader.ReadRecord -> %s", err)
+	}
+
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("warc::Reader.ReadRecord -> invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+
+	block := make([]byte, length)
+	if _, err = io.ReadFull(r.reader.R, block); err != nil {
+		return nil, fmt.Errorf("warc::Reader.ReadRecord -> %s", err)
+	}
+
+	return &Record{
+		Version: version,
+		Header:  header,
+		Block:   block,
+	}, nil
+}
@@ -0,0 +1,202 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func testPageResult(url string) crawler.PageResult {
+	return crawler.PageResult{
+		Url:        crawler.PageRequestFromUrl(url),
+		StatusCode: 200,
+		Headers: http.Header{
+			"Content-Type": {"text/html"},
+		},
+		RequestHeaders: http.Header{
+			"User-Agent": {"go-crawler"},
+		},
+		Protocol: "HTTP/1.1",
+	}
+}
+
+// returns the records of the WARC file fileName
+func readRecords(t *testing.T, fileName string) []*Record {
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := make([]*Record, 0)
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			return records
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	writer, err := NewWriter(filepath.Join(t.TempDir(), "crawl"+FILE_EXTENSION), 0, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte("<html>hello</html>")
+	if err = writer.WritePage(testPageResult("https://example.com/page?q=1"), body); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := writer.Files()
+	if len(files) != 1 || filepath.Base(files[0]) != "crawl-00000"+FILE_EXTENSION {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	records := readRecords(t, files[0])
+	tests := []struct {
+		recordType string
+		targetUri  string
+		prefix     string
+	}{
+		{RECORD_WARCINFO, "", "software: test"},
+		{RECORD_REQUEST, "https://example.com/page?q=1", "GET /page?q=1 HTTP/1.1\r\nHost: example.com\r\n"},
+		{RECORD_RESPONSE, "https://example.com/page?q=1", "HTTP/1.1 200 OK\r\n"},
+	}
+
+	if len(records) != len(tests) {
+		t.Fatalf("got %d records, expected %d", len(records), len(tests))
+	}
+
+	for i, test := range tests {
+		record := records[i]
+		if record.Version != WARC_VERSION || record.Type() != test.recordType || record.TargetUri() != test.targetUri {
+			t.Errorf("record %d: got %s %s %s", i, record.Version, record.Type(), record.TargetUri())
+		}
+		if !bytes.HasPrefix(record.Block, []byte(test.prefix)) {
+			t.Errorf("record %d: block %q does not start with %q", i, record.Block, test.prefix)
+		}
+		if record.Header.Get("WARC-Block-Digest") != digest(record.Block) {
+			t.Errorf("record %d: invalid block digest", i)
+		}
+	}
+
+	if records[1].Header.Get("WARC-Concurrent-To") != records[2].Header.Get("WARC-Record-ID") {
+		t.Errorf("the request is not concurrent to its response")
+	}
+
+	payload, err := records[2].Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, body) || records[2].Header.Get("WARC-Payload-Digest") != digest(body) {
+		t.Errorf("got payload %q, expected %q", payload, body)
+	}
+}
+
+func TestWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	// an existing file being kept
+	if err := os.WriteFile(filepath.Join(dir, "crawl-00000"+FILE_EXTENSION), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewWriter(filepath.Join(dir, "crawl"), 1000, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := 10
+	for i := 0; i < pages; i++ {
+		body := bytes.Repeat([]byte{byte(i)}, 2000)
+		if err = writer.WritePage(testPageResult("https://example.com/"), body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := writer.Files()
+	if len(files) < 2 || filepath.Base(files[0]) != "crawl-00001"+FILE_EXTENSION {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	responses := 0
+	for _, fileName := range files {
+		records := readRecords(t, fileName)
+		if len(records) == 0 || records[0].Type() != RECORD_WARCINFO {
+			t.Fatalf("%s does not start with a warcinfo record", fileName)
+		}
+		if len(records)%2 != 1 {
+			t.Errorf("%s: the records of a page are split across files", fileName)
+		}
+		for _, record := range records {
+			if record.Type() == RECORD_RESPONSE {
+				responses++
+			}
+		}
+	}
+
+	if responses != pages {
+		t.Errorf("got %d responses, expected %d", responses, pages)
+	}
+}
+
+func TestNewWriterMissingDirectory(t *testing.T) {
+	if _, err := NewWriter(filepath.Join(t.TempDir(), "missing", "crawl"), 0, "test"); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+}
+
+func TestReaderUncompressed(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewWriter(filepath.Join(dir, "crawl"), 0, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	compressed, err := os.ReadFile(writer.Files()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncompressed, err := io.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(bytes.NewReader(uncompressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := reader.ReadRecord()
+	if err != nil || record.Type() != RECORD_WARCINFO {
+		t.Fatalf("got %v, %v", record, err)
+	}
+	if _, err = reader.ReadRecord(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+const WARC_VERSION = "WARC/1.1"
+
+// the size of the compressed records after which a new file is started
+const DEFAULT_MAX_FILE_SIZE = 1 << 30
+
+const FILE_EXTENSION = ".warc.gz"
+
+// the format of WARC-Date, WARC 1.1 allowing fractions of seconds
+const DATE_FORMAT = "2006-01-02T15:04:05.000000Z"
+
+const (
+	RECORD_WARCINFO = "warcinfo"
+	RECORD_REQUEST  = "request"
+	RECORD_RESPONSE = "response"
+)
+
+// returns a new WARC-Record-ID
+func newRecordId() string {
+	var id [16]byte
+	rand.Read(id[:])
+
+	// uuid version 4
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+// returns the digest of body as a WARC-Block-Digest or a WARC-Payload-Digest
+func digest(body []byte) string {
+	sum := sha1.Sum(body)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// writes headers sorted by name, for the http messages to be reproducible
+func writeHeaders(buffer *bytes.Buffer, headers http.Header) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range headers[name] {
+			fmt.Fprintf(buffer, "%s: %s\r\n", name, value)
+		}
+	}
+}
+
+// returns the http request of a fetched page as sent by the crawler
+func httpRequest(pageResult crawler.PageResult) []byte {
+	var buffer bytes.Buffer
+
+	requestUri := "/"
+	host := ""
+	if u, err := url.Parse(pageResult.Url.ToUrl()); err == nil {
+		requestUri = u.RequestURI()
+		host = u.Host
+	}
+
+	// the http client sending HTTP/1.1 requests unless HTTP/2 has been negotiated
+	protocol := "HTTP/1.1"
+	if pageResult.Protocol == "HTTP/2.0" {
+		protocol = pageResult.Protocol
+	}
+
+	fmt.Fprintf(&buffer, "%s %s %s\r\n", pageResult.Url.GetMethod(), requestUri, protocol)
+	if len(pageResult.RequestHeaders.Get("Host")) == 0 {
+		fmt.Fprintf(&buffer, "Host: %s\r\n", host)
+	}
+	writeHeaders(&buffer, pageResult.RequestHeaders)
+	buffer.WriteString("\r\n")
+
+	return buffer.Bytes()
+}
+
+// returns the http response of a fetched page, the body being the one read
+// by the crawler (decompressed if the transport added Accept-Encoding)
+func httpResponse(pageResult crawler.PageResult, protocol string, body []byte) []byte {
+	var buffer bytes.Buffer
+
+	fmt.Fprintf(&buffer, "%s %d %s\r\n", protocol, pageResult.StatusCode, http.StatusText(pageResult.StatusCode))
+	writeHeaders(&buffer, pageResult.Headers)
+	buffer.WriteString("\r\n")
+	buffer.Write(body)
+
+	return buffer.Bytes()
+}
+
+// a WARC record to write, Header being written in order before the
+// WARC-Date, WARC-Record-ID, Content-Length and WARC-Block-Digest fields
+type record struct {
+	Type     string
+	Id       string
+	Date     time.Time
+	Header   [][2]string
+	Block    []byte
+	MimeType string
+}
+
+// counts the bytes written to the current file
+type countingWriter struct {
+	writer io.Writer
+	size   int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// writes the fetched pages as request and response records of gzip
+// compressed WARC 1.1 files (<prefix>-00000.warc.gz...), a new file being
+// started once MaxFileSize bytes have been written
+type Writer struct {
+	Prefix      string
+	MaxFileSize int64
+
+	// the name and version of the software in the warcinfo records
+	Software string
+
+	file       *os.File
+	files      []string
+	counter    *countingWriter
+	fileIndex  int
+	warcinfoId string
+
+	// the first error of the analyzer, returned by Close
+	err error
+	sync.Mutex
+}
+
+// returns a writer with its first file created, for the errors (a missing
+// directory...) to be reported before crawling, prefix ".warc.gz" being removed
+func NewWriter(prefix string, maxFileSize int64, software string) (*Writer, error) {
+	if maxFileSize <= 0 {
+		maxFileSize = DEFAULT_MAX_FILE_SIZE
+	}
+
+	writer := &Writer{
+		Prefix:      strings.TrimSuffix(prefix, FILE_EXTENSION),
+		MaxFileSize: maxFileSize,
+		Software:    software,
+	}
+
+	if err := writer.openFile(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// returns the names of the files created
+func (w *Writer) Files() []string {
+	w.Lock()
+	defer w.Unlock()
+
+	return append([]string{}, w.files...)
+}
+
+// creates the next file, the existing files being kept
+func (w *Writer) openFile() error {
+	for {
+		fileName := fmt.Sprintf("%s-%05d%s", w.Prefix, w.fileIndex, FILE_EXTENSION)
+		w.fileIndex++
+
+		file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("warc::Writer.openFile -> %s", err)
+		}
+
+		w.file = file
+		w.files = append(w.files, fileName)
+		w.counter = &countingWriter{writer: file}
+		break
+	}
+
+	var fields bytes.Buffer
+	fmt.Fprintf(&fields, "software: %s\r\n", w.Software)
+	fmt.Fprintf(&fields, "format: WARC File Format 1.1\r\n")
+	fmt.Fprintf(&fields, "conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n")
+
+	w.warcinfoId = newRecordId()
+	return w.writeRecord(record{
+		Type:     RECORD_WARCINFO,
+		Id:       w.warcinfoId,
+		Date:     time.Now(),
+		Header:   [][2]string{{"WARC-Filename", filepath.Base(w.file.Name())}},
+		Block:    fields.Bytes(),
+		MimeType: "application/warc-fields",
+	})
+}
+
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return fmt.Errorf("warc::Writer.closeFile -> %s", err)
+	}
+	return nil
+}
+
+// writes rec as a gzip member of its own, for the records to be read independently
+func (w *Writer) writeRecord(rec record) error {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%s\r\n", WARC_VERSION)
+	fmt.Fprintf(&buffer, "WARC-Type: %s\r\n", rec.Type)
+	for _, field := range rec.Header {
+		fmt.Fprintf(&buffer, "%s: %s\r\n", field[0], field[1])
+	}
+	fmt.Fprintf(&buffer, "WARC-Date: %s\r\n", rec.Date.UTC().Format(DATE_FORMAT))
+	fmt.Fprintf(&buffer, "WARC-Record-ID: %s\r\n", rec.Id)
+	fmt.Fprintf(&buffer, "Content-Type: %s\r\n", rec.MimeType)
+	fmt.Fprintf(&buffer, "Content-Length: %d\r\n", len(rec.Block))
+	fmt.Fprintf(&buffer, "WARC-Block-Digest: %s\r\n", digest(rec.Block))
+	buffer.WriteString("\r\n")
+	buffer.Write(rec.Block)
+	buffer.WriteString("\r\n\r\n")
+
+	gzipWriter := gzip.NewWriter(w.counter)
+	if _, err := gzipWriter.Write(buffer.Bytes()); err != nil {
+		return fmt.Errorf("warc::Writer.writeRecord -> %s", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("warc::Writer.writeRecord -> %s", err)
+	}
+	return nil
+}
+
+// writes the request and response records of a fetched page, body being
+// the body of the response
+func (w *Writer) WritePage(pageResult crawler.PageResult, body []byte) error {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.writePage(pageResult, body)
+}
+
+func (w *Writer) writePage(pageResult crawler.PageResult, body []byte) error {
+	if w.file == nil {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	date := time.Now()
+	if pageResult.Timings != nil {
+		date = pageResult.Timings.Start
+	}
+
+	protocol := pageResult.Protocol
+	if len(protocol) == 0 {
+		protocol = "HTTP/1.1"
+	}
+
+	targetUri := pageResult.Url.ToUrl()
+	responseId := newRecordId()
+
+	err := w.writeRecord(record{
+		Type: RECORD_REQUEST,
+		Id:   newRecordId(),
+		Date: date,
+		Header: [][2]string{
+			{"WARC-Target-URI", targetUri},
+			{"WARC-Warcinfo-ID", w.warcinfoId},
+			{"WARC-Concurrent-To", responseId},
+		},
+		Block:    httpRequest(pageResult),
+		MimeType: "application/http;msgtype=request",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = w.writeRecord(record{
+		Type: RECORD_RESPONSE,
+		Id:   responseId,
+		Date: date,
+		Header: [][2]string{
+			{"WARC-Target-URI", targetUri},
+			{"WARC-Warcinfo-ID", w.warcinfoId},
+			{"WARC-Payload-Digest", digest(body)},
+		},
+		Block:    httpResponse(pageResult, protocol, body),
+		MimeType: "application/http;msgtype=response",
+	})
+	if err != nil {
+		return err
+	}
+
+	// the records of a page being kept in the same file
+	if w.counter.size >= w.MaxFileSize {
+		return w.closeFile()
+	}
+	return nil
+}
+
+// returns an analyzer writing the records of every fetched page, the
+// archiving being stopped and logged on the first error, returned by Close
+func (w *Writer) Analyzer() crawler.PageAnalyzer {
+	return func(body []byte, pageResult *crawler.PageResult) {
+		w.Lock()
+		defer w.Unlock()
+
+		if w.err != nil {
+			return
+		}
+
+		if w.err = w.writePage(*pageResult, body); w.err != nil {
+			log.Println("warc archiving stopped:", w.err)
+		}
+	}
+}
+
+// closes the current file, returns the first error of the analyzer
+func (w *Writer) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if err := w.closeFile(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
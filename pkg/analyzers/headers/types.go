@@ -0,0 +1,20 @@
+package headers
+
+import (
+	"github.com/m1dugh/crawler/internal/analyzers/headers"
+)
+
+type Severity = headers.Severity
+type Finding = headers.Finding
+
+const (
+	SEVERITY_INFO   = headers.SEVERITY_INFO
+	SEVERITY_LOW    = headers.SEVERITY_LOW
+	SEVERITY_MEDIUM = headers.SEVERITY_MEDIUM
+	SEVERITY_HIGH   = headers.SEVERITY_HIGH
+)
+
+var ParseSeverity = headers.ParseSeverity
+var AuditPage = headers.AuditPage
+var Audit = headers.Audit
+var AuditFetchedUrls = headers.AuditFetchedUrls
@@ -0,0 +1,17 @@
+package secrets
+
+import (
+	"github.com/m1dugh/crawler/internal/analyzers/secrets"
+)
+
+const ANALYZER_NAME = secrets.ANALYZER_NAME
+
+type Rule = secrets.Rule
+type RuleSet = secrets.RuleSet
+type Finding = secrets.Finding
+type Analyzer = secrets.Analyzer
+
+var ParseRules = secrets.ParseRules
+var DefaultRules = secrets.DefaultRules
+var NewAnalyzer = secrets.NewAnalyzer
+var Entropy = secrets.Entropy
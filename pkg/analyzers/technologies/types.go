@@ -0,0 +1,13 @@
+package technologies
+
+import (
+	"github.com/m1dugh/crawler/internal/analyzers/technologies"
+)
+
+type PatternList = technologies.PatternList
+type Category = technologies.Category
+type Signature = technologies.Signature
+type Database = technologies.Database
+
+var ParseDatabase = technologies.ParseDatabase
+var DefaultDatabase = technologies.DefaultDatabase
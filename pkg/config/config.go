@@ -58,7 +58,11 @@ func initEmptyFile() {
 }
 
 func GetDefaultScopeFile() (*os.File, error) {
-	return os.Open(filepath.Join(ROOT_PATH, "scope.json"))
+	path, err := findScopeFile(filepath.Join(ROOT_PATH, "scope"))
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
 }
 
 func GetConfig() (Config, error) {
@@ -89,6 +93,52 @@ func SaveConfig(config Config) bool {
 	return err == nil
 }
 
+// loads the plugin described by pluginConfig and initializes it with its settings
+func LoadPlugin(pluginConfig *PluginConfig) (*crplg.CrawlerPlugin, error) {
+	plg, err := OpenPlugin(pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// exec plugins being sent their settings once validated against the schema
+	// returned by their first initialize
+	if err = plg.Initialize(pluginConfig.Settings); err != nil {
+		plg.Close()
+		return nil, fmt.Errorf("config::LoadPlugin -> could not initialize %s: %w", pluginConfig.Name, err)
+	}
+
+	return plg, nil
+}
+
+// loads the plugin described by pluginConfig without initializing it, for its
+// metadata, settings schema and filters to be read: the OnInit hook of a go plugin
+// is not called and an exec plugin only receives the initialize sent without settings
+func OpenPlugin(pluginConfig *PluginConfig) (*crplg.CrawlerPlugin, error) {
+	var plg *crplg.CrawlerPlugin
+	var err error
+
+	switch pluginConfig.GetKind() {
+	case PLUGIN_KIND_GO:
+		plg, err = crplg.GetCrawlerPlugin(pluginConfig.GetPath())
+	case PLUGIN_KIND_EXEC:
+		options := crplg.NewExecPluginOptions()
+		options.Timeout = pluginConfig.GetCallTimeout(options.Timeout)
+		if pluginConfig.MaxRestarts != nil {
+			options.MaxRestarts = *pluginConfig.MaxRestarts
+		}
+		options.FailOpen = pluginConfig.FailOpen
+		plg, err = crplg.GetExecPlugin(pluginConfig.GetPath(), pluginConfig.Args, options)
+	default:
+		return nil, fmt.Errorf("config::OpenPlugin -> unknown plugin kind %s", pluginConfig.Kind)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return plg, nil
+}
+
 func LoadPluginsFromConfig() map[string]*crplg.CrawlerPlugin {
 	config, err := GetConfig()
 	if err != nil {
@@ -98,18 +148,11 @@ func LoadPluginsFromConfig() map[string]*crplg.CrawlerPlugin {
 	res := make(map[string]*crplg.CrawlerPlugin, len(config.Plugins))
 	for _, pluginConfig := range config.Plugins {
 		if pluginConfig.Active {
-			// paths of plugins are relative to ROOT_PATH folder
-			var pluginPath string
-
-			if strings.HasPrefix(pluginConfig.Path, "/") {
-				pluginPath = pluginConfig.Path
-			} else {
-
-				pluginPath = filepath.Join(ROOT_PATH, pluginConfig.Path)
-			}
-			plg, err := crplg.GetCrawlerPlugin(pluginPath)
+			plg, err := LoadPlugin(pluginConfig)
 			if err == nil {
 				res[pluginConfig.Name] = plg
+			} else {
+				log.Printf("could not load plugin %s: %s\n", pluginConfig.Name, err)
 			}
 		}
 	}
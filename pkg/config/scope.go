@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var SCOPES_PATH string = ROOT_PATH + "/scopes"
+
+// the extensions tried when looking for a scope file without extension
+var SCOPE_EXTENSIONS = []string{".yaml", ".yml", ".json"}
+
+// the prefix used to reference a scope stored in SCOPES_PATH
+const NAMED_SCOPE_PREFIX = "@"
+
+// a list of scope references accepting either a single string or a list
+type ScopeReferences []string
+
+func (refs *ScopeReferences) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*refs = ScopeReferences{single}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+
+	*refs = list
+	return nil
+}
+
+// the structure of a scope file, json files being valid yaml, both formats are supported
+type ScopeFile struct {
+	// the scopes the current scope inherits from, either paths relative
+	// to the scope file or names of scopes in SCOPES_PATH prefixed by '@'
+	Extends ScopeReferences `yaml:"extends"`
+
+	crawler.Scope `yaml:",inline"`
+}
+
+func findScopeFile(basePath string) (string, error) {
+	if info, err := os.Stat(basePath); err == nil && !info.IsDir() {
+		return basePath, nil
+	}
+
+	for _, extension := range SCOPE_EXTENSIONS {
+		if _, err := os.Stat(basePath + extension); err == nil {
+			return basePath + extension, nil
+		}
+	}
+
+	return "", fmt.Errorf("config::findScopeFile -> could not find scope %s", basePath)
+}
+
+// returns the path of the scope file referenced by ref, relative paths
+// are resolved against dir
+func ResolveScopePath(ref string, dir string) (string, error) {
+	if strings.HasPrefix(ref, NAMED_SCOPE_PREFIX) {
+		name := ref[len(NAMED_SCOPE_PREFIX):]
+		if len(name) == 0 {
+			return "", errors.New("config::ResolveScopePath -> empty scope name")
+		}
+		return findScopeFile(filepath.Join(SCOPES_PATH, name))
+	}
+
+	if !filepath.IsAbs(ref) && len(dir) > 0 {
+		ref = filepath.Join(dir, ref)
+	}
+
+	return findScopeFile(ref)
+}
+
+func loadScopeFile(path string, parents map[string]bool) (*crawler.Scope, error) {
+
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if parents[absolutePath] {
+		return nil, fmt.Errorf("config::loadScopeFile -> %s extends itself", path)
+	}
+
+	source, err := ioutil.ReadFile(absolutePath)
+	if err != nil {
+		return nil, fmt.Errorf("config::loadScopeFile -> could not read %s", path)
+	}
+
+	var scopeFile ScopeFile
+	if err = yaml.Unmarshal(source, &scopeFile); err != nil {
+		return nil, fmt.Errorf("config::loadScopeFile -> could not unmarshal %s: %s", path, err)
+	}
+
+	parents[absolutePath] = true
+	defer delete(parents, absolutePath)
+
+	var result *crawler.Scope = &crawler.Scope{}
+	for _, ref := range scopeFile.Extends {
+		basePath, err := ResolveScopePath(ref, filepath.Dir(absolutePath))
+		if err != nil {
+			return nil, err
+		}
+
+		base, err := loadScopeFile(basePath, parents)
+		if err != nil {
+			return nil, err
+		}
+		result = result.Merge(base)
+	}
+
+	return result.Merge(&scopeFile.Scope), nil
+}
+
+// loads and merges all the scopes referenced by refs,
+// refs being paths to scope files or names of scopes in SCOPES_PATH prefixed by '@'
+func LoadScope(refs ...string) (*crawler.Scope, error) {
+
+	if len(refs) == 0 {
+		return nil, errors.New("config::LoadScope -> no scope provided")
+	}
+
+	var result *crawler.Scope = &crawler.Scope{}
+	for _, ref := range refs {
+		path, err := ResolveScopePath(ref, "")
+		if err != nil {
+			return nil, err
+		}
+
+		scope, err := loadScopeFile(path, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+
+		result = result.Merge(scope)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/m1dugh/crawler/internal/analyzers/secrets"
+)
+
+// the rule file merged with the default rules of the secrets analyzer
+var SECRETS_RULES_FILE = ROOT_PATH + "/secrets.yaml"
+
+// returns the default rules of the secrets analyzer merged with the rules
+// of path, or of SECRETS_RULES_FILE if path is empty and the file exists
+func LoadSecretsRules(path string) (*secrets.RuleSet, error) {
+	rules := secrets.DefaultRules()
+
+	if len(path) == 0 {
+		if _, err := os.Stat(SECRETS_RULES_FILE); err != nil {
+			return rules, nil
+		}
+		path = SECRETS_RULES_FILE
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config::LoadSecretsRules -> could not read %s", path)
+	}
+
+	custom, err := secrets.ParseRules(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules.Merge(custom), nil
+}
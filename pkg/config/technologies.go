@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/m1dugh/crawler/internal/analyzers/technologies"
+)
+
+// the database merged with the default database of the technology fingerprinting
+var TECHNOLOGIES_FILE = ROOT_PATH + "/technologies.json"
+
+// returns the default technologies database merged with the database of path,
+// or of TECHNOLOGIES_FILE if path is empty and the file exists
+func LoadTechnologies(path string) (*technologies.Database, error) {
+	database := technologies.DefaultDatabase()
+
+	if len(path) == 0 {
+		if _, err := os.Stat(TECHNOLOGIES_FILE); err != nil {
+			return database, nil
+		}
+		path = TECHNOLOGIES_FILE
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config::LoadTechnologies -> could not read %s", path)
+	}
+
+	custom, err := technologies.ParseDatabase(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.Merge(custom), nil
+}
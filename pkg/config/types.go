@@ -1,16 +1,115 @@
 package config
 
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	crplg "github.com/m1dugh/crawler/internal/plugin"
+)
+
+// the kinds of plugins
+const (
+	// a go plugin built with -buildmode=plugin
+	PLUGIN_KIND_GO = "go"
+
+	// an executable speaking the exec plugin protocol over stdin/stdout
+	PLUGIN_KIND_EXEC = "exec"
+)
+
 type PluginConfig struct {
 	Name   string `yaml:"name"`
 	Path   string `yaml:"path"`
 	Active bool   `yaml:"active"`
 	/*Symbols []string `yaml:"symbols"`*/
+
+	// the kind of the plugin, PLUGIN_KIND_GO if empty
+	Kind string `yaml:"kind,omitempty"`
+
+	// the arguments given to exec plugins
+	Args []string `yaml:"args,omitempty"`
+
+	// the max duration of a hook call ("10s", "500ms"...)
+	Timeout string `yaml:"timeout,omitempty"`
+
+	// the max duration of a call to an exec plugin, the process being killed and restarted when exceeded
+	CallTimeout string `yaml:"call_timeout,omitempty"`
+
+	// the number of times an exec plugin is restarted after crashing or timing out
+	MaxRestarts *int `yaml:"max_restarts,omitempty"`
+
+	// the settings given to the plugin when loaded
+	Settings map[string]string `yaml:"settings,omitempty"`
+
+	// the max number of concurrent calls to the hooks of the plugin, unlimited if not set
+	MaxConcurrency int `yaml:"max_concurrency,omitempty"`
+
+	// the number of consecutive failures (errors, panics or timeouts) after which the plugin is disabled
+	MaxFailures *int `yaml:"max_failures,omitempty"`
+
+	// sends the requests whose BeforeRequest hook fails, the requests being skipped if not set
+	FailOpen bool `yaml:"fail_open,omitempty"`
+}
+
+// returns the isolation options of the plugin hooks
+func (cfg *PluginConfig) GetGuardOptions() *crplg.GuardOptions {
+	options := crplg.NewGuardOptions()
+	options.Timeout = cfg.GetTimeout(options.Timeout)
+	options.MaxConcurrency = cfg.MaxConcurrency
+	if cfg.MaxFailures != nil {
+		options.MaxFailures = *cfg.MaxFailures
+	}
+	options.FailOpen = cfg.FailOpen
+	return options
+}
+
+func (cfg *PluginConfig) GetKind() string {
+	if len(cfg.Kind) == 0 {
+		return PLUGIN_KIND_GO
+	}
+	return cfg.Kind
+}
+
+func parseTimeout(value string, defaultTimeout time.Duration) time.Duration {
+	if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultTimeout
+}
+
+// returns the timeout of the hook calls or defaultTimeout if not set or invalid
+func (cfg *PluginConfig) GetTimeout(defaultTimeout time.Duration) time.Duration {
+	return parseTimeout(cfg.Timeout, defaultTimeout)
+}
+
+// returns the timeout of the calls to an exec plugin or defaultTimeout if not set or invalid
+func (cfg *PluginConfig) GetCallTimeout(defaultTimeout time.Duration) time.Duration {
+	return parseTimeout(cfg.CallTimeout, defaultTimeout)
+}
+
+// returns the path of the plugin, relative paths being relative to ROOT_PATH
+func (cfg *PluginConfig) GetPath() string {
+	if strings.HasPrefix(cfg.Path, "/") {
+		return cfg.Path
+	}
+
+	return filepath.Join(ROOT_PATH, cfg.Path)
 }
 
 type Config struct {
 	Plugins []*PluginConfig `yaml:"plugins"`
 }
 
+// returns the config of the plugin named name, nil if not found
+func (cfg *Config) GetPlugin(name string) *PluginConfig {
+	for _, p := range cfg.Plugins {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
 func DefaultConfig() *Config {
 	return &Config{}
 }
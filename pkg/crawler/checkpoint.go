@@ -0,0 +1,206 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+// writes data to fileName as json, the data being written to a temporary
+// file renamed to fileName for fileName to never hold a partial scan
+func SaveData(fileName string, data crawler.CrawlerData) error {
+	err := crawler.WriteFileAtomic(fileName, func(file *bufio.Writer) error {
+		return json.NewEncoder(file).Encode(data)
+	})
+	if err != nil {
+		return fmt.Errorf("crawler::SaveData -> could not write %s: %s", fileName, err)
+	}
+
+	return nil
+}
+
+// writes the data of store to fileName like SaveData, the entries being read and
+// written one at a time instead of copying the whole data of the crawl
+func SaveStore(fileName string, store crawler.Store) error {
+	err := crawler.WriteFileAtomic(fileName, func(file *bufio.Writer) error {
+		return writeStore(file, store)
+	})
+	if err != nil {
+		return fmt.Errorf("crawler::SaveStore -> could not write %s: %s", fileName, err)
+	}
+
+	return nil
+}
+
+// writes the json of the CrawlerData of store
+func writeStore(w *bufio.Writer, store crawler.Store) error {
+	writeJson := func(value interface{}) error {
+		body, err := json.Marshal(value)
+		if err == nil {
+			_, err = w.Write(body)
+		}
+		return err
+	}
+
+	urlsToFetch, err := store.GetUrlsToFetch()
+	if err != nil {
+		return err
+	}
+	w.WriteString(`{"urls_to_fetch":`)
+	if err = writeJson(urlsToFetch); err != nil {
+		return err
+	}
+
+	// the entries of a domain being handled one after the other, a domain is
+	// closed when the next one starts
+	w.WriteString(`,"fetched_urls":{`)
+	var lastDomain *string
+	err = store.ForEachEntry(func(domainName string, baseUrl string, entry crawler.DomainResultEntry) error {
+		if lastDomain == nil || *lastDomain != domainName {
+			if lastDomain != nil {
+				w.WriteString(`},`)
+			}
+			if err := writeJson(domainName); err != nil {
+				return err
+			}
+			w.WriteString(`:{`)
+			lastDomain = &domainName
+		} else {
+			w.WriteString(`,`)
+		}
+
+		if err := writeJson(baseUrl); err != nil {
+			return err
+		}
+		w.WriteString(`:`)
+		return writeJson(entry)
+	})
+	if err != nil {
+		return err
+	}
+	if lastDomain != nil {
+		w.WriteString(`}`)
+	}
+	w.WriteString(`}`)
+
+	if seen := store.FilterData().Seen; seen != nil {
+		w.WriteString(`,"seen":`)
+		if err = writeJson(seen); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.WriteString("}\n")
+	return err
+}
+
+// reads the data written by SaveData
+func LoadData(fileName string) (*crawler.CrawlerData, error) {
+	body, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("crawler::LoadData -> could not read %s: %s", fileName, err)
+	}
+
+	data := crawler.NewCrawlerData()
+	data.Seen = nil
+	if err = json.Unmarshal(body, data); err != nil {
+		return nil, fmt.Errorf("crawler::LoadData -> could not unmarshal %s: %s", fileName, err)
+	}
+
+	if data.FetchedUrls == nil {
+		data.FetchedUrls = make(crawler.FetchedUrls)
+	}
+
+	// the scans saved before the seen set was recorded have no seen key
+	if data.Seen == nil {
+		data.UseSeenSet(crawler.NewSeenSet())
+	}
+
+	return data, nil
+}
+
+func (c *Crawler) isCheckpointDue() bool {
+	if c.Options.CheckpointPages > 0 && c.pagesSinceCheckpoint >= c.Options.CheckpointPages {
+		return true
+	}
+
+	return c.Options.CheckpointInterval > 0 && time.Since(c.lastCheckpoint) >= c.Options.CheckpointInterval
+}
+
+// returns true if the checkpoint written in the background is still being written
+func (c *Crawler) isCheckpointWriting() bool {
+	if c.checkpointWriting == nil {
+		return false
+	}
+
+	select {
+	case <-c.checkpointWriting:
+		c.checkpointWriting = nil
+		return false
+	default:
+		return true
+	}
+}
+
+// waits for the checkpoint written in the background, for the checkpoint file
+// not to be written once the crawl has returned
+func (c *Crawler) waitCheckpoint() {
+	if c.checkpointWriting != nil {
+		<-c.checkpointWriting
+		c.checkpointWriting = nil
+	}
+}
+
+// saves the data of the crawl to Options.CheckpointFile and syncs the store if a
+// checkpoint is due, the pending requests being saved as urls to fetch for the scan to be resumed,
+// the data being copied and written in the background for the crawl not to wait for the file,
+// a checkpoint due while the previous one is still being written being delayed
+func (c *Crawler) checkpointIfDue(pending map[int]crawler.PageRequest) {
+	if !c.isCheckpointDue() {
+		return
+	}
+
+	if len(c.Options.CheckpointFile) > 0 && c.isCheckpointWriting() {
+		return
+	}
+
+	pendingUrls := make([]crawler.PageRequest, 0, len(pending))
+	for _, url := range pending {
+		pendingUrls = append(pendingUrls, url)
+	}
+
+	if len(c.Options.CheckpointFile) > 0 {
+		var data crawler.CrawlerData
+		if memoryStore, ok := c.store.(*crawler.MemoryStore); ok {
+			// the dispatch loop being the only writer of the store, it is copied without dataLock
+			data = memoryStore.Copy()
+		} else {
+			data = c.GetData()
+		}
+		data.UrlsToFetch = append(data.UrlsToFetch, pendingUrls...)
+
+		done := make(chan struct{})
+		c.checkpointWriting = done
+		go func() {
+			defer close(done)
+			if err := SaveData(c.Options.CheckpointFile, data); err != nil {
+				log.Println("could not write checkpoint:", err)
+			}
+		}()
+	}
+
+	c.dataLock.Lock()
+	err := c.store.Sync(pendingUrls)
+	c.dataLock.Unlock()
+	if err != nil {
+		log.Println("could not sync store:", err)
+	}
+
+	c.lastCheckpoint = time.Now()
+	c.pagesSinceCheckpoint = 0
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+func TestSaveStore(t *testing.T) {
+	tests := []struct {
+		name        string
+		urlsToFetch []string
+		fetched     []string
+	}{
+		{"empty", nil, nil},
+		{"urls to fetch", []string{"https://a.com/1", "https://b.com/2"}, nil},
+		{"one domain", nil, []string{"https://a.com/1", "https://a.com/2?q=1", "https://a.com/2?q=2"}},
+		{"several domains", []string{"https://c.com/"}, []string{"https://a.com/1", "https://b.com/1", "https://a.com/2", "https://b.com/2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := crawler.NewCrawlerData()
+			for _, url := range test.urlsToFetch {
+				data.UrlsToFetch = append(data.UrlsToFetch, crawler.PageRequestFromUrl(url))
+			}
+			store := crawler.NewMemoryStore(data)
+			for _, url := range test.fetched {
+				store.PushUrl(crawler.PageRequestFromUrl(url))
+				popped, _, _ := store.PopUrl()
+				store.AddPageResult(crawler.PageResult{Url: popped, StatusCode: 200}, crawler.Attachements{"url": url})
+			}
+
+			fileName := filepath.Join(t.TempDir(), "scan.db")
+			if err := SaveStore(fileName, store); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := LoadData(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(loaded.UrlsToFetch) != len(data.UrlsToFetch) {
+				t.Errorf("expected %d urls to fetch, got %d", len(data.UrlsToFetch), len(loaded.UrlsToFetch))
+			}
+			if !reflect.DeepEqual(loaded.FetchedUrls, data.FetchedUrls) {
+				t.Errorf("expected fetched urls %v, got %v", data.FetchedUrls, loaded.FetchedUrls)
+			}
+			if loaded.SeenSet().Len() != data.SeenSet().Len() {
+				t.Errorf("expected %d seen urls, got %d", data.SeenSet().Len(), loaded.SeenSet().Len())
+			}
+		})
+	}
+}
+
+func TestLoadDataWithoutSeen(t *testing.T) {
+	// a scan saved before the seen set was recorded
+	const body = `{
+	"urls_to_fetch": [{"base_url": "https://a.com/queued"}],
+	"fetched_urls": {"a.com": {"https://a.com/fetched": {"results": [{"url": {"base_url": "https://a.com/fetched"}, "status_code": 200}]}}}
+}`
+
+	tests := []struct {
+		name string
+		url  string
+		seen bool
+	}{
+		{"queued", "https://a.com/queued", true},
+		{"fetched", "https://a.com/fetched", true},
+		{"new", "https://a.com/new", false},
+	}
+
+	fileName := filepath.Join(t.TempDir(), "scan.db")
+	if err := os.WriteFile(fileName, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := LoadData(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := crawler.NewMemoryStore(data)
+
+			url := crawler.PageRequestFromUrl(test.url)
+			if seen := store.IsSeen(url); seen != test.seen {
+				t.Errorf("expected seen %v, got %v", test.seen, seen)
+			}
+			if added, _ := store.PushUrl(url); added == test.seen {
+				t.Errorf("expected added %v, got %v", !test.seen, added)
+			}
+		})
+	}
+}
@@ -1,8 +1,6 @@
 package crawler
 
 import (
-	"bytes"
-	"encoding/gob"
 	"log"
 	"net/http"
 	"sync"
@@ -38,6 +36,15 @@ type Options struct {
 
 	// a flag indicating wether robots.txt should be fetched
 	FetchRobots bool
+
+	// the file the data of the crawl is saved to on every checkpoint, no file if empty
+	CheckpointFile string
+
+	// the max duration between two checkpoints, 0 to disable
+	CheckpointInterval time.Duration
+
+	// the max number of fetched pages between two checkpoints, 0 to disable
+	CheckpointPages int
 }
 
 var DEFAULT_HEADERS_PROVIDER = func(crawler.PageRequest) http.Header {
@@ -61,13 +68,38 @@ func NewCrawlerOptions() *Options {
 }
 
 type Crawler struct {
-	Scope               *crawler.Scope
-	Options             *Options
-	data                *crawler.CrawlerData
+	Scope   *crawler.Scope
+	Options *Options
+
+	// the data of the crawl, only modified by the dispatch loop while holding dataLock
+	store    crawler.Store
+	dataLock sync.RWMutex
+
 	OnUrlFound          chan []crawler.PageRequest
 	OnEndRequested      chan bool
-	done                bool
+	done                int32
 	GetPluginsForDomain func(domainName string) []crawler.OnPageResultAdded
+
+	// returns the hooks to call for the pages of a domain
+	GetHooksForDomain func(domainName string) []crawler.DomainHooks
+
+	// the hooks called when the crawl starts and ends
+	CrawlHooks []crawler.CrawlHooks
+
+	// the analyzers enriching the result of every fetched page, a panicking
+	// analyzer being logged and skipped
+	PageAnalyzers []crawler.PageAnalyzer
+
+	// the time and number of fetched pages of the last checkpoint, see Options.CheckpointInterval
+	lastCheckpoint       time.Time
+	pagesSinceCheckpoint int
+
+	// closed once the checkpoint being written in the background is written, nil if none
+	checkpointWriting chan struct{}
+
+	// the subscriptions to the events of the crawler, see Subscribe
+	subscriptions     []*Subscription
+	subscriptionsLock sync.RWMutex
 }
 
 func NewCrawler(scope *crawler.Scope, opts *Options) *Crawler {
@@ -78,24 +110,184 @@ func NewCrawler(scope *crawler.Scope, opts *Options) *Crawler {
 
 	return &Crawler{
 		Scope:   scope,
-		data:    crawler.NewCrawlerData(),
+		store:   crawler.NewMemoryStore(nil),
 		Options: opts,
-		done:    false,
 	}
 }
 
 func (c *Crawler) IsDone() bool {
-	return c.done
+	return atomic.LoadInt32(&c.done) == 1
+}
+
+func (c *Crawler) setDone(done bool) {
+	var value int32
+	if done {
+		value = 1
+	}
+	atomic.StoreInt32(&c.done, value)
 }
 
 // launches the crawler with the given data
 func (c *Crawler) ResumeScan(data *crawler.CrawlerData) {
-	c.data = data
+	c.SetStore(crawler.NewMemoryStore(data))
 	c.Crawl([]string{})
 }
 
+// sets the store the urls to fetch and the results of the crawl are kept in,
+// the crawl being resumed from the content of the store, must not be called while crawling
+func (c *Crawler) SetStore(store crawler.Store) {
+	c.dataLock.Lock()
+	c.store = store
+	c.dataLock.Unlock()
+}
+
+func (c *Crawler) GetStore() crawler.Store {
+	return c.store
+}
+
+// returns a copy of the data of the crawl, safe to call while the crawler is running,
+// the results being read from disk for the stores keeping them on disk
 func (c *Crawler) GetData() crawler.CrawlerData {
-	return *(c.data)
+	c.dataLock.RLock()
+	defer c.dataLock.RUnlock()
+
+	data, err := c.store.Snapshot()
+	if err != nil {
+		log.Println("could not read crawl data:", err)
+	}
+	return data
+}
+
+// writes the data of the crawl to fileName like SaveData, reading and writing the
+// results one endpoint at a time instead of copying them all like GetData
+func (c *Crawler) Save(fileName string) error {
+	c.dataLock.RLock()
+	defer c.dataLock.RUnlock()
+
+	return SaveStore(fileName, c.store)
+}
+
+// calls handle with a copy of the results of every endpoint, read one at a time,
+// handle must not modify the crawl
+func (c *Crawler) ForEachEntry(handle func(domainName string, baseUrl string, entry crawler.DomainResultEntry) error) error {
+	c.dataLock.RLock()
+	defer c.dataLock.RUnlock()
+
+	return c.store.ForEachEntry(handle)
+}
+
+// returns a copy of the FilterData of the store and its urls to fetch, the whole data
+// for a MemoryStore, only the summary of the results for the stores keeping them on disk
+func (c *Crawler) getSummaryData() crawler.CrawlerData {
+	c.dataLock.RLock()
+	defer c.dataLock.RUnlock()
+
+	data := c.store.FilterData().Copy()
+	urlsToFetch, err := c.store.GetUrlsToFetch()
+	if err != nil {
+		log.Println("could not read crawl data:", err)
+	}
+	data.UrlsToFetch = urlsToFetch
+	return data
+}
+
+// returns a copy of the results of a domain, safe to call while the crawler is running
+func (c *Crawler) GetDomainResults(domainName string) crawler.DomainResults {
+	c.dataLock.RLock()
+	defer c.dataLock.RUnlock()
+
+	results := crawler.NewDomainResults()
+	for baseUrl := range c.store.FilterData().FetchedUrls[domainName] {
+		entry, ok, err := c.store.GetEntry(domainName, baseUrl)
+		if err != nil {
+			log.Println("could not read crawl data:", err)
+		} else if ok {
+			results[baseUrl] = &entry
+		}
+	}
+	return results
+}
+
+// returns a copy of the entry of baseUrl, an empty entry if baseUrl has not been fetched yet
+func (c *Crawler) getDomainResultEntry(domainName string, baseUrl string) crawler.DomainResultEntry {
+	c.dataLock.RLock()
+	defer c.dataLock.RUnlock()
+
+	entry, _, err := c.store.GetEntry(domainName, baseUrl)
+	if err != nil {
+		log.Println("could not read crawl data:", err)
+	}
+	return entry
+}
+
+// returns the hooks of GetHooksForDomain and GetPluginsForDomain for domainName
+func (c *Crawler) getHooksForDomain(domainName string) []crawler.DomainHooks {
+	hooks := make([]crawler.DomainHooks, 0)
+	if c.GetHooksForDomain != nil {
+		hooks = append(hooks, c.GetHooksForDomain(domainName)...)
+	}
+
+	if c.GetPluginsForDomain != nil {
+		for _, handler := range c.GetPluginsForDomain(domainName) {
+			hooks = append(hooks, crawler.DomainHooks{
+				OnPageResultAdded: handler,
+			})
+		}
+	}
+
+	return hooks
+}
+
+// runs analyze on the page, a panic being logged for the crawl to go on
+func runPageAnalyzer(analyze crawler.PageAnalyzer, body []byte, pageResult *crawler.PageResult) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("page analyzer panicked on %s: %v\n", pageResult.Url.ToUrl(), recovered)
+		}
+	}()
+
+	analyze(body, pageResult)
+}
+
+func callOnError(hooks []crawler.DomainHooks, url crawler.PageRequest, err error) {
+	for _, hook := range hooks {
+		if hook.OnError != nil {
+			hook.OnError(url, err)
+		}
+	}
+}
+
+func (c *Crawler) start() {
+	var urlsToFetch []crawler.PageRequest
+	for _, hooks := range c.CrawlHooks {
+		if hooks.OnStart != nil {
+			if urlsToFetch == nil {
+				c.dataLock.RLock()
+				var err error
+				if urlsToFetch, err = c.store.GetUrlsToFetch(); err != nil {
+					log.Println("could not read crawl data:", err)
+				}
+				c.dataLock.RUnlock()
+			}
+			hooks.OnStart(append([]crawler.PageRequest{}, urlsToFetch...))
+		}
+	}
+}
+
+// calls the OnFinish hooks with the summary data of the store, see getSummaryData
+func (c *Crawler) finish() {
+	var data *crawler.CrawlerData
+	for _, hooks := range c.CrawlHooks {
+		if hooks.OnFinish != nil {
+			if data == nil {
+				summary := c.getSummaryData()
+				data = &summary
+			}
+			hooks.OnFinish(data.Copy(), c.IsDone())
+		}
+	}
+
+	c.emit(Event{Type: EVENT_CRAWL_FINISHED, Done: c.IsDone()})
 }
 
 type _SyncCounter struct {
@@ -151,6 +343,8 @@ func (counter *_SyncCounter) IsEmpty() bool {
 }
 
 type _CrawlerFetchResult struct {
+	// the key of the request in the pending requests of the crawl
+	id int
 	crawler.Attachements
 	crawler.PageResult
 }
@@ -161,9 +355,13 @@ func (c *Crawler) Crawl(baseUrls []string) {
 		log.Fatal("scope is not set")
 	}
 
+	c.dataLock.Lock()
 	for _, v := range baseUrls {
-		c.data.UrlsToFetch = append(c.data.UrlsToFetch, crawler.PageRequestFromUrl(v))
+		if _, err := c.store.PushUrl(crawler.PageRequestFromUrl(v)); err != nil {
+			log.Fatal(err)
+		}
 	}
+	c.dataLock.Unlock()
 
 	var shouldAddFilter crawler.ShouldAddFilter
 
@@ -185,23 +383,37 @@ func (c *Crawler) Crawl(baseUrls []string) {
 	inChannel := make(chan crawler.PageRequest)
 	outChannel := make(chan _CrawlerFetchResult)
 
-	c.done = false
+	c.setDone(false)
+
+	c.start()
+	defer c.finish()
+	defer c.waitCheckpoint()
 
 	var workers int32 = 0
 
 	requestCounter := NewSyncCounter(c.Options.RequestRate)
 
-	for len(c.data.UrlsToFetch) > 0 || workers > 0 {
+	// the requests being fetched by the workers, saved back to the urls to fetch by checkpoints
+	pending := make(map[int]crawler.PageRequest)
+	nextId := 0
 
-		addedWorkers := 0
+	c.lastCheckpoint = time.Now()
+	c.pagesSinceCheckpoint = 0
+
+	// the interval checkpoints being written while the workers are slow to respond
+	var checkpointTicker <-chan time.Time
+	if c.Options.CheckpointInterval > 0 {
+		ticker := time.NewTicker(c.Options.CheckpointInterval)
+		defer ticker.Stop()
+		checkpointTicker = ticker.C
+	}
 
-		fetchedUrlsMapCopy, err := fetchedUrlsCopy(c.data.FetchedUrls)
+	// the dispatch loop being the only writer of c.store, it reads it without holding dataLock
+	for c.store.Size() > 0 || atomic.LoadInt32(&workers) > 0 {
 
-		if err != nil {
-			log.Fatal(err)
-		}
+		addedWorkers := 0
 
-		for c.Options.MaxWorkers-workers > 0 {
+		for c.Options.MaxWorkers-atomic.LoadInt32(&workers) > 0 {
 
 			if !requestCounter.IsReady() {
 				if addedWorkers > 0 {
@@ -210,7 +422,12 @@ func (c *Crawler) Crawl(baseUrls []string) {
 					continue
 				}
 			}
-			url, ok := c.data.PopUrlToFetch()
+			c.dataLock.Lock()
+			url, ok, err := c.store.PopUrl()
+			c.dataLock.Unlock()
+			if err != nil {
+				log.Fatal(err)
+			}
 			if !ok {
 				break
 			}
@@ -218,6 +435,21 @@ func (c *Crawler) Crawl(baseUrls []string) {
 			if c.OnEndRequested != nil {
 				select {
 				case <-c.OnEndRequested:
+					// the urls not fetched yet are kept for the scan to be resumed
+					unfetched := []crawler.PageRequest{url}
+					for _, pendingUrl := range pending {
+						unfetched = append(unfetched, pendingUrl)
+					}
+
+					c.dataLock.Lock()
+					err := c.store.Requeue(unfetched)
+					if err == nil {
+						err = c.store.Sync(nil)
+					}
+					c.dataLock.Unlock()
+					if err != nil {
+						log.Println("could not save the urls to fetch:", err)
+					}
 					return
 				default:
 
@@ -225,82 +457,190 @@ func (c *Crawler) Crawl(baseUrls []string) {
 
 			}
 
+			id := nextId
+			nextId++
+			pending[id] = url
+
 			atomic.AddInt32(&workers, 1)
 			addedWorkers++
 			requestCounter.Increment()
-			go func(fetchedUrls crawler.FetchedUrls) {
+			go func(id int) {
 				defer atomic.AddInt32(&workers, -1)
 				url := <-inChannel
 
-				var pageResult crawler.PageResult
-				var body []byte
+				domainName := crawler.ExtractDomainName(url.BaseUrl)
+				hooks := c.getHooksForDomain(domainName)
+
+				request, err := http.NewRequest(url.GetMethod(), url.ToUrl(), nil)
+				if err != nil {
+					callOnError(hooks, url, err)
+					c.emit(Event{Type: EVENT_ERROR, Url: url, Err: err})
+					outChannel <- _CrawlerFetchResult{id: id}
+					return
+				}
+
 				if c.Options.HeadersProvider != nil {
-					request, _ := http.NewRequest("GET", url.ToUrl(), nil)
 					request.Header = c.Options.HeadersProvider(url)
-					pageResult, body, _ = crawler.FetchPage(httpClient, url, c.Scope, fetchedUrls, request)
-				} else {
-					pageResult, body, _ = crawler.FetchPage(httpClient, url, c.Scope, fetchedUrls, nil)
+				}
+
+				for _, hook := range hooks {
+					if hook.BeforeRequest != nil && !hook.BeforeRequest(request, url) {
+						c.emit(Event{Type: EVENT_URL_REJECTED, Url: url, Reason: crawler.REJECT_SKIPPED})
+						outChannel <- _CrawlerFetchResult{id: id}
+						return
+					}
+				}
+
+				c.emit(Event{Type: EVENT_REQUEST_STARTED, Url: url})
+				startTime := time.Now()
+
+				pageResult, body, err := crawler.FetchPage(httpClient, url, c.Scope, nil, request)
+				duration := time.Since(startTime)
+				if err != nil {
+					callOnError(hooks, url, err)
+					c.emit(Event{Type: EVENT_ERROR, Url: url, Err: err, Duration: duration})
+					outChannel <- _CrawlerFetchResult{id: id}
+					return
+				}
+
+				for _, analyze := range c.PageAnalyzers {
+					runPageAnalyzer(analyze, body, &pageResult)
+				}
+
+				for _, hook := range hooks {
+					if hook.OnUrlsFound != nil {
+						pageResult.FoundUrls = hook.OnUrlsFound(pageResult, pageResult.FoundUrls)
+					}
+				}
+
+				for _, hook := range hooks {
+					if hook.AnalyzePage != nil {
+						pageResult.Findings = append(pageResult.Findings, hook.AnalyzePage(body, pageResult)...)
+					}
 				}
 
 				result := _CrawlerFetchResult{
+					id:         id,
 					PageResult: pageResult,
 				}
 
-				domainName := crawler.ExtractDomainName(url.BaseUrl)
-
 				// plugin handling
-				if c.GetPluginsForDomain != nil {
-					handlers := c.GetPluginsForDomain(domainName)
-
-					var domainResultEntry crawler.DomainResultEntry
-					domainResults, ok := fetchedUrls[domainName]
-					if ok {
-						domainResultEntryPtr, ok := domainResults[url.BaseUrl]
-						if ok {
-							domainResultEntry = *domainResultEntryPtr
-						}
-					}
+				if len(hooks) > 0 {
+					domainResultEntry := c.getDomainResultEntry(domainName, url.BaseUrl)
 
-					result.Attachements = make(crawler.Attachements, len(handlers))
-					for _, handler := range handlers {
+					result.Attachements = make(crawler.Attachements, len(hooks))
+					for _, hook := range hooks {
 
-						att := handler(body, pageResult, domainResultEntry)
+						if hook.OnPageResultAdded == nil {
+							continue
+						}
+						att := hook.OnPageResultAdded(body, pageResult, domainResultEntry)
 						result.Attachements.AddAll(att)
 
 					}
 				}
 
+				c.emit(Event{
+					Type:         EVENT_RESPONSE_RECEIVED,
+					Url:          url,
+					PageResult:   &pageResult,
+					Attachements: result.Attachements,
+					Duration:     duration,
+				})
+
 				outChannel <- result
-			}(fetchedUrlsMapCopy)
+			}(id)
 			inChannel <- url
 
 		}
 
-		for ; addedWorkers > 0; addedWorkers-- {
-			crawlerFetchResult := <-outChannel
+		for addedWorkers > 0 {
+			var crawlerFetchResult _CrawlerFetchResult
+			select {
+			case crawlerFetchResult = <-outChannel:
+			case <-checkpointTicker:
+				c.checkpointIfDue(pending)
+				continue
+			}
+
+			addedWorkers--
+			delete(pending, crawlerFetchResult.id)
+			c.pagesSinceCheckpoint++
 
 			pageResult := crawlerFetchResult.PageResult
 
 			url := pageResult.Url.ToUrl()
 			if len(url) == 0 {
+				c.checkpointIfDue(pending)
 				continue
 			}
 
 			domainName := crawler.ExtractDomainName(url)
 
-			if c.Options.FetchRobots && !c.data.FetchedUrls.IsDomainPresent(domainName) {
-				pageResult.FoundUrls = append(pageResult.FoundUrls, crawler.FetchRobots(pageResult.Url.GetRootUrl())...)
+			if c.Options.FetchRobots && !c.store.FilterData().FetchedUrls.IsDomainPresent(domainName) {
+				robotsUrls := crawler.FetchRobots(pageResult.Url.GetRootUrl())
+				pageResult.FoundUrls = append(pageResult.FoundUrls, robotsUrls...)
+				for _, robotsUrl := range robotsUrls {
+					pageResult.Links = append(pageResult.Links, crawler.Link{Url: robotsUrl.ToUrl(), Source: crawler.LINK_SOURCE_ROBOTS})
+				}
+			}
+
+			parent := pageResult.Url
+			addedUrls := make([]crawler.PageRequest, 0, len(pageResult.FoundUrls))
+			events := make([]Event, 0, len(pageResult.FoundUrls))
+
+			// the stored results being saved with the scan
+			pageResult.RequestHeaders = crawler.RedactHeaders(pageResult.RequestHeaders)
+
+			c.dataLock.Lock()
+			err := c.store.AddPageResult(pageResult, crawlerFetchResult.Attachements)
+			c.dataLock.Unlock()
+			if err != nil {
+				log.Fatal(err)
 			}
 
-			c.data.AddFetchedUrl(pageResult)
+			// this loop being the only one writing to the store, the filters run
+			// holding dataLock for reading only, GetData not waiting for them
+			for _, foundUrl := range pageResult.FoundUrls {
+				foundUrl.Depth = parent.Depth + 1
+				foundUrl.Parent = parent.ToUrl()
+
+				c.dataLock.RLock()
+				reason := crawler.CheckUrlToAdd(c.store, foundUrl, shouldAddFilter, c.Scope)
+				c.dataLock.RUnlock()
+
+				if reason == crawler.REJECT_NONE {
+					c.dataLock.Lock()
+					added, err := c.store.PushUrl(foundUrl)
+					c.dataLock.Unlock()
+					if err != nil {
+						log.Fatal(err)
+					}
+					if !added {
+						reason = crawler.REJECT_DUPLICATE
+					}
+				}
+
+				if reason != crawler.REJECT_NONE {
+					events = append(events, Event{Type: EVENT_URL_REJECTED, Url: foundUrl, Parent: &parent, Reason: reason})
+					continue
+				}
+
+				addedUrls = append(addedUrls, foundUrl)
+				events = append(events, Event{Type: EVENT_URL_DISCOVERED, Url: foundUrl, Parent: &parent})
+			}
+
+			// emitted without holding dataLock, subscribers being free to call GetData
+			for _, event := range events {
+				c.emit(event)
+			}
+
+			c.checkpointIfDue(pending)
 
-			c.data.FetchedUrls[domainName].AddAttachements(pageResult.Url.BaseUrl, crawlerFetchResult.Attachements)
 			if len(pageResult.FoundUrls) <= 0 {
 				continue
 			}
 
-			addedUrls := c.data.AddUrlsToFetch(pageResult.FoundUrls, shouldAddFilter, c.Scope)
-
 			// callback
 			if c.OnUrlFound != nil {
 				c.OnUrlFound <- addedUrls
@@ -309,41 +649,28 @@ func (c *Crawler) Crawl(baseUrls []string) {
 		}
 
 	}
-	c.done = true
-}
-
-func fetchedUrlsCopy(fetchedUrls crawler.FetchedUrls) (crawler.FetchedUrls, error) {
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	dec := gob.NewDecoder(&buf)
 
-	err := enc.Encode(fetchedUrls)
-	if err != nil {
-		return nil, err
+	c.dataLock.Lock()
+	if err := c.store.Sync(nil); err != nil {
+		log.Println("could not save the crawl:", err)
 	}
+	c.dataLock.Unlock()
 
-	var copy crawler.FetchedUrls
-	err = dec.Decode(&copy)
-	if err != nil {
-		return nil, err
-	}
-
-	return copy, nil
+	c.setDone(true)
 }
 
 func AggressiveShouldAddFilter(foundUrl crawler.PageRequest, data *crawler.CrawlerData) bool {
 
-	fetchedUrls, present := data.FetchedUrls[foundUrl.BaseUrl]
+	domainName := crawler.ExtractDomainName(foundUrl.BaseUrl)
+	fetchedUrls, present := data.FetchedUrls[domainName][foundUrl.BaseUrl]
 
 	if !present {
 		return true
 	}
 
-	for _, urls := range fetchedUrls {
-		for _, url := range urls.PageResults {
-			if url.Url.Equals(foundUrl) {
-				return false
-			}
+	for _, url := range fetchedUrls.PageResults {
+		if url.Url.Equals(foundUrl) {
+			return false
 		}
 	}
 
@@ -351,6 +678,30 @@ func AggressiveShouldAddFilter(foundUrl crawler.PageRequest, data *crawler.Crawl
 
 }
 
+// returns a ShouldAddFilter accepting an url only if all the given filters accept it
+func AllShouldAddFilter(filters ...crawler.ShouldAddFilter) crawler.ShouldAddFilter {
+	return func(foundUrl crawler.PageRequest, data *crawler.CrawlerData) bool {
+		for _, filter := range filters {
+			if !filter(foundUrl, data) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// returns a ShouldAddFilter accepting an url if at least one of the given filters accepts it
+func AnyShouldAddFilter(filters ...crawler.ShouldAddFilter) crawler.ShouldAddFilter {
+	return func(foundUrl crawler.PageRequest, data *crawler.CrawlerData) bool {
+		for _, filter := range filters {
+			if filter(foundUrl, data) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // has to be over 0
 const VALIDITY_COUNT uint8 = 3
 
@@ -384,12 +735,73 @@ func ModerateShouldAddFilter(foundUrl crawler.PageRequest, data *crawler.Crawler
 
 }
 
+// the max number of bits differing between the fingerprints of two near-duplicate pages
+const SIMILARITY_THRESHOLD = 3
+
+// returns false if the same endpoint has responded VALIDITY_COUNT times
+// with the same status code and near-duplicate bodies
+func SimilarityShouldAddFilter(foundUrl crawler.PageRequest, data *crawler.CrawlerData) bool {
+
+	if !AggressiveShouldAddFilter(foundUrl, data) {
+		return false
+	}
+
+	domainName := crawler.ExtractDomainName(foundUrl.BaseUrl)
+	fetchedUrls, present := data.FetchedUrls[domainName][foundUrl.BaseUrl]
+
+	if !present || len(fetchedUrls.PageResults) < int(VALIDITY_COUNT) {
+		return true
+	}
+
+	reference := fetchedUrls.PageResults[0]
+	for _, url := range fetchedUrls.PageResults[1:] {
+		if url.StatusCode != reference.StatusCode ||
+			crawler.HammingDistance(url.Fingerprint, reference.Fingerprint) > SIMILARITY_THRESHOLD {
+			return true
+		}
+	}
+
+	return false
+}
+
 func LightShouldAddFilter(foundUrl crawler.PageRequest, data *crawler.CrawlerData) bool {
-	_, present := data.FetchedUrls[foundUrl.BaseUrl]
+	domainName := crawler.ExtractDomainName(foundUrl.BaseUrl)
+	_, present := data.FetchedUrls[domainName][foundUrl.BaseUrl]
 
 	return !present
 }
 
-func init() {
-	gob.Register(crawler.FetchedUrls{})
+// the number of distinct endpoints sharing the same url pattern
+// to be explored before the pattern is considered as known
+const CLUSTER_SAMPLES_COUNT uint8 = 5
+
+// returns a ShouldAddFilter clustering urls by structural pattern
+// (/users/123 and /users/456 both being /users/{id}) and rejecting
+// new endpoints of a cluster once samples endpoints of it have been found
+func NewSmartShouldAddFilter(samples int) crawler.ShouldAddFilter {
+	return func(foundUrl crawler.PageRequest, data *crawler.CrawlerData) bool {
+
+		if !AggressiveShouldAddFilter(foundUrl, data) {
+			return false
+		}
+
+		pattern := crawler.UrlPattern(foundUrl.BaseUrl)
+
+		// endpoint without any variable segment
+		if pattern == foundUrl.BaseUrl {
+			return true
+		}
+
+		// the endpoints of the cluster fetched or to fetch, counted when they are pushed
+		endpoints := data.EndpointPatterns().Endpoints(crawler.ExtractDomainName(foundUrl.BaseUrl), pattern)
+
+		// endpoint already part of the samples of its cluster
+		if endpoints[foundUrl.BaseUrl] {
+			return true
+		}
+
+		return len(endpoints) < samples
+	}
 }
+
+var SmartShouldAddFilter crawler.ShouldAddFilter = NewSmartShouldAddFilter(int(CLUSTER_SAMPLES_COUNT))
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+	"github.com/m1dugh/crawler/internal/store"
+)
+
+var testStores = []struct {
+	name     string
+	newStore func(t *testing.T) crawler.Store
+}{
+	{"memory", func(t *testing.T) crawler.Store {
+		return crawler.NewMemoryStore(nil)
+	}},
+	{"disk", func(t *testing.T) crawler.Store {
+		s, err := store.OpenDiskStore(t.TempDir(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}},
+}
+
+func TestSmartShouldAddFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		queued  []string
+		fetched []string
+		url     string
+		added   bool
+	}{
+		{"empty cluster", nil, nil, "https://a.com/users/1", true},
+		{"cluster of queued urls", []string{"https://a.com/users/1", "https://a.com/users/2"}, nil, "https://a.com/users/3", false},
+		{"cluster of fetched urls", nil, []string{"https://a.com/users/1", "https://a.com/users/2"}, "https://a.com/users/3", false},
+		{"cluster of both", []string{"https://a.com/users/1"}, []string{"https://a.com/users/2"}, "https://a.com/users/3", false},
+		{"sample of the cluster", []string{"https://a.com/users/1", "https://a.com/users/2"}, nil, "https://a.com/users/2?tab=posts", true},
+		{"cluster not full", []string{"https://a.com/users/1", "https://a.com/users/1?tab=posts"}, nil, "https://a.com/users/3", true},
+		{"other cluster", []string{"https://a.com/users/1", "https://a.com/users/2"}, nil, "https://a.com/orders/3", true},
+		{"other domain", []string{"https://a.com/users/1", "https://a.com/users/2"}, nil, "https://b.com/users/3", true},
+		{"no variable segment", []string{"https://a.com/users/1", "https://a.com/users/2"}, nil, "https://a.com/users", true},
+	}
+
+	filter := NewSmartShouldAddFilter(2)
+	scope := &crawler.Scope{}
+	for _, s := range testStores {
+		for _, test := range tests {
+			t.Run(s.name+"/"+test.name, func(t *testing.T) {
+				st := s.newStore(t)
+				for _, url := range test.fetched {
+					st.PushUrl(crawler.PageRequestFromUrl(url))
+					popped, _, _ := st.PopUrl()
+					st.AddPageResult(crawler.PageResult{Url: popped, StatusCode: 200}, nil)
+				}
+				for _, url := range test.queued {
+					st.PushUrl(crawler.PageRequestFromUrl(url))
+				}
+
+				reason := crawler.CheckUrlToAdd(st, crawler.PageRequestFromUrl(test.url), filter, scope)
+				if added := reason == crawler.REJECT_NONE; added != test.added {
+					t.Errorf("expected added %v, got %s", test.added, reason)
+				}
+			})
+		}
+	}
+}
+
+func TestFilterData(t *testing.T) {
+	urls := []string{"https://a.com/users/1", "https://a.com/users/2?tab=posts", "https://a.com/about", "https://b.com/orders/3"}
+
+	for _, s := range testStores {
+		t.Run(s.name, func(t *testing.T) {
+			st := s.newStore(t)
+			for _, url := range urls {
+				st.PushUrl(crawler.PageRequestFromUrl(url))
+			}
+			for i := 0; i < 2; i++ {
+				popped, _, _ := st.PopUrl()
+				st.AddPageResult(crawler.PageResult{Url: popped, StatusCode: 200}, nil)
+			}
+
+			// the same data whatever the store
+			data := st.FilterData()
+			if len(data.UrlsToFetch) != 0 {
+				t.Errorf("expected no urls to fetch, got %v", data.UrlsToFetch)
+			}
+			if results := len(data.FetchedUrls["a.com"]) + len(data.FetchedUrls["b.com"]); results != 2 {
+				t.Errorf("expected 2 fetched endpoints, got %v", data.FetchedUrls)
+			}
+			if data.Seen.Len() != len(urls) {
+				t.Errorf("expected %d seen urls, got %d", len(urls), data.Seen.Len())
+			}
+			expected := crawler.EndpointPatterns{
+				"a.com": {"https://a.com/users/{id}": {"https://a.com/users/1": true, "https://a.com/users/2": true}},
+				"b.com": {"https://b.com/orders/{id}": {"https://b.com/orders/3": true}},
+			}
+			if !reflect.DeepEqual(data.EndpointPatterns(), expected) {
+				t.Errorf("expected patterns %v, got %v", expected, data.EndpointPatterns())
+			}
+		})
+	}
+}
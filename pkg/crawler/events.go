@@ -0,0 +1,228 @@
+package crawler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/m1dugh/crawler/internal/crawler"
+)
+
+type EventType string
+
+const (
+	// a request is about to be sent
+	EVENT_REQUEST_STARTED EventType = "request_started"
+
+	// a response has been received and handled by the plugins
+	EVENT_RESPONSE_RECEIVED EventType = "response_received"
+
+	// a found url has been added to the urls to fetch
+	EVENT_URL_DISCOVERED EventType = "url_discovered"
+
+	// a found url has not been added to the urls to fetch, see Event.Reason
+	EVENT_URL_REJECTED EventType = "url_rejected"
+
+	// a request could not be made or its response could not be read
+	EVENT_ERROR EventType = "error"
+
+	// the crawl has ended, see Event.Done
+	EVENT_CRAWL_FINISHED EventType = "crawl_finished"
+)
+
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// the url of the request, empty for EVENT_CRAWL_FINISHED
+	Url crawler.PageRequest
+
+	// the page the url was found on (EVENT_URL_DISCOVERED, EVENT_URL_REJECTED)
+	Parent *crawler.PageRequest
+
+	// the result of the request and the attachements of the plugins (EVENT_RESPONSE_RECEIVED)
+	PageResult   *crawler.PageResult
+	Attachements crawler.Attachements
+
+	// the duration of the request (EVENT_RESPONSE_RECEIVED)
+	Duration time.Duration
+
+	// why the url has been rejected (EVENT_URL_REJECTED)
+	Reason crawler.RejectReason
+
+	// EVENT_ERROR
+	Err error
+
+	// false if the crawl has been stopped before the end (EVENT_CRAWL_FINISHED)
+	Done bool
+}
+
+// what a subscription does with an event when its buffer is full
+type OverflowPolicy int
+
+const (
+	// waits for the subscriber to read an event, slowing down the crawler
+	OVERFLOW_BLOCK OverflowPolicy = iota
+
+	// drops the new event
+	OVERFLOW_DROP_NEWEST
+
+	// drops the oldest event of the buffer to make room for the new one
+	OVERFLOW_DROP_OLDEST
+)
+
+const DEFAULT_SUBSCRIPTION_BUFFER_SIZE = 256
+
+type SubscriptionOptions struct {
+	// the number of events buffered before Overflow applies
+	BufferSize int
+
+	Overflow OverflowPolicy
+
+	// the types of the events delivered, all if empty
+	Types []EventType
+}
+
+func NewSubscriptionOptions() *SubscriptionOptions {
+	return &SubscriptionOptions{
+		BufferSize: DEFAULT_SUBSCRIPTION_BUFFER_SIZE,
+		Overflow:   OVERFLOW_BLOCK,
+	}
+}
+
+// a stream of the events of a crawler, Events being closed by Unsubscribe
+type Subscription struct {
+	Events <-chan Event
+
+	events   chan Event
+	done     chan struct{}
+	overflow OverflowPolicy
+	types    map[EventType]bool
+	dropped  uint64
+
+	closeOnce sync.Once
+	sync.RWMutex
+}
+
+// returns the number of events dropped because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription) accepts(eventType EventType) bool {
+	return len(s.types) == 0 || s.types[eventType]
+}
+
+func (s *Subscription) send(event Event) {
+	s.RLock()
+	defer s.RUnlock()
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	switch s.overflow {
+	case OVERFLOW_DROP_NEWEST:
+		select {
+		case s.events <- event:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case OVERFLOW_DROP_OLDEST:
+		for {
+			select {
+			case s.events <- event:
+				return
+			default:
+			}
+
+			select {
+			case <-s.events:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.events <- event:
+		case <-s.done:
+		}
+	}
+}
+
+func (s *Subscription) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		// waits for the pending sends to return
+		s.Lock()
+		close(s.events)
+		s.Unlock()
+	})
+}
+
+// subscribes to the events of the crawler, options being NewSubscriptionOptions() if nil
+func (c *Crawler) Subscribe(options *SubscriptionOptions) *Subscription {
+	if options == nil {
+		options = NewSubscriptionOptions()
+	}
+
+	bufferSize := options.BufferSize
+	if bufferSize <= 0 && options.Overflow != OVERFLOW_BLOCK {
+		bufferSize = 1
+	} else if bufferSize < 0 {
+		bufferSize = 0
+	}
+
+	events := make(chan Event, bufferSize)
+	subscription := &Subscription{
+		Events:   events,
+		events:   events,
+		done:     make(chan struct{}),
+		overflow: options.Overflow,
+		types:    make(map[EventType]bool, len(options.Types)),
+	}
+
+	for _, eventType := range options.Types {
+		subscription.types[eventType] = true
+	}
+
+	c.subscriptionsLock.Lock()
+	c.subscriptions = append(c.subscriptions, subscription)
+	c.subscriptionsLock.Unlock()
+
+	return subscription
+}
+
+// stops the delivery of the events to subscription and closes its channel
+func (c *Crawler) Unsubscribe(subscription *Subscription) {
+	c.subscriptionsLock.Lock()
+	for i, s := range c.subscriptions {
+		if s == subscription {
+			c.subscriptions = append(c.subscriptions[:i], c.subscriptions[i+1:]...)
+			break
+		}
+	}
+	c.subscriptionsLock.Unlock()
+
+	subscription.close()
+}
+
+func (c *Crawler) emit(event Event) {
+	c.subscriptionsLock.RLock()
+	subscriptions := append([]*Subscription(nil), c.subscriptions...)
+	c.subscriptionsLock.RUnlock()
+
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	event.Time = time.Now()
+	for _, subscription := range subscriptions {
+		if subscription.accepts(event.Type) {
+			subscription.send(event)
+		}
+	}
+}
@@ -12,6 +12,7 @@ type CrawlerData = crawler.CrawlerData
 type ShouldAddFilter = crawler.ShouldAddFilter
 
 var PageRequestFromUrl = crawler.PageRequestFromUrl
+var RedactHeaders = crawler.RedactHeaders
 
 func BasicScope(urls *crawler.RegexScope) *crawler.Scope {
 	return &crawler.Scope{
@@ -22,3 +23,66 @@ func BasicScope(urls *crawler.RegexScope) *crawler.Scope {
 }
 
 type DomainResultEntry = crawler.DomainResultEntry
+type Attachements = crawler.Attachements
+type Timings = crawler.Timings
+type Link = crawler.Link
+
+const (
+	LINK_SOURCE_TEXT        = crawler.LINK_SOURCE_TEXT
+	LINK_SOURCE_ROBOTS      = crawler.LINK_SOURCE_ROBOTS
+	LINK_SOURCE_FORM_ACTION = crawler.LINK_SOURCE_FORM_ACTION
+)
+
+type DomainHooks = crawler.DomainHooks
+type CrawlHooks = crawler.CrawlHooks
+type PageAnalyzer = crawler.PageAnalyzer
+
+type Technology = crawler.Technology
+
+type Finding = crawler.Finding
+type Severity = crawler.Severity
+
+const (
+	SEVERITY_INFO   = crawler.SEVERITY_INFO
+	SEVERITY_LOW    = crawler.SEVERITY_LOW
+	SEVERITY_MEDIUM = crawler.SEVERITY_MEDIUM
+	SEVERITY_HIGH   = crawler.SEVERITY_HIGH
+)
+
+var ParseSeverity = crawler.ParseSeverity
+
+type SeenSet = crawler.SeenSet
+type ScalableBloomFilter = crawler.ScalableBloomFilter
+
+const (
+	BLOOM_INITIAL_CAPACITY = crawler.BLOOM_INITIAL_CAPACITY
+	BLOOM_GROWTH_FACTOR    = crawler.BLOOM_GROWTH_FACTOR
+	BLOOM_TIGHTENING_RATIO = crawler.BLOOM_TIGHTENING_RATIO
+)
+
+var NewSeenSet = crawler.NewSeenSet
+var NewBloomSeenSet = crawler.NewBloomSeenSet
+var NewScalableBloomFilter = crawler.NewScalableBloomFilter
+
+type EndpointPatterns = crawler.EndpointPatterns
+
+type Store = crawler.Store
+type MemoryStore = crawler.MemoryStore
+
+var NewMemoryStore = crawler.NewMemoryStore
+var AddUrlToStore = crawler.AddUrlToStore
+var CheckUrlToAdd = crawler.CheckUrlToAdd
+
+var WriteFileAtomic = crawler.WriteFileAtomic
+
+type RejectReason = crawler.RejectReason
+
+const (
+	REJECT_NONE         = crawler.REJECT_NONE
+	REJECT_OUT_OF_SCOPE = crawler.REJECT_OUT_OF_SCOPE
+	REJECT_FILTERED     = crawler.REJECT_FILTERED
+	REJECT_DUPLICATE    = crawler.REJECT_DUPLICATE
+	REJECT_SKIPPED      = crawler.REJECT_SKIPPED
+)
+
+var MergeTechnologies = crawler.MergeTechnologies
@@ -0,0 +1,28 @@
+package graph
+
+import (
+	"github.com/m1dugh/crawler/internal/graph"
+)
+
+type Collapse = graph.Collapse
+type Options = graph.Options
+type Node = graph.Node
+type Edge = graph.Edge
+type Graph = graph.Graph
+
+const (
+	COLLAPSE_NONE      = graph.COLLAPSE_NONE
+	COLLAPSE_DIRECTORY = graph.COLLAPSE_DIRECTORY
+	COLLAPSE_HOST      = graph.COLLAPSE_HOST
+
+	SOURCE_PARENT = graph.SOURCE_PARENT
+)
+
+var COLLAPSE_MODES = graph.COLLAPSE_MODES
+var FORMATS = graph.FORMATS
+
+var Build = graph.Build
+var Write = graph.Write
+var WriteDot = graph.WriteDot
+var WriteGraphML = graph.WriteGraphML
+var WriteJson = graph.WriteJson
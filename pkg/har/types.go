@@ -0,0 +1,24 @@
+package har
+
+import (
+	"github.com/m1dugh/crawler/internal/har"
+)
+
+type Creator = har.Creator
+type NameValue = har.NameValue
+type Cookie = har.Cookie
+type Request = har.Request
+type Content = har.Content
+type Response = har.Response
+type Timings = har.Timings
+type Entry = har.Entry
+type Log = har.Log
+type File = har.File
+type Writer = har.Writer
+
+const HAR_VERSION = har.HAR_VERSION
+const CREATOR_NAME = har.CREATOR_NAME
+
+var NewEntry = har.NewEntry
+var NewWriter = har.NewWriter
+var Export = har.Export
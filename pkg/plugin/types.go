@@ -7,9 +7,43 @@ import (
 
 type Attachements = crawler.Attachements
 type OnPageResultAdded = crawler.OnPageResultAdded
+type OnStart = crawler.OnStart
+type BeforeRequest = crawler.BeforeRequest
+type OnUrlsFound = crawler.OnUrlsFound
+type OnError = crawler.OnError
+type OnFinish = crawler.OnFinish
+type AnalyzePage = crawler.AnalyzePage
+
+const API_VERSION = cr_plugin.API_VERSION
 
 type CrawlerPluginEntry = cr_plugin.CrawlerPluginEntry
 
 type CrawlerPlugin = cr_plugin.CrawlerPlugin
 
+type PluginGuard = cr_plugin.PluginGuard
+type GuardOptions = cr_plugin.GuardOptions
+
+var NewPluginGuard = cr_plugin.NewPluginGuard
+
+type PluginMetadata = cr_plugin.PluginMetadata
+type LoadError = cr_plugin.LoadError
+
+type OnInit = cr_plugin.OnInit
+type SettingSchema = cr_plugin.SettingSchema
+
+var ValidateSettings = cr_plugin.ValidateSettings
+
+type DomainMatchMode = cr_plugin.DomainMatchMode
+
+const (
+	MATCH_AUTO     = cr_plugin.MATCH_AUTO
+	MATCH_EXACT    = cr_plugin.MATCH_EXACT
+	MATCH_WILDCARD = cr_plugin.MATCH_WILDCARD
+	MATCH_REGEX    = cr_plugin.MATCH_REGEX
+	MATCH_ALL      = cr_plugin.MATCH_ALL
+)
+
 var NewAttachements = crawler.NewAttachements
+
+// loads the go plugin at path, errors being *LoadError
+var GetCrawlerPlugin = cr_plugin.GetCrawlerPlugin
@@ -0,0 +1,23 @@
+package store
+
+import (
+	"github.com/m1dugh/crawler/internal/store"
+)
+
+type DiskStore = store.DiskStore
+
+const (
+	FRONTIER_FILE = store.FRONTIER_FILE
+	RESULTS_FILE  = store.RESULTS_FILE
+	INDEX_FILE    = store.INDEX_FILE
+	STATE_FILE    = store.STATE_FILE
+	LOCK_FILE     = store.LOCK_FILE
+)
+
+var (
+	ErrReadOnly = store.ErrReadOnly
+	ErrLocked   = store.ErrLocked
+)
+
+var OpenDiskStore = store.OpenDiskStore
+var OpenDiskStoreReadOnly = store.OpenDiskStoreReadOnly
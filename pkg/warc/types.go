@@ -0,0 +1,22 @@
+package warc
+
+import (
+	"github.com/m1dugh/crawler/internal/warc"
+)
+
+type Writer = warc.Writer
+type Reader = warc.Reader
+type Record = warc.Record
+
+const (
+	WARC_VERSION          = warc.WARC_VERSION
+	DEFAULT_MAX_FILE_SIZE = warc.DEFAULT_MAX_FILE_SIZE
+	FILE_EXTENSION        = warc.FILE_EXTENSION
+
+	RECORD_WARCINFO = warc.RECORD_WARCINFO
+	RECORD_REQUEST  = warc.RECORD_REQUEST
+	RECORD_RESPONSE = warc.RECORD_RESPONSE
+)
+
+var NewWriter = warc.NewWriter
+var NewReader = warc.NewReader